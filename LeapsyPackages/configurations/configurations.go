@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 
 	"gopkg.in/ini.v1"
 
@@ -20,18 +21,11 @@ var (
 
 var configMap map[string]map[string]string // 設定資料
 
-// init - 初始函式
-func init() {
-	initializeConfigMapOrPanic() // 初始化設定資料或逐層結束程式
-}
+var configOncePointer = new(sync.Once) // 只載入一次設定檔
 
-// initializeConfigMapOrPanic - 初始化設定資料或逐層結束程式
+// initializeConfigMapOrPanic - 初始化設定資料或逐層結束程式(第一次取得設定值時才載入設定檔，不在套件初始化時載入)
 func initializeConfigMapOrPanic() {
-
-	if nil == configMap { // 若沒有設定資料
-		loadConfigFileOrPanic() // 載入設定檔或逐層結束程式
-	}
-
+	configOncePointer.Do(loadConfigFileOrPanic) // 載入設定檔或逐層結束程式
 }
 
 type emptyStruct struct{} // 空結構
@@ -79,6 +73,9 @@ func loadConfigFileOrPanic() {
  * @return string 設定資料區塊下關鍵字對應的值
  */
 func GetConfigValueOrPanic(sectionName, key string) string {
+
+	initializeConfigMapOrPanic() // 初始化設定資料或逐層結束程式
+
 	configValue, ok := configMap[sectionName][key] // 取得設定檔區塊下關鍵字對應的值
 
 	if !ok { // 若取得設定檔區塊下關鍵字對應的值失敗
@@ -125,3 +122,47 @@ func GetConfigPositiveIntValueOrPanic(sectionName string, key string) int {
 
 	return value // 回傳取得的設定檔區塊下關鍵字對應的正整數值
 }
+
+// GetConfigValueOrDefault - 取得設定值，若沒有設定則回傳預設值(用於可省略的設定)
+/**
+ * @param  string sectionName  區塊名
+ * @param  string key  關鍵字
+ * @param  string defaultValue  預設值
+ * @return string 設定資料區塊下關鍵字對應的值
+ */
+func GetConfigValueOrDefault(sectionName, key, defaultValue string) string {
+
+	initializeConfigMapOrPanic() // 初始化設定資料或逐層結束程式
+
+	if configValue, ok := configMap[sectionName][key]; ok { // 若有設定
+		return configValue // 回傳設定值
+	}
+
+	return defaultValue // 回傳預設值
+}
+
+// GetConfigSection - 取得整個設定區塊(複本，沒有此區塊則為空，用於關鍵字不固定的區塊)
+/**
+ * @param  string sectionName  區塊名
+ * @return map[string]string returnSection 區塊下所有關鍵字對應的值
+ */
+func GetConfigSection(sectionName string) (returnSection map[string]string) {
+
+	initializeConfigMapOrPanic() // 初始化設定資料或逐層結束程式
+
+	returnSection = make(map[string]string) // 為區塊建立空間
+
+	for key, configValue := range configMap[sectionName] { // 針對區塊下每一個關鍵字
+		returnSection[key] = configValue // 複製值
+	}
+
+	return // 回傳
+}
+
+// IsConfigLoaded - 判斷設定檔是否已載入
+/**
+ * @return bool 是否已載入設定檔
+ */
+func IsConfigLoaded() bool {
+	return 0 < len(configMap) // 有設定資料即已載入
+}
@@ -0,0 +1,58 @@
+package databases
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite驅動
+
+	"../configurations"
+	"../logings"
+	"../paths"
+)
+
+var (
+	logger = logings.GetLogger() // 記錄器
+
+	sqliteDatabasePointer *sql.DB          // SQLite資料庫指標
+	sqliteOncePointer     = new(sync.Once) // 只開啟一次SQLite資料庫
+)
+
+// openSQLiteDatabaseOrPanic - 開啟SQLite資料庫或逐層結束程式
+func openSQLiteDatabaseOrPanic() {
+
+	fileName := configurations.GetConfigValueOrPanic(`database`, `sqlite-file`) // SQLite資料庫檔名
+
+	paths.CreateIfPathNotExisted(filepath.Dir(fileName)) // 若資料庫所在路徑不存在，則建立路徑
+
+	databasePointer, sqlOpenError := sql.Open(`sqlite3`, fileName) // 開啟SQLite資料庫
+
+	if nil == sqlOpenError { // 若開啟成功，則確認資料庫可連線
+		sqlOpenError = databasePointer.Ping()
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`開啟SQLite資料庫 %s `},
+		[]interface{}{fileName},
+		sqlOpenError,
+	)
+
+	if nil != sqlOpenError { // 若開啟SQLite資料庫錯誤
+		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+	}
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+
+	sqliteDatabasePointer = databasePointer // 儲存資料庫指標
+}
+
+// GetSQLiteDatabaseOrPanic - 取得SQLite資料庫或逐層結束程式
+/**
+ * @return *sql.DB SQLite資料庫指標
+ */
+func GetSQLiteDatabaseOrPanic() *sql.DB {
+	sqliteOncePointer.Do(openSQLiteDatabaseOrPanic) // 第一次使用時開啟資料庫
+	return sqliteDatabasePointer                    // 回傳資料庫指標
+}
@@ -1,53 +1,213 @@
 package jwts
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"../configurations"
+	"../logings"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// 令牌用途(避免某用途的令牌被拿去做其他用途)
+const (
+	PurposeLogin = `login` // QRcode登入
+	PurposeArea  = `area`  // 切換場域
+)
 
-	//"errors"
-	//"sync"
-	//"time"
+const (
+	jwtConfigSectionName     = `jwt`      // 令牌設定區塊名
+	jwtKeysConfigSectionName = `jwt-keys` // 令牌密鑰設定區塊名(金鑰ID = 密鑰)
+	secretMinLength          = 32         // 密鑰最短長度
 
-	"github.com/dgrijalva/jwt-go"
-	//"github.com/google/uuid"
-	//"github.com/icrowley/fake"
-	//"github.com/robfig/cron"
-	//"../configurations"
+	secretsEnvironmentVariableName = `LEAPSY_JWT_KEYS` // 密鑰環境變數名(JSON，格式同金鑰檔，優先於金鑰檔與設定檔)
+	secretPlaceholderPrefix        = `CHANGE-ME`       // 範例設定檔中的密鑰佔位值前綴(不可用於簽署或驗證)
 )
 
 var (
-	secretByteArray = getNewSecretByteArray()
+	logger = logings.GetLogger() // 記錄器
+
+	secretByteArrayMapRWMutex = new(sync.RWMutex)       // 密鑰讀寫鎖
+	secretByteArrayMap        = make(map[string][]byte) // 所有可驗證的密鑰(金鑰ID對應密鑰)
+	currentKeyID              string                    // 簽署新令牌使用的金鑰ID
+	signingMethod             = jwt.SigningMethodHS512  // 簽署方法(只接受此方法)
+	issuer                    string                    // 簽發者
+	audience                  string                    // 接收者
+	leewaySeconds             int64                     // 驗證期限時允許的時間誤差(秒)
+
+	errTokenEmpty           = errors.New(`令牌為空`)
+	errTokenKeyIDUnknown    = errors.New(`令牌金鑰ID不存在或已停用`)
+	errTokenMethodInvalid   = errors.New(`令牌簽署方法錯誤`)
+	errTokenClaimsInvalid   = errors.New(`令牌內容格式錯誤`)
+	errTokenExpired         = errors.New(`令牌未設定期限或已過期`)
+	errTokenNotValidYet     = errors.New(`令牌尚未生效`)
+	errTokenIssuerInvalid   = errors.New(`令牌簽發者錯誤`)
+	errTokenAudienceInvalid = errors.New(`令牌接收者錯誤`)
+	errTokenPurposeInvalid  = errors.New(`令牌用途錯誤`)
 )
 
-const key_AES = "RB7Wfa$WHssV4LZce6HCyNYpdPPlYnDn" //固定 KEY 加密解密 32位數
+// Initialize - 從設定檔載入令牌設定與密鑰(需在主程式依設定檔初始化記錄器後呼叫)，設定錯誤則逐層結束程式
+func Initialize() {
+	issuer = configurations.GetConfigValueOrPanic(jwtConfigSectionName, `issuer`)
+	audience = configurations.GetConfigValueOrPanic(jwtConfigSectionName, `audience`)
+	leewaySeconds = int64(configurations.GetConfigPositiveIntValueOrPanic(jwtConfigSectionName, `leeway`))
 
-// getSecretByteArray - 取得密鑰
-func getSecretByteArray() []byte {
-	//secretByteArrayRWMutex.RLock()
-	gotSecretByteArray := secretByteArray
-	//secretByteArrayRWMutex.RUnlock()
-	return gotSecretByteArray
+	loadSecretsOrPanic() // 載入密鑰或逐層結束程式
 }
 
-// getNewSecretByteArray - 取得新密鑰
-/*
- * @return []byte result 結果
+// loadSecretsOrPanic - 從設定檔、金鑰檔與環境變數載入密鑰，找不到可用的簽署用密鑰則逐層結束程式
+func loadSecretsOrPanic() {
+
+	secrets := configurations.GetConfigSection(jwtKeysConfigSectionName) // 設定檔中的密鑰
+
+	keyFileName := configurations.GetConfigValueOrDefault(jwtConfigSectionName, `key-file`, ``) // 金鑰檔(JSON，金鑰ID對應密鑰)
+
+	var keyFileError error
+
+	if `` != keyFileName {
+
+		var fileBytes []byte
+
+		if fileBytes, keyFileError = ioutil.ReadFile(keyFileName); nil == keyFileError {
+			keyFileError = mergeSecretsJSON(secrets, fileBytes) // 金鑰檔優先於設定檔
+		}
+
+	}
+
+	if environmentSecrets, ok := os.LookupEnv(secretsEnvironmentVariableName); ok && nil == keyFileError {
+		keyFileError = mergeSecretsJSON(secrets, []byte(environmentSecrets)) // 環境變數優先於金鑰檔
+	}
+
+	newSecretByteArrayMap := getUsableSecretByteArrayMap(secrets)
+
+	newCurrentKeyID := configurations.GetConfigValueOrPanic(jwtConfigSectionName, `current-key-id`)
+
+	if _, ok := newSecretByteArrayMap[newCurrentKeyID]; !ok && nil == keyFileError {
+		keyFileError = errors.New(`找不到可用的簽署用金鑰 ` + newCurrentKeyID + `，請以金鑰檔(key-file)或環境變數 ` + secretsEnvironmentVariableName + ` 提供`)
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`載入令牌金鑰 %d 把,簽署用金鑰ID %s `},
+		[]interface{}{len(newSecretByteArrayMap), newCurrentKeyID},
+		keyFileError,
+	)
+
+	if nil != keyFileError {
+		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+	}
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+
+	secretByteArrayMapRWMutex.Lock()
+	secretByteArrayMap = newSecretByteArrayMap
+	currentKeyID = newCurrentKeyID
+	secretByteArrayMapRWMutex.Unlock()
+}
+
+// mergeSecretsJSON - 將JSON格式({"金鑰ID":"密鑰"})的密鑰合併進密鑰表(覆蓋相同金鑰ID)
+/**
+ * @param map[string]string secrets 密鑰表
+ * @param []byte jsonBytes JSON格式的密鑰
+ * @return error 格式錯誤
  */
-func getNewSecretByteArray() (result []byte) {
+func mergeSecretsJSON(secrets map[string]string, jsonBytes []byte) error {
 
-	//result = []byte(base64.StdEncoding.EncodeToString([]byte(`新的key`)))
-	//result = []byte(base64.StdEncoding.EncodeToString([]byte(key_AES)))
-	result = []byte(key_AES)
-	return
+	jsonSecrets := make(map[string]string)
+
+	if unmarshalError := json.Unmarshal(jsonBytes, &jsonSecrets); nil != unmarshalError {
+		return unmarshalError
+	}
+
+	for keyID, secret := range jsonSecrets {
+		secrets[keyID] = secret
+	}
+
+	return nil
 }
 
-// type Token struct {
-// 	Data string
-// }
+// getUsableSecretByteArrayMap - 取得可用的密鑰(長度不足或仍為佔位值的密鑰不使用並記錄警告)
+/**
+ * @param map[string]string secrets 密鑰表
+ * @return map[string][]byte 可用的密鑰(金鑰ID對應密鑰)
+ */
+func getUsableSecretByteArrayMap(secrets map[string]string) map[string][]byte {
+
+	usableSecretByteArrayMap := make(map[string][]byte)
 
+	for keyID, secret := range secrets {
+
+		reason := `` // 不使用的原因
+
+		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(secret)), secretPlaceholderPrefix) {
+			reason = `仍為範例佔位值`
+		} else if len(secret) < secretMinLength {
+			reason = `長度不足`
+		}
+
+		if `` == reason {
+			usableSecretByteArrayMap[keyID] = []byte(secret)
+			continue
+		}
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`令牌金鑰 %s %s(至少 %d 字)，不使用此金鑰 `},
+			[]interface{}{keyID, reason, secretMinLength},
+			nil,
+		)
+
+		logger.Warnf(formatString, args...) // 記錄警告
+	}
+
+	return usableSecretByteArrayMap
+}
+
+// getSecretByteArray - 取得金鑰ID對應的密鑰
+/**
+ * @param string keyID 金鑰ID
+ * @return []byte 密鑰
+ * @return bool 是否找到
+ */
+func getSecretByteArray(keyID string) ([]byte, bool) {
+	secretByteArrayMapRWMutex.RLock()
+	secretByteArray, ok := secretByteArrayMap[keyID]
+	secretByteArrayMapRWMutex.RUnlock()
+	return secretByteArray, ok
+}
+
+// getCurrentKeyID - 取得簽署用金鑰ID
+/**
+ * @return string 金鑰ID
+ */
+func getCurrentKeyID() string {
+	secretByteArrayMapRWMutex.RLock()
+	keyID := currentKeyID
+	secretByteArrayMapRWMutex.RUnlock()
+	return keyID
+}
+
+// TokenInfo - 令牌資訊
 type TokenInfo struct {
-	Data string
+	Data    string // 資料(帳號或場域代號)
+	Purpose string // 用途
+}
+
+// GetTokenLifetime - 取得某用途令牌的預設有效時間
+/*
+ * @params string purpose 用途
+ * @return time.Duration 有效時間
+ */
+func GetTokenLifetime(purpose string) time.Duration {
+	return time.Duration(configurations.GetConfigPositiveIntValueOrPanic(jwtConfigSectionName, purpose+`-ttl`)) * time.Second
 }
 
-// CreateToken - 產生令牌
+// CreateToken - 產生令牌(期限依用途設定)
 /*
  * @params TokenInfo tokenInfo 令牌資訊
  * @return *string returnTokenStringPointer 令牌字串指標
@@ -55,19 +215,41 @@ type TokenInfo struct {
 func CreateToken(tokenInfoPointer *TokenInfo) (returnTokenStringPointer *string) {
 
 	if nil != tokenInfoPointer {
+		returnTokenStringPointer = CreateTokenWithLifetime(tokenInfoPointer, GetTokenLifetime(tokenInfoPointer.Purpose))
+	}
+
+	return
+}
+
+// CreateTokenWithLifetime - 產生指定有效時間的令牌
+/*
+ * @params TokenInfo tokenInfo 令牌資訊
+ * @params time.Duration lifetime 有效時間
+ * @return *string returnTokenStringPointer 令牌字串指標
+ */
+func CreateTokenWithLifetime(tokenInfoPointer *TokenInfo, lifetime time.Duration) (returnTokenStringPointer *string) {
+
+	if nil != tokenInfoPointer {
+
+		ttlSeconds := int64(lifetime / time.Second)
+		now := time.Now().Unix()
+		keyID := getCurrentKeyID()
+
 		claim := jwt.MapClaims{
-			`Data`: tokenInfoPointer.Data,
+			`Data`:    tokenInfoPointer.Data,
+			`purpose`: tokenInfoPointer.Purpose,
+			`iss`:     issuer,
+			`aud`:     audience,
+			`iat`:     now,
+			`nbf`:     now,
+			`exp`:     now + ttlSeconds,
 		}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS512, claim)
-		tokenString, tokenSignedStringError := token.SignedString(getSecretByteArray())
+		token := jwt.NewWithClaims(signingMethod, claim)
+		token.Header[`kid`] = keyID
 
-		// logings.SendLog(
-		// 	[]string{`簽署令牌字串 %s `},
-		// 	[]interface{}{tokenString},
-		// 	tokenSignedStringError,
-		// 	logrus.InfoLevel,
-		// )
+		secretByteArray, _ := getSecretByteArray(keyID)
+		tokenString, tokenSignedStringError := token.SignedString(secretByteArray)
 
 		if nil == tokenSignedStringError {
 			returnTokenStringPointer = &tokenString
@@ -78,72 +260,90 @@ func CreateToken(tokenInfoPointer *TokenInfo) (returnTokenStringPointer *string)
 	return
 }
 
+// getSecretFunction - 依令牌標頭的金鑰ID取得密鑰(輪替期間新舊金鑰皆可驗證)
 func getSecretFunction() jwt.Keyfunc {
 	return func(token *jwt.Token) (interface{}, error) {
-		return getSecretByteArray(), nil
+
+		if token.Method != signingMethod {
+			return nil, errTokenMethodInvalid
+		}
+
+		keyID, _ := token.Header[`kid`].(string)
+
+		if secretByteArray, ok := getSecretByteArray(keyID); ok {
+			return secretByteArray, nil
+		}
+
+		return nil, errTokenKeyIDUnknown
 	}
 }
 
-// ParseToken - 解析令牌
+// ParseToken - 解析令牌(驗證簽章、期限、簽發者、接收者與用途)
 /*
  * @params string tokenString 令牌字串
+ * @params string purpose 預期用途
  * @return *TokenInfo tokenInfoPointer 令牌資訊指標
+ * @return error returnError 驗證失敗的原因
  */
-func ParseToken(tokenString string) (tokenInfoPointer *TokenInfo) {
+func ParseToken(tokenString string, purpose string) (tokenInfoPointer *TokenInfo, returnError error) {
 
-	if tokenString != `` {
+	if `` == tokenString {
+		returnError = errTokenEmpty
+		return
+	}
 
-		//defaultFormatSlices := []string{`解析令牌 %s `}
-		//dafaultArgs := []interface{}{tokenString}
+	// 時間相關欄位自行驗證(需允許時間誤差，且期限為必要欄位)
+	parser := jwt.Parser{ValidMethods: []string{signingMethod.Alg()}, SkipClaimsValidation: true}
 
-		token, jwtParseError := jwt.Parse(tokenString, getSecretFunction())
+	token, jwtParseError := parser.Parse(tokenString, getSecretFunction())
 
-		// logings.SendLog(
-		// 	[]string{`解析令牌 %s `},
-		// 	dafaultArgs,
-		// 	jwtParseError,
-		// 	logrus.InfoLevel,
-		// )
+	if nil != jwtParseError {
 
-		if jwtParseError != nil {
-			return
+		if validationError, ok := jwtParseError.(*jwt.ValidationError); ok && nil != validationError.Inner {
+			returnError = validationError.Inner
+		} else {
+			returnError = jwtParseError
 		}
 
-		mapClaim, ok := token.Claims.(jwt.MapClaims)
-
-		if !ok {
+		return
+	}
 
-			// logings.SendLog(
-			// 	defaultFormatSlices,
-			// 	dafaultArgs,
-			// 	errors.New(`將Claim轉成MapClaim錯誤`),
-			// 	logrus.InfoLevel,
-			// )
+	mapClaim, ok := token.Claims.(jwt.MapClaims)
 
-			return
-		}
-
-		// 驗證token，如果token被修改過則為false
-		if !token.Valid {
+	if !ok || !token.Valid {
+		returnError = errTokenClaimsInvalid
+		return
+	}
 
-			// logings.SendLog(
-			// 	defaultFormatSlices,
-			// 	dafaultArgs,
-			// 	errors.New(`無效令牌錯誤`),
-			// 	logrus.InfoLevel,
-			// )
+	now := time.Now().Unix()
+
+	switch {
+	case !mapClaim.VerifyExpiresAt(now-leewaySeconds, true):
+		returnError = errTokenExpired
+	case !mapClaim.VerifyNotBefore(now+leewaySeconds, true) || !mapClaim.VerifyIssuedAt(now+leewaySeconds, true):
+		returnError = errTokenNotValidYet
+	case !mapClaim.VerifyIssuer(issuer, true):
+		returnError = errTokenIssuerInvalid
+	case !mapClaim.VerifyAudience(audience, true):
+		returnError = errTokenAudienceInvalid
+	case purpose != mapClaim[`purpose`]:
+		returnError = errTokenPurposeInvalid
+	}
 
-			return
-		}
+	if nil != returnError {
+		return
+	}
 
-		dataValue, dataOK := mapClaim[`Data`].(string)
+	dataValue, dataOK := mapClaim[`Data`].(string)
 
-		if dataOK {
-			tokenInfoPointer = &TokenInfo{
-				Data: dataValue,
-			}
-		}
+	if !dataOK {
+		returnError = errTokenClaimsInvalid
+		return
+	}
 
+	tokenInfoPointer = &TokenInfo{
+		Data:    dataValue,
+		Purpose: purpose,
 	}
 
 	return
@@ -0,0 +1,234 @@
+package jwts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// setTestSettings - 設定測試用的簽發者、接收者、時間誤差與密鑰，回傳還原函式
+/**
+ * @return func() 還原原本設定的函式
+ */
+func setTestSettings() func() {
+
+	originalIssuer, originalAudience, originalLeewaySeconds := issuer, audience, leewaySeconds
+	originalSecretByteArrayMap, originalCurrentKeyID := secretByteArrayMap, currentKeyID
+
+	issuer = `test-issuer`
+	audience = `test-audience`
+	leewaySeconds = 30
+	secretByteArrayMap = map[string][]byte{
+		`old`: []byte(`old-secret-old-secret-old-secret-old`),
+		`new`: []byte(`new-secret-new-secret-new-secret-new`),
+	}
+	currentKeyID = `new`
+
+	return func() {
+		issuer, audience, leewaySeconds = originalIssuer, originalAudience, originalLeewaySeconds
+		secretByteArrayMap, currentKeyID = originalSecretByteArrayMap, originalCurrentKeyID
+	}
+}
+
+// signTestToken - 以指定金鑰ID簽署測試用令牌
+/**
+ * @param *testing.T t 測試
+ * @param jwt.MapClaims claim 令牌內容
+ * @param string keyID 金鑰ID
+ * @return string 令牌字串
+ */
+func signTestToken(t *testing.T, claim jwt.MapClaims, keyID string) string {
+
+	token := jwt.NewWithClaims(signingMethod, claim)
+	token.Header[`kid`] = keyID
+
+	secretByteArray, _ := getSecretByteArray(keyID)
+
+	tokenString, signError := token.SignedString(secretByteArray)
+
+	if nil != signError {
+		t.Fatalf(`簽署令牌失敗: %v`, signError)
+	}
+
+	return tokenString
+}
+
+// TestParseToken - 驗證期限、生效時間、簽發者、接收者與用途
+func TestParseToken(t *testing.T) {
+
+	defer setTestSettings()()
+
+	now := time.Now().Unix()
+
+	// getClaim - 取得預設有效的登入令牌內容，並套用變更
+	getClaim := func(changes map[string]interface{}) jwt.MapClaims {
+
+		claim := jwt.MapClaims{
+			`Data`:    `user`,
+			`purpose`: PurposeLogin,
+			`iss`:     issuer,
+			`aud`:     audience,
+			`iat`:     now,
+			`nbf`:     now,
+			`exp`:     now + 60,
+		}
+
+		for key, value := range changes {
+			if nil == value {
+				delete(claim, key)
+			} else {
+				claim[key] = value
+			}
+		}
+
+		return claim
+	}
+
+	testCases := []struct {
+		name      string
+		changes   map[string]interface{} // 令牌內容變更(nil為移除欄位)
+		keyID     string
+		purpose   string // 預期用途
+		wantError error
+	}{
+		{`有效的登入令牌`, nil, `new`, PurposeLogin, nil},
+		{`已過期`, map[string]interface{}{`exp`: now - 60}, `new`, PurposeLogin, errTokenExpired},
+		{`過期但在時間誤差內`, map[string]interface{}{`exp`: now - 10}, `new`, PurposeLogin, nil},
+		{`未設定期限`, map[string]interface{}{`exp`: nil}, `new`, PurposeLogin, errTokenExpired},
+		{`尚未生效`, map[string]interface{}{`nbf`: now + 60}, `new`, PurposeLogin, errTokenNotValidYet},
+		{`生效時間在時間誤差內`, map[string]interface{}{`nbf`: now + 10}, `new`, PurposeLogin, nil},
+		{`簽發時間在未來`, map[string]interface{}{`iat`: now + 60}, `new`, PurposeLogin, errTokenNotValidYet},
+		{`簽發者錯誤`, map[string]interface{}{`iss`: `other-issuer`}, `new`, PurposeLogin, errTokenIssuerInvalid},
+		{`未設定簽發者`, map[string]interface{}{`iss`: nil}, `new`, PurposeLogin, errTokenIssuerInvalid},
+		{`接收者錯誤`, map[string]interface{}{`aud`: `other-audience`}, `new`, PurposeLogin, errTokenAudienceInvalid},
+		{`未設定接收者`, map[string]interface{}{`aud`: nil}, `new`, PurposeLogin, errTokenAudienceInvalid},
+		{`場域令牌不可用於登入`, map[string]interface{}{`purpose`: PurposeArea}, `new`, PurposeLogin, errTokenPurposeInvalid},
+		{`登入令牌不可用於切換場域`, nil, `new`, PurposeArea, errTokenPurposeInvalid},
+		{`未設定用途`, map[string]interface{}{`purpose`: nil}, `new`, PurposeLogin, errTokenPurposeInvalid},
+		{`有效的場域令牌`, map[string]interface{}{`purpose`: PurposeArea}, `new`, PurposeArea, nil},
+		{`以舊金鑰簽署`, nil, `old`, PurposeLogin, nil},
+		{`金鑰ID不存在`, nil, `unknown`, PurposeLogin, errTokenKeyIDUnknown},
+		{`資料格式錯誤`, map[string]interface{}{`Data`: 1}, `new`, PurposeLogin, errTokenClaimsInvalid},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			tokenString := signTestToken(t, getClaim(testCase.changes), testCase.keyID)
+
+			tokenInfoPointer, parseError := ParseToken(tokenString, testCase.purpose)
+
+			if !errors.Is(parseError, testCase.wantError) {
+				t.Fatalf(`ParseToken() 錯誤 = %v，預期 %v`, parseError, testCase.wantError)
+			}
+
+			if nil == testCase.wantError && (nil == tokenInfoPointer || `user` != tokenInfoPointer.Data || testCase.purpose != tokenInfoPointer.Purpose) {
+				t.Errorf(`ParseToken() = %+v，預期資料 user、用途 %s`, tokenInfoPointer, testCase.purpose)
+			}
+
+		})
+	}
+
+}
+
+// TestParseTokenDuringKeyRotation - 輪替簽署用金鑰後，舊金鑰簽署的令牌在舊金鑰停用前仍可驗證
+func TestParseTokenDuringKeyRotation(t *testing.T) {
+
+	defer setTestSettings()()
+
+	testCases := []struct {
+		name            string
+		signingKeyID    string // 簽署時的簽署用金鑰ID
+		rotatedKeyID    string // 輪替後的簽署用金鑰ID
+		isOldKeyRemoved bool   // 輪替後是否停用舊金鑰
+		wantError       error
+	}{
+		{`未輪替`, `old`, `old`, false, nil},
+		{`輪替後舊令牌仍可驗證`, `old`, `new`, false, nil},
+		{`停用舊金鑰後舊令牌失效`, `old`, `new`, true, errTokenKeyIDUnknown},
+		{`輪替後產生的令牌不受停用舊金鑰影響`, `new`, `new`, true, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			secretByteArrayMap = map[string][]byte{
+				`old`: []byte(`old-secret-old-secret-old-secret-old`),
+				`new`: []byte(`new-secret-new-secret-new-secret-new`),
+			}
+			currentKeyID = testCase.signingKeyID
+
+			tokenStringPointer := CreateTokenWithLifetime(&TokenInfo{Data: `user`, Purpose: PurposeLogin}, time.Minute)
+
+			if nil == tokenStringPointer {
+				t.Fatalf(`CreateTokenWithLifetime() 產生令牌失敗`)
+			}
+
+			token, _, parseError := new(jwt.Parser).ParseUnverified(*tokenStringPointer, jwt.MapClaims{})
+
+			if nil != parseError {
+				t.Fatalf(`解析令牌標頭失敗: %v`, parseError)
+			}
+
+			if testCase.signingKeyID != token.Header[`kid`] {
+				t.Fatalf(`令牌金鑰ID = %v，預期 %s`, token.Header[`kid`], testCase.signingKeyID)
+			}
+
+			currentKeyID = testCase.rotatedKeyID
+
+			if testCase.isOldKeyRemoved {
+				delete(secretByteArrayMap, `old`)
+			}
+
+			if _, parseError := ParseToken(*tokenStringPointer, PurposeLogin); !errors.Is(parseError, testCase.wantError) {
+				t.Errorf(`ParseToken() 錯誤 = %v，預期 %v`, parseError, testCase.wantError)
+			}
+
+		})
+	}
+
+}
+
+// TestGetUsableSecretByteArrayMap - 佔位值與長度不足的密鑰不可用於簽署或驗證
+func TestGetUsableSecretByteArrayMap(t *testing.T) {
+
+	secrets := map[string]string{
+		`real`:        `real-secret-real-secret-real-secret`,
+		`placeholder`: `CHANGE-ME-supply-this-key-through-key-file-or-environment`,
+		`lowercase`:   `change-me-supply-this-key-through-key-file-or-environment`,
+		`short`:       `short-secret`,
+		`empty`:       ``,
+	}
+
+	usableSecretByteArrayMap := getUsableSecretByteArrayMap(secrets)
+
+	if 1 != len(usableSecretByteArrayMap) {
+		t.Fatalf(`可用密鑰 %d 把，應只有 1 把`, len(usableSecretByteArrayMap))
+	}
+
+	if _, ok := usableSecretByteArrayMap[`real`]; !ok {
+		t.Errorf(`應可使用金鑰 real`)
+	}
+
+}
+
+// TestMergeSecretsJSON - 金鑰檔或環境變數的密鑰覆蓋設定檔中相同金鑰ID的密鑰
+func TestMergeSecretsJSON(t *testing.T) {
+
+	secrets := map[string]string{`k1`: `CHANGE-ME`, `k0`: `kept`}
+
+	if mergeError := mergeSecretsJSON(secrets, []byte(`{"k1":"supplied"}`)); nil != mergeError {
+		t.Fatal(mergeError)
+	}
+
+	if `supplied` != secrets[`k1`] || `kept` != secrets[`k0`] {
+		t.Errorf(`合併後密鑰 %v`, secrets)
+	}
+
+	if nil == mergeSecretsJSON(secrets, []byte(`not-json`)) {
+		t.Errorf(`格式錯誤應回傳錯誤`)
+	}
+
+}
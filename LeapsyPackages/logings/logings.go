@@ -1,6 +1,10 @@
 package logings
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -11,120 +15,264 @@ import (
 )
 
 const (
-	fileExtensionConstString     = `.%Y%m%d%H`        // log檔副檔名
-	rotateTimeConstDuration      = time.Hour          // 切割期間
-	errorsMaxAgeConstDuration    = time.Hour * 24 * 7 // 錯誤保留最大期間
-	nonErrorsMaxAgeConstDuration = time.Hour * 24 * 7 // 非錯誤保留最大期間
+	fileExtensionConstString = `.%Y%m%d%H` // log檔副檔名
+)
+
+// 記錄格式
+const (
+	FormatText = `text` // 文字
+	FormatJSON = `json` // JSON(每筆記錄一行，欄位可直接查詢)
+)
+
+const (
+	redactedValueConstString = `[REDACTED]` // 敏感欄位的替代值
 )
 
 var logger = logrus.New() // 記錄器
 
-// init - 初始函式
-func init() {
-	setLogFiles() // 設定記錄檔
+var logFilesHookPointer *lfshook.LfsHook // 記錄檔鉤指標(Initialize後才有，切換記錄格式時一併切換)
+
+var sensitiveFieldKeywords = []string{`password`, `token`, `verificationcode`, `secret`} // 敏感欄位名稱關鍵字(不分大小寫)
+
+// redactionHook - 敏感欄位遮蔽鉤(在寫入任何輸出前把敏感欄位換成替代值)
+type redactionHook struct{}
+
+// Levels - 適用的記錄層級
+/**
+ * @return []logrus.Level 所有層級
+ */
+func (redactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire - 遮蔽敏感欄位
+/**
+ * @param *logrus.Entry entryPointer 記錄
+ * @return error 錯誤
+ */
+func (redactionHook) Fire(entryPointer *logrus.Entry) error {
+
+	for key := range entryPointer.Data { // 針對每一個欄位
+
+		lowerKey := strings.ToLower(key)
+
+		for _, keyword := range sensitiveFieldKeywords {
+
+			if strings.Contains(lowerKey, keyword) { // 若是敏感欄位
+				entryPointer.Data[key] = redactedValueConstString
+				break
+			}
+
+		}
+
+	}
+
+	return nil
 }
 
-// setLogFiles - 設定記錄檔
-func setLogFiles() {
+// Settings - 記錄設定(由主程式讀取設定檔後傳入)
+type Settings struct {
+	Level            string        // 記錄層級(panic、fatal、error、warn、info、debug、trace)
+	Directory        string        // 記錄檔目錄(其下分 errors、warns、infos)
+	RotationInterval time.Duration // 記錄檔切割間隔
+	MaxAge           time.Duration // 記錄檔保留期間
+	MaxSize          int64         // 單一記錄檔大小上限(位元組，0為不限)
+	Format           string        // 記錄格式(text或json)
+	IsStdoutMirrored bool          // 是否同時輸出到標準輸出
+}
+
+// init - 初始函式(設定檔尚未載入，先只輸出到標準錯誤，待主程式呼叫Initialize再加上記錄檔)
+func init() {
 	logger.SetLevel(logrus.TraceLevel) // 設定最高層級
-	logger.AddHook(filename.NewHook()) // 加上行數鉤
-	logger.AddHook(getLogFilesHook())  // 加上記錄檔鉤
+	logger.ReplaceHooks(getHooks(nil)) // 加上敏感欄位遮蔽鉤與行數鉤
 }
 
-// getLogFilesHook - 回傳記錄檔鉤
+// getHooks - 取得記錄器的所有鉤(敏感欄位遮蔽鉤需在記錄檔鉤之前)
 /**
- * @return  logrus.Hook  記錄檔鉤
+ * @param *lfshook.LfsHook logFilesHookPointer 記錄檔鉤指標(可為空)
+ * @return logrus.LevelHooks 所有鉤
  */
-func getLogFilesHook() logrus.Hook {
+func getHooks(logFilesHookPointer *lfshook.LfsHook) logrus.LevelHooks {
 
-	formatStringItemSlices := []string{`新增輪流`, `記錄檔`} // 記錄器格式片段
-	defaultArgs := []interface{}{}                    // 記錄器預設參數
+	levelHooks := make(logrus.LevelHooks)
 
-	errorRotateLogsName := `logs/errors/error` // 錯誤記錄檔名
+	levelHooks.Add(redactionHook{})    // 加上敏感欄位遮蔽鉤
+	levelHooks.Add(filename.NewHook()) // 加上行數鉤
 
-	// 新增輪流記錄檔
-	errorRotateLogs, rotatelogsNewError := rotatelogs.New(
-		errorRotateLogsName+fileExtensionConstString,
-		rotatelogs.WithLinkName(errorRotateLogsName),
-		rotatelogs.WithRotationTime(rotateTimeConstDuration),
-		rotatelogs.WithMaxAge(errorsMaxAgeConstDuration),
-	)
+	if nil != logFilesHookPointer {
+		levelHooks.Add(logFilesHookPointer) // 加上記錄檔鉤
+	}
 
-	// 取得記錄器格式字串與參數
-	formatString, args := GetLogFuncFormatAndArguments(
-		[]string{strings.Join(formatStringItemSlices, `錯誤`)},
-		defaultArgs,
-		rotatelogsNewError,
-	)
+	return levelHooks
+}
 
-	if nil != rotatelogsNewError { // 若新增輪流記錄檔錯誤，則記錄錯誤並逐層結束程式
-		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
-	} else { // 若新增輪流錯誤記錄檔成功，則記錄資訊
-		go logger.Infof(formatString, args...) // 記錄資訊
+// Initialize - 依記錄設定初始化記錄器(記錄層級、記錄檔、記錄格式與標準輸出)
+/**
+ * @param Settings settings 記錄設定
+ * @return error 錯誤
+ */
+func Initialize(settings Settings) error {
+
+	level, parseLevelError := logrus.ParseLevel(settings.Level) // 記錄層級
+
+	if nil != parseLevelError {
+		return parseLevelError
 	}
 
-	warnRotateLogsName := `logs/warns/warn` // 警告記錄檔名
+	newLogFilesHookPointer, getLogFilesHookError := getLogFilesHook(settings) // 記錄檔鉤
 
-	// 新增輪流記錄檔
-	warnRotateLogs, rotatelogsNewError := rotatelogs.New(
-		warnRotateLogsName+fileExtensionConstString,
-		rotatelogs.WithLinkName(warnRotateLogsName),
-		rotatelogs.WithRotationTime(rotateTimeConstDuration),
-		rotatelogs.WithMaxAge(nonErrorsMaxAgeConstDuration),
-	)
+	if nil != getLogFilesHookError {
+		return getLogFilesHookError
+	}
 
-	// 取得記錄器格式字串與參數
-	formatString, args = GetLogFuncFormatAndArguments(
-		[]string{strings.Join(formatStringItemSlices, `警告`)},
-		defaultArgs,
-		rotatelogsNewError,
-	)
+	logFilesHookPointer = newLogFilesHookPointer
 
-	if nil != rotatelogsNewError { // 若新增輪流記錄檔錯誤，則記錄錯誤並逐層結束程式
-		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
-	} else { // 若新增輪流警告記錄檔成功，則記錄資訊
-		go logger.Infof(formatString, args...) // 記錄資訊
+	if setFormatError := SetFormat(settings.Format); nil != setFormatError { // 設定記錄格式(同時套用到記錄檔鉤)
+		return setFormatError
 	}
 
-	infoRotateLogsName := `logs/infos/info` // 資訊記錄檔名
+	if settings.IsStdoutMirrored {
+		logger.SetOutput(os.Stdout) // 同時輸出到標準輸出
+	} else {
+		logger.SetOutput(ioutil.Discard) // 只寫入記錄檔
+	}
 
-	// 新增輪流記錄檔
-	infoRotateLogs, rotatelogsNewError := rotatelogs.New(
-		infoRotateLogsName+fileExtensionConstString,
-		rotatelogs.WithLinkName(infoRotateLogsName),
-		rotatelogs.WithRotationTime(rotateTimeConstDuration),
-		rotatelogs.WithMaxAge(nonErrorsMaxAgeConstDuration),
-	)
+	logger.ReplaceHooks(getHooks(logFilesHookPointer)) // 換上含記錄檔鉤的所有鉤
+	logger.SetLevel(level)                             // 設定記錄層級
 
-	// 取得記錄器格式字串與參數
-	formatString, args = GetLogFuncFormatAndArguments(
-		[]string{strings.Join(formatStringItemSlices, `資訊`)},
-		defaultArgs,
-		rotatelogsNewError,
-	)
+	return nil
+}
+
+// SetLevel - 執行期間調整記錄層級
+/**
+ * @param string levelString 記錄層級(panic、fatal、error、warn、info、debug、trace)
+ * @return error 錯誤
+ */
+func SetLevel(levelString string) error {
+
+	level, parseLevelError := logrus.ParseLevel(levelString) // 記錄層級
+
+	if nil != parseLevelError {
+		return parseLevelError
+	}
+
+	logger.SetLevel(level)
+
+	return nil
+}
+
+// GetLevel - 取得目前的記錄層級
+/**
+ * @return string 記錄層級
+ */
+func GetLevel() string {
+	return logger.GetLevel().String()
+}
+
+// SetFormat - 設定記錄格式(同時套用到標準輸出與記錄檔)
+/**
+ * @param string format 記錄格式(text或json)
+ * @return error 錯誤
+ */
+func SetFormat(format string) error {
+
+	var formatter logrus.Formatter // 記錄格式
+
+	switch format {
+
+	case FormatText:
+		formatter = &logrus.TextFormatter{}
+
+	case FormatJSON:
+		formatter = &logrus.JSONFormatter{}
+
+	default:
+		return fmt.Errorf(`記錄格式 %s 應為 %s 或 %s`, format, FormatText, FormatJSON)
+
+	}
+
+	logger.SetFormatter(formatter)
+
+	if nil != logFilesHookPointer {
+		logFilesHookPointer.SetFormatter(formatter)
+	}
+
+	return nil
+}
+
+// newRotateLogs - 新增輪流記錄檔
+/**
+ * @param string rotateLogsName 記錄檔名(不含副檔名，同時作為指向最新記錄檔的連結名)
+ * @param Settings settings 記錄設定
+ * @return *rotatelogs.RotateLogs 輪流記錄檔
+ * @return error 錯誤
+ */
+func newRotateLogs(rotateLogsName string, settings Settings) (*rotatelogs.RotateLogs, error) {
+
+	options := []rotatelogs.Option{
+		rotatelogs.WithLinkName(rotateLogsName),
+		rotatelogs.WithRotationTime(settings.RotationInterval),
+		rotatelogs.WithMaxAge(settings.MaxAge),
+	}
+
+	if 0 < settings.MaxSize { // 若有大小上限
+		options = append(options, rotatelogs.WithRotationSize(settings.MaxSize))
+	}
+
+	return rotatelogs.New(rotateLogsName+fileExtensionConstString, options...)
+}
+
+// getLogFilesHook - 回傳記錄檔鉤
+/**
+ * @param Settings settings 記錄設定
+ * @return *lfshook.LfsHook 記錄檔鉤
+ * @return error 錯誤
+ */
+func getLogFilesHook(settings Settings) (*lfshook.LfsHook, error) {
+
+	rotateLogsPointerMap := make(map[string]*rotatelogs.RotateLogs) // 記錄檔種類對應輪流記錄檔
+
+	// 錯誤、警告、資訊記錄檔
+	for _, kind := range []string{`error`, `warn`, `info`} {
+
+		rotateLogsName := filepath.Join(settings.Directory, kind+`s`, kind) // 記錄檔名
+
+		rotateLogsPointer, rotatelogsNewError := newRotateLogs(rotateLogsName, settings) // 新增輪流記錄檔
+
+		// 取得記錄器格式字串與參數
+		formatString, args := GetLogFuncFormatAndArguments(
+			[]string{`新增輪流記錄檔 %s `},
+			[]interface{}{rotateLogsName},
+			rotatelogsNewError,
+		)
+
+		if nil != rotatelogsNewError { // 若新增輪流記錄檔錯誤，則回傳錯誤
+			return nil, fmt.Errorf(formatString, args...)
+		}
 
-	if nil != rotatelogsNewError { // 若新增輪流記錄檔錯誤，則記錄錯誤並逐層結束程式
-		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
-	} else { // 若新增輪流記錄檔成功，則記錄資訊
 		go logger.Infof(formatString, args...) // 記錄資訊
+
+		rotateLogsPointerMap[kind] = rotateLogsPointer
 	}
 
-	// 建立記錄檔鉤
-	logFilesHook := lfshook.NewHook(
+	// 建立記錄檔鉤(除錯與追蹤記錄寫入資訊記錄檔，方便執行期間調低記錄層級查問題)
+	logFilesHookPointer := lfshook.NewHook(
 		lfshook.WriterMap{
-			logrus.PanicLevel: errorRotateLogs,
-			logrus.FatalLevel: errorRotateLogs,
-			logrus.ErrorLevel: errorRotateLogs,
-			logrus.WarnLevel:  warnRotateLogs,
-			logrus.InfoLevel:  infoRotateLogs,
+			logrus.PanicLevel: rotateLogsPointerMap[`error`],
+			logrus.FatalLevel: rotateLogsPointerMap[`error`],
+			logrus.ErrorLevel: rotateLogsPointerMap[`error`],
+			logrus.WarnLevel:  rotateLogsPointerMap[`warn`],
+			logrus.InfoLevel:  rotateLogsPointerMap[`info`],
+			logrus.DebugLevel: rotateLogsPointerMap[`info`],
+			logrus.TraceLevel: rotateLogsPointerMap[`info`],
 		},
 		&logrus.TextFormatter{},
 	)
 
-	return logFilesHook // 回傳記錄檔鉤
+	return logFilesHookPointer, nil // 回傳記錄檔鉤
 }
 
-// GetLogger - 取得記錄器
+// GetLogger - 取得記錄器(各套件共用同一個記錄器，Initialize直接調整此記錄器，因此可在初始化前先取得；但初始化前的記錄不會寫入記錄檔)
 /**
  * @return  *logrus.Logger  記錄器
  */
@@ -0,0 +1,370 @@
+package networkHub
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"../configurations"
+	"../logings"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	accountCredentialReadWriteLock = new(sync.RWMutex) // 帳號密碼與驗證碼讀寫鎖
+
+	// 密碼雜湊成本(bcrypt cost)
+	passwordBcryptCost int
+
+	// 密碼規則
+	passwordMinLength        int  // 最短長度
+	isPasswordLetterRequired bool // 是否需包含英文字母
+	isPasswordDigitRequired  bool // 是否需包含數字
+	isPasswordSymbolRequired bool // 是否需包含符號
+
+	// 驗證碼規則
+	verificationCodeTTLDuration            time.Duration // 有效時間
+	verificationCodeMaxAttempts            int           // 可輸入錯誤次數
+	verificationCodeLockoutDuration        time.Duration // 錯誤過多後鎖定時間
+	verificationCodeResendCooldownDuration time.Duration // 重新寄送間隔
+
+	errPasswordIncorrect             = errors.New(`無此帳號或密碼錯誤`)
+	errVerificationCodeIncorrect     = errors.New(`無此帳號或驗證碼錯誤`)
+	errVerificationCodeExpired       = errors.New(`驗證碼已過期或已使用，請重新取得驗證信`)
+	errVerificationCodeLocked        = errors.New(`驗證碼錯誤次數過多，請稍後再重新取得驗證信`)
+	errVerificationCodeResendTooSoon = errors.New(`驗證信寄送過於頻繁，請稍後再試`)
+	errPasswordUnchanged             = errors.New(`新密碼不可與舊密碼相同`)
+	errPasswordPolicyViolated        = errors.New(`密碼不符合規則`)
+)
+
+// initializeAccountCredentialSettings - 從設定檔載入密碼與驗證碼規則
+func initializeAccountCredentialSettings() {
+
+	passwordBcryptCost = configurations.GetConfigPositiveIntValueOrPanic(`password`, `bcrypt-cost`)
+
+	passwordMinLength = configurations.GetConfigPositiveIntValueOrPanic(`password`, `min-length`)
+	isPasswordLetterRequired = 1 == configurations.GetConfigPositiveIntValueOrPanic(`password`, `require-letter`)
+	isPasswordDigitRequired = 1 == configurations.GetConfigPositiveIntValueOrPanic(`password`, `require-digit`)
+	isPasswordSymbolRequired = 1 == configurations.GetConfigPositiveIntValueOrPanic(`password`, `require-symbol`)
+
+	verificationCodeTTLDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`verification-code`, `ttl`)) * time.Second
+	verificationCodeMaxAttempts = configurations.GetConfigPositiveIntValueOrPanic(`verification-code`, `max-attempts`)
+	verificationCodeLockoutDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`verification-code`, `lockout`)) * time.Second
+	verificationCodeResendCooldownDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`verification-code`, `resend-cooldown`)) * time.Second
+}
+
+// accountCredential - 帳號密碼與驗證碼(以accountCredentialReadWriteLock保護)
+type accountCredential struct {
+	passwordHash                    string    // 登入密碼雜湊(bcrypt)
+	verificationCodeHash            string    // 驗證碼雜湊(與密碼分開保存，使用一次後清除)
+	verificationCodeExpiryTime      time.Time // 驗證碼有效期限
+	verificationCodeFailedCount     int       // 驗證碼輸入錯誤次數
+	verificationCodeSentTime        time.Time // 最後寄送驗證信時間
+	verificationCodeLockedUntilTime time.Time // 驗證碼錯誤過多的鎖定期限
+}
+
+// generateVerificationCode - 產生六碼驗證碼(使用密碼學安全亂數)
+/**
+ * @return string returnVerificationCode 驗證碼
+ * @return error returnError 錯誤
+ */
+func generateVerificationCode() (returnVerificationCode string, returnError error) {
+
+	number, returnError := rand.Int(rand.Reader, big.NewInt(1000000))
+
+	if nil == returnError {
+		returnVerificationCode = fmt.Sprintf(`%06d`, number.Int64())
+	}
+
+	return // 回傳
+}
+
+// reserveVerificationCodeSending - 檢查帳號與連線能否寄送驗證信，可以則記錄寄送時間(鎖定中或未超過重新寄送間隔則回傳錯誤)
+/**
+ * @param *client clientPointer 連線指標
+ * @param *Account accountPointer 帳號指標
+ * @return error 錯誤
+ */
+func reserveVerificationCodeSending(clientPointer *client, accountPointer *Account) error {
+
+	now := time.Now()
+
+	credentialPointer := accountPointer.credentialPointer // 帳號密碼與驗證碼
+
+	accountCredentialReadWriteLock.Lock()         // 寫鎖
+	defer accountCredentialReadWriteLock.Unlock() // 記得解開寫鎖
+
+	if now.Before(credentialPointer.verificationCodeLockedUntilTime) { // 帳號鎖定中
+		return errVerificationCodeLocked
+	}
+
+	if now.Sub(credentialPointer.verificationCodeSentTime) < verificationCodeResendCooldownDuration ||
+		now.Sub(clientPointer.verificationCodeSentTime) < verificationCodeResendCooldownDuration { // 帳號或連線剛寄送過
+		return errVerificationCodeResendTooSoon
+	}
+
+	credentialPointer.verificationCodeSentTime = now
+	clientPointer.verificationCodeSentTime = now
+
+	return nil
+}
+
+// hashSecret - 將密碼或驗證碼加鹽雜湊
+/**
+ * @param string secret 密碼或驗證碼
+ * @return string returnHash 雜湊
+ * @return error returnError 錯誤
+ */
+func hashSecret(secret string) (returnHash string, returnError error) {
+
+	hashBytes, returnError := bcrypt.GenerateFromPassword([]byte(secret), passwordBcryptCost)
+
+	if nil == returnError {
+		returnHash = string(hashBytes)
+	}
+
+	return // 回傳
+}
+
+// isSecretMatched - 比對密碼或驗證碼與雜湊
+/**
+ * @param string hash 雜湊
+ * @param string secret 密碼或驗證碼
+ * @return bool 是否相符
+ */
+func isSecretMatched(hash string, secret string) bool {
+	return `` != hash && nil == bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret))
+}
+
+// checkPasswordPolicy - 檢查密碼是否符合密碼規則
+/**
+ * @param string password 密碼
+ * @return error 不符合時的錯誤(說明所有不符合的規則)
+ */
+func checkPasswordPolicy(password string) error {
+
+	isLetterFound, isDigitFound, isSymbolFound := false, false, false
+
+	for _, character := range password {
+
+		switch {
+		case unicode.IsLetter(character):
+			isLetterFound = true
+		case unicode.IsDigit(character):
+			isDigitFound = true
+		case unicode.IsPunct(character) || unicode.IsSymbol(character):
+			isSymbolFound = true
+		}
+
+	}
+
+	violations := []string{} // 不符合的規則
+
+	if len([]rune(password)) < passwordMinLength {
+		violations = append(violations, fmt.Sprintf(`長度至少%d個字`, passwordMinLength))
+	}
+
+	if isPasswordLetterRequired && !isLetterFound {
+		violations = append(violations, `需包含英文字母`)
+	}
+
+	if isPasswordDigitRequired && !isDigitFound {
+		violations = append(violations, `需包含數字`)
+	}
+
+	if isPasswordSymbolRequired && !isSymbolFound {
+		violations = append(violations, `需包含符號`)
+	}
+
+	if 0 < len(violations) {
+		return fmt.Errorf(`%w:%s`, errPasswordPolicyViolated, strings.Join(violations, `,`))
+	}
+
+	return nil
+}
+
+// verifyAccountPassword - 驗證帳號密碼
+/**
+ * @param *Account accountPointer 帳號指標
+ * @param string password 密碼
+ * @return error 錯誤
+ */
+func verifyAccountPassword(accountPointer *Account, password string) error {
+
+	accountCredentialReadWriteLock.RLock()                        // 讀鎖
+	passwordHash := accountPointer.credentialPointer.passwordHash // 不在鎖內比對，避免雜湊計算期間阻塞其他帳號
+	accountCredentialReadWriteLock.RUnlock()                      // 解開讀鎖
+
+	if !isSecretMatched(passwordHash, password) {
+		return errPasswordIncorrect
+	}
+
+	return nil
+}
+
+// setAccountVerificationCode - 設定帳號驗證碼(只保存雜湊，與密碼分開，不覆蓋密碼)
+/**
+ * @param *Account accountPointer 帳號指標
+ * @param string verificationCode 驗證碼
+ * @return error returnError 錯誤
+ */
+func setAccountVerificationCode(accountPointer *Account, verificationCode string) (returnError error) {
+
+	verificationCodeHash, returnError := hashSecret(verificationCode)
+
+	if nil != returnError {
+		return // 回傳
+	}
+
+	accountCredentialReadWriteLock.Lock() // 寫鎖
+	accountPointer.credentialPointer.verificationCodeHash = verificationCodeHash
+	accountPointer.credentialPointer.verificationCodeExpiryTime = time.Now().Add(verificationCodeTTLDuration)
+	accountPointer.credentialPointer.verificationCodeFailedCount = 0
+	accountCredentialReadWriteLock.Unlock() // 解開寫鎖
+
+	return // 回傳
+}
+
+// consumeAccountVerificationCode - 驗證並使用帳號驗證碼(驗證碼只能使用一次，錯誤過多則作廢並鎖定帳號)
+/**
+ * @param *Account accountPointer 帳號指標
+ * @param string verificationCode 驗證碼
+ * @return error 錯誤
+ */
+func consumeAccountVerificationCode(accountPointer *Account, verificationCode string) error {
+
+	now := time.Now()
+
+	credentialPointer := accountPointer.credentialPointer // 帳號密碼與驗證碼
+
+	accountCredentialReadWriteLock.RLock() // 讀鎖
+	verificationCodeHash := credentialPointer.verificationCodeHash
+	verificationCodeExpiryTime := credentialPointer.verificationCodeExpiryTime
+	verificationCodeLockedUntilTime := credentialPointer.verificationCodeLockedUntilTime
+	accountCredentialReadWriteLock.RUnlock() // 解開讀鎖
+
+	if now.Before(verificationCodeLockedUntilTime) {
+		return errVerificationCodeLocked
+	}
+
+	if `` == verificationCodeHash || now.After(verificationCodeExpiryTime) {
+		return errVerificationCodeExpired
+	}
+
+	if !isSecretMatched(verificationCodeHash, verificationCode) {
+
+		accountCredentialReadWriteLock.Lock()         // 寫鎖
+		defer accountCredentialReadWriteLock.Unlock() // 記得解開寫鎖
+
+		if verificationCodeHash != credentialPointer.verificationCodeHash { // 比對期間已被使用或已重新寄送
+			return errVerificationCodeIncorrect
+		}
+
+		credentialPointer.verificationCodeFailedCount++
+
+		if credentialPointer.verificationCodeFailedCount >= verificationCodeMaxAttempts { // 錯誤過多:作廢驗證碼並鎖定
+			credentialPointer.verificationCodeHash = ``
+			credentialPointer.verificationCodeExpiryTime = time.Time{}
+			credentialPointer.verificationCodeFailedCount = 0
+			credentialPointer.verificationCodeLockedUntilTime = now.Add(verificationCodeLockoutDuration)
+			return errVerificationCodeLocked
+		}
+
+		return errVerificationCodeIncorrect
+	}
+
+	accountCredentialReadWriteLock.Lock()         // 寫鎖
+	defer accountCredentialReadWriteLock.Unlock() // 記得解開寫鎖
+
+	if verificationCodeHash != credentialPointer.verificationCodeHash { // 比對期間已被使用或已重新寄送
+		return errVerificationCodeExpired
+	}
+
+	credentialPointer.verificationCodeHash = ``
+	credentialPointer.verificationCodeExpiryTime = time.Time{}
+	credentialPointer.verificationCodeFailedCount = 0
+
+	return nil
+}
+
+// changeAccountPassword - 變更帳號密碼(先寫入帳號儲存庫，再更新快取)
+/**
+ * @param *Account accountPointer 帳號指標
+ * @param string oldPassword 舊密碼
+ * @param string newPassword 新密碼
+ * @return error returnError 錯誤
+ */
+func changeAccountPassword(accountPointer *Account, oldPassword string, newPassword string) (returnError error) {
+
+	if returnError = verifyAccountPassword(accountPointer, oldPassword); nil != returnError {
+		return // 回傳
+	}
+
+	if oldPassword == newPassword {
+		returnError = errPasswordUnchanged
+		return // 回傳
+	}
+
+	if returnError = checkPasswordPolicy(newPassword); nil != returnError {
+		return // 回傳
+	}
+
+	passwordHash, returnError := hashSecret(newPassword)
+
+	if nil != returnError {
+		return // 回傳
+	}
+
+	if returnError = accountStorePointer.repository.updatePasswordHash(accountPointer.UserID, passwordHash); nil != returnError {
+		return // 回傳
+	}
+
+	accountCredentialReadWriteLock.Lock() // 寫鎖
+	accountPointer.credentialPointer.passwordHash = passwordHash
+	accountCredentialReadWriteLock.Unlock() // 解開寫鎖
+
+	return // 回傳
+}
+
+// migrateLegacyPassword - 將帳號儲存資料中的明碼密碼轉為雜湊並寫回帳號儲存庫
+/**
+ * @param accountRepository repository 帳號儲存庫
+ * @param *accountRecord accountRecordPointer 帳號儲存資料指標
+ */
+func migrateLegacyPassword(repository accountRepository, accountRecordPointer *accountRecord) {
+
+	if `` == accountRecordPointer.UserPassword { // 沒有明碼密碼
+		return // 回傳
+	}
+
+	var hashError error
+
+	if `` == accountRecordPointer.PasswordHash { // 已有雜湊時只清除明碼密碼
+		accountRecordPointer.PasswordHash, hashError = hashSecret(accountRecordPointer.UserPassword)
+	}
+
+	if nil == hashError {
+		accountRecordPointer.UserPassword = ``
+
+		hashError = repository.updatePasswordHash(accountRecordPointer.UserID, accountRecordPointer.PasswordHash)
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`帳號 %s 的明碼密碼轉為雜湊 `},
+		[]interface{}{accountRecordPointer.UserID},
+		hashError,
+	)
+
+	if nil != hashError {
+		logger.Errorf(formatString, args...) // 記錄錯誤
+		return                               // 回傳
+	}
+
+	logger.Warnf(formatString, args...) // 記錄警告
+}
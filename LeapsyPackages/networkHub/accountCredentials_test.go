@@ -0,0 +1,126 @@
+package networkHub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestConsumeAccountVerificationCode - 驗證碼逾時失效、只能使用一次、錯誤過多則作廢並鎖定帳號
+func TestConsumeAccountVerificationCode(t *testing.T) {
+
+	defer func(originalCost, originalMaxAttempts int, originalTTLDuration, originalLockoutDuration time.Duration) {
+		passwordBcryptCost = originalCost
+		verificationCodeMaxAttempts = originalMaxAttempts
+		verificationCodeTTLDuration = originalTTLDuration
+		verificationCodeLockoutDuration = originalLockoutDuration
+	}(passwordBcryptCost, verificationCodeMaxAttempts, verificationCodeTTLDuration, verificationCodeLockoutDuration)
+
+	passwordBcryptCost = bcrypt.MinCost
+	verificationCodeMaxAttempts = 3
+	verificationCodeTTLDuration = time.Minute
+	verificationCodeLockoutDuration = time.Minute
+
+	const verificationCode = `123456`
+	const wrongCode = `654321`
+
+	testCases := []struct {
+		name        string
+		expiredAgo  time.Duration // 驗證碼已過期多久(0為未過期)
+		attempts    []string      // 依序輸入的驗證碼
+		wantErrors  []error       // 每次輸入預期的錯誤
+		isLockedOut bool          // 最後是否仍在鎖定中
+	}{
+		{`正確驗證碼`, 0, []string{verificationCode}, []error{nil}, false},
+		{`驗證碼只能使用一次`, 0, []string{verificationCode, verificationCode}, []error{nil, errVerificationCodeExpired}, false},
+		{`驗證碼已過期`, time.Second, []string{verificationCode}, []error{errVerificationCodeExpired}, false},
+		{`錯誤後仍可輸入正確驗證碼`, 0, []string{wrongCode, wrongCode, verificationCode}, []error{errVerificationCodeIncorrect, errVerificationCodeIncorrect, nil}, false},
+		{`錯誤達上限則鎖定`, 0, []string{wrongCode, wrongCode, wrongCode}, []error{errVerificationCodeIncorrect, errVerificationCodeIncorrect, errVerificationCodeLocked}, true},
+		{`鎖定中輸入正確驗證碼`, 0, []string{wrongCode, wrongCode, wrongCode, verificationCode}, []error{errVerificationCodeIncorrect, errVerificationCodeIncorrect, errVerificationCodeLocked, errVerificationCodeLocked}, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			accountPointer := &Account{UserID: `user`, credentialPointer: &accountCredential{}}
+
+			if setError := setAccountVerificationCode(accountPointer, verificationCode); nil != setError {
+				t.Fatalf(`setAccountVerificationCode() 錯誤 = %v`, setError)
+			}
+
+			if 0 < testCase.expiredAgo {
+				accountPointer.credentialPointer.verificationCodeExpiryTime = time.Now().Add(-testCase.expiredAgo)
+			}
+
+			for index, attempt := range testCase.attempts {
+				if consumeError := consumeAccountVerificationCode(accountPointer, attempt); !errors.Is(consumeError, testCase.wantErrors[index]) {
+					t.Fatalf(`第 %d 次輸入 consumeAccountVerificationCode() 錯誤 = %v，預期 %v`, index+1, consumeError, testCase.wantErrors[index])
+				}
+			}
+
+			if isLockedOut := time.Now().Before(accountPointer.credentialPointer.verificationCodeLockedUntilTime); isLockedOut != testCase.isLockedOut {
+				t.Errorf(`鎖定中 = %v，預期 %v`, isLockedOut, testCase.isLockedOut)
+			}
+
+		})
+	}
+
+}
+
+// TestConsumeAccountVerificationCodeAfterLockout - 鎖定期滿後原驗證碼已作廢，重新取得的驗證碼可使用
+func TestConsumeAccountVerificationCodeAfterLockout(t *testing.T) {
+
+	defer func(originalCost, originalMaxAttempts int, originalTTLDuration, originalLockoutDuration time.Duration) {
+		passwordBcryptCost = originalCost
+		verificationCodeMaxAttempts = originalMaxAttempts
+		verificationCodeTTLDuration = originalTTLDuration
+		verificationCodeLockoutDuration = originalLockoutDuration
+	}(passwordBcryptCost, verificationCodeMaxAttempts, verificationCodeTTLDuration, verificationCodeLockoutDuration)
+
+	passwordBcryptCost = bcrypt.MinCost
+	verificationCodeMaxAttempts = 1
+	verificationCodeTTLDuration = time.Minute
+	verificationCodeLockoutDuration = time.Minute
+
+	testCases := []struct {
+		name      string
+		isResent  bool // 鎖定期滿後是否重新取得驗證碼
+		code      string
+		wantError error
+	}{
+		{`原驗證碼已作廢`, false, `111111`, errVerificationCodeExpired},
+		{`重新取得的驗證碼`, true, `222222`, nil},
+		{`重新取得後輸入原驗證碼再次鎖定`, true, `111111`, errVerificationCodeLocked},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			accountPointer := &Account{UserID: `user`, credentialPointer: &accountCredential{}}
+
+			if setError := setAccountVerificationCode(accountPointer, `111111`); nil != setError {
+				t.Fatalf(`setAccountVerificationCode() 錯誤 = %v`, setError)
+			}
+
+			if consumeError := consumeAccountVerificationCode(accountPointer, `000000`); !errors.Is(consumeError, errVerificationCodeLocked) {
+				t.Fatalf(`錯誤達上限 consumeAccountVerificationCode() 錯誤 = %v，預期 %v`, consumeError, errVerificationCodeLocked)
+			}
+
+			accountPointer.credentialPointer.verificationCodeLockedUntilTime = time.Now().Add(-time.Second) // 鎖定期滿
+
+			if testCase.isResent {
+				if setError := setAccountVerificationCode(accountPointer, `222222`); nil != setError {
+					t.Fatalf(`setAccountVerificationCode() 錯誤 = %v`, setError)
+				}
+			}
+
+			if consumeError := consumeAccountVerificationCode(accountPointer, testCase.code); !errors.Is(consumeError, testCase.wantError) {
+				t.Errorf(`consumeAccountVerificationCode() 錯誤 = %v，預期 %v`, consumeError, testCase.wantError)
+			}
+
+		})
+	}
+
+}
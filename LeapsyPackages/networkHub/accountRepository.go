@@ -0,0 +1,646 @@
+package networkHub
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"../configurations"
+	"../databases"
+	"../logings"
+	"../paths"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// accountRecord - 帳號儲存資料(帳號檔或資料庫中的一筆帳號)
+type accountRecord struct {
+	UserID       string `json:"userID" yaml:"userID"`                                 // 使用者登入帳號
+	UserPassword string `json:"userPassword,omitempty" yaml:"userPassword,omitempty"` // 舊版明碼密碼(載入時轉為雜湊並清除)
+	PasswordHash string `json:"passwordHash" yaml:"passwordHash"`                     // 使用者登入密碼雜湊(bcrypt)
+	UserName     string `json:"userName" yaml:"userName"`                             // 使用者名稱
+	IsExpert     int    `json:"isExpert" yaml:"isExpert"`                             // 是否為專家帳號:1是,2否
+	IsFrontline  int    `json:"isFrontline" yaml:"isFrontline"`                       // 是否為一線人員帳號:1是,2否
+	Role         string `json:"role,omitempty" yaml:"role,omitempty"`                 // 角色(空則依專家、一線人員旗標判斷)
+	Area         []int  `json:"area" yaml:"area"`                                     // 專家所屬場域代號
+	PicFile      string `json:"picFile" yaml:"picFile"`                               // 帳號頭像檔名
+	IsDemo       bool   `json:"isDemo" yaml:"isDemo"`                                 // 是否為demo測試帳號
+}
+
+// toAccount - 將帳號儲存資料轉成帳號
+/**
+ * @return *Account 帳號指標
+ */
+func (accountRecordPointer *accountRecord) toAccount() *Account {
+
+	accountPointer := &Account{
+		UserID:            accountRecordPointer.UserID,
+		UserName:          accountRecordPointer.UserName,
+		IsExpert:          accountRecordPointer.IsExpert,
+		IsFrontline:       accountRecordPointer.IsFrontline,
+		Role:              accountRecordPointer.Role,
+		Area:              accountRecordPointer.Area,
+		credentialPointer: &accountCredential{passwordHash: accountRecordPointer.PasswordHash},
+		isDemo:            accountRecordPointer.IsDemo,
+	}
+
+	if `` != accountRecordPointer.PicFile { // 若有設定頭像檔，則讀取頭像
+
+		pic, getAccountPicStringError := getAccountPicString(accountRecordPointer.PicFile)
+
+		if nil != getAccountPicStringError { // 若讀取頭像失敗，記錄警告並以空頭像載入此帳號，不影響其他帳號
+			logger.WithFields(logrus.Fields{
+				loggerFieldOfUserID:  accountRecordPointer.UserID,
+				loggerFieldOfPicFile: accountRecordPointer.PicFile,
+			}).WithError(getAccountPicStringError).Warn(`帳號頭像讀取失敗，改用空頭像`)
+		}
+
+		accountPointer.Pic = pic
+	}
+
+	if nil == accountPointer.Area { // 回傳給客戶端時維持空陣列
+		accountPointer.Area = []int{}
+	}
+
+	return accountPointer
+}
+
+// accountRepository - 帳號儲存庫
+type accountRepository interface {
+
+	// loadAllAccountRecords - 載入所有帳號儲存資料
+	loadAllAccountRecords() ([]accountRecord, error)
+
+	// findAccountRecord - 查找帳號儲存資料(找不到回傳nil)
+	findAccountRecord(userID string) (*accountRecord, error)
+
+	// updatePasswordHash - 更新帳號密碼雜湊(同時清除舊版明碼密碼)
+	updatePasswordHash(userID string, passwordHash string) error
+}
+
+// accountFileRepository - 帳號檔儲存庫(JSON或YAML)
+type accountFileRepository struct {
+	fileName      string      // 帳號檔名
+	isYAML        bool        // 是否為YAML帳號檔(否則為JSON帳號檔)
+	fileWriteLock *sync.Mutex // 寫檔鎖(讀出、修改、寫回需一次完成)
+
+	indexReadWriteLock *sync.RWMutex            // 帳號索引讀寫鎖
+	accountRecordIndex map[string]accountRecord // 使用者登入帳號對應帳號儲存資料(每次同步載入帳號檔時重建)
+}
+
+// readAccountRecords - 讀取並解譯帳號檔
+/**
+ * @return []accountRecord returnAccountRecords 帳號儲存資料
+ * @return error returnError 錯誤
+ */
+func (accountFileRepositoryPointer *accountFileRepository) readAccountRecords() (returnAccountRecords []accountRecord, returnError error) {
+
+	fileBytes, returnError := ioutil.ReadFile(accountFileRepositoryPointer.fileName) // 讀取帳號檔
+
+	if nil != returnError { // 若讀取帳號檔錯誤
+		return // 回傳
+	}
+
+	if accountFileRepositoryPointer.isYAML {
+		returnError = yaml.Unmarshal(fileBytes, &returnAccountRecords) // 解譯YAML帳號檔
+	} else {
+		returnError = json.Unmarshal(fileBytes, &returnAccountRecords) // 解譯JSON帳號檔
+	}
+
+	return // 回傳
+}
+
+// loadAllAccountRecords - 載入所有帳號儲存資料(並重建帳號索引)
+/**
+ * @return []accountRecord returnAccountRecords 帳號儲存資料
+ * @return error returnError 錯誤
+ */
+func (accountFileRepositoryPointer *accountFileRepository) loadAllAccountRecords() (returnAccountRecords []accountRecord, returnError error) {
+
+	if returnAccountRecords, returnError = accountFileRepositoryPointer.readAccountRecords(); nil != returnError { // 若讀取錯誤，保留原本索引
+		return // 回傳
+	}
+
+	accountRecordIndex := make(map[string]accountRecord, len(returnAccountRecords))
+
+	for _, loadedAccountRecord := range returnAccountRecords {
+		accountRecordIndex[loadedAccountRecord.UserID] = loadedAccountRecord
+	}
+
+	accountFileRepositoryPointer.indexReadWriteLock.Lock() // 寫鎖
+	accountFileRepositoryPointer.accountRecordIndex = accountRecordIndex
+	accountFileRepositoryPointer.indexReadWriteLock.Unlock() // 解開寫鎖
+
+	return // 回傳
+}
+
+// findAccountRecord - 查找帳號儲存資料(從帳號索引查找，帳號檔新增的帳號於下次同步後才找得到)
+/**
+ * @param string userID 使用者登入帳號
+ * @return *accountRecord returnAccountRecordPointer 帳號儲存資料指標(找不到回傳nil)
+ * @return error returnError 錯誤
+ */
+func (accountFileRepositoryPointer *accountFileRepository) findAccountRecord(userID string) (returnAccountRecordPointer *accountRecord, returnError error) {
+
+	accountFileRepositoryPointer.indexReadWriteLock.RLock() // 讀鎖
+	isIndexed := nil != accountFileRepositoryPointer.accountRecordIndex
+	foundAccountRecord, ok := accountFileRepositoryPointer.accountRecordIndex[userID]
+	accountFileRepositoryPointer.indexReadWriteLock.RUnlock() // 解開讀鎖
+
+	if !isIndexed { // 尚未載入過帳號檔，則先載入並建立索引
+
+		if _, returnError = accountFileRepositoryPointer.loadAllAccountRecords(); nil != returnError { // 若載入錯誤
+			return // 回傳
+		}
+
+		return accountFileRepositoryPointer.findAccountRecord(userID)
+	}
+
+	if ok { // 若找到帳號
+		returnAccountRecordPointer = &foundAccountRecord
+	}
+
+	return // 回傳
+}
+
+// updatePasswordHash - 更新帳號密碼雜湊
+/**
+ * @param string userID 使用者登入帳號
+ * @param string passwordHash 密碼雜湊
+ * @return error returnError 錯誤
+ */
+func (accountFileRepositoryPointer *accountFileRepository) updatePasswordHash(userID string, passwordHash string) (returnError error) {
+
+	accountFileRepositoryPointer.fileWriteLock.Lock()         // 寫檔鎖
+	defer accountFileRepositoryPointer.fileWriteLock.Unlock() // 記得解開寫檔鎖
+
+	accountRecords, returnError := accountFileRepositoryPointer.readAccountRecords() // 讀取帳號檔
+
+	if nil != returnError { // 若載入錯誤
+		return // 回傳
+	}
+
+	isFound := false // 是否找到帳號
+
+	for index := range accountRecords { // 針對每一筆帳號儲存資料
+
+		if userID == accountRecords[index].UserID { // 若找到帳號
+			accountRecords[index].PasswordHash = passwordHash
+			accountRecords[index].UserPassword = ``
+			isFound = true
+		}
+
+	}
+
+	if !isFound { // 若找不到帳號
+		returnError = errors.New(`帳號檔中找不到帳號 ` + userID)
+		return // 回傳
+	}
+
+	var fileBytes []byte
+
+	if accountFileRepositoryPointer.isYAML {
+		fileBytes, returnError = yaml.Marshal(accountRecords)
+	} else {
+		fileBytes, returnError = json.MarshalIndent(accountRecords, ``, `  `)
+	}
+
+	if nil != returnError { // 若轉換錯誤
+		return // 回傳
+	}
+
+	fileName := accountFileRepositoryPointer.fileName
+
+	paths.CreateIfPathNotExisted(filepath.Dir(fileName)) // 若帳號檔所在路徑不存在，則建立路徑
+
+	temporaryFileName := fileName + `.tmp` // 先寫入暫存檔再改名，避免寫到一半中斷造成帳號檔損毀
+
+	if returnError = ioutil.WriteFile(temporaryFileName, fileBytes, 0600); nil != returnError { // 若寫入暫存檔錯誤
+		return // 回傳
+	}
+
+	if returnError = os.Rename(temporaryFileName, fileName); nil != returnError { // 以暫存檔取代帳號檔
+		return // 回傳
+	}
+
+	accountFileRepositoryPointer.indexReadWriteLock.Lock() // 寫鎖
+
+	if indexedAccountRecord, ok := accountFileRepositoryPointer.accountRecordIndex[userID]; ok { // 同步更新帳號索引
+		indexedAccountRecord.PasswordHash = passwordHash
+		indexedAccountRecord.UserPassword = ``
+		accountFileRepositoryPointer.accountRecordIndex[userID] = indexedAccountRecord
+	}
+
+	accountFileRepositoryPointer.indexReadWriteLock.Unlock() // 解開寫鎖
+
+	return // 回傳
+}
+
+// accountSQLiteRepository - SQLite帳號儲存庫
+type accountSQLiteRepository struct {
+	tableOncePointer *sync.Once // 只建立一次資料表
+}
+
+const (
+	// 建立帳號資料表
+	accountSQLiteCreateTableString = `CREATE TABLE IF NOT EXISTS accounts (
+		user_id TEXT PRIMARY KEY,
+		user_password TEXT NOT NULL DEFAULT '',
+		password_hash TEXT NOT NULL DEFAULT '',
+		user_name TEXT NOT NULL DEFAULT '',
+		is_expert INTEGER NOT NULL DEFAULT 2,
+		is_frontline INTEGER NOT NULL DEFAULT 2,
+		role TEXT NOT NULL DEFAULT '',
+		area TEXT NOT NULL DEFAULT '[]',
+		pic_file TEXT NOT NULL DEFAULT '',
+		is_demo INTEGER NOT NULL DEFAULT 0
+	)`
+
+	// 舊版帳號資料表加入密碼雜湊欄位(已有欄位時會失敗，可忽略)
+	accountSQLiteAddPasswordHashColumnString = `ALTER TABLE accounts ADD COLUMN password_hash TEXT NOT NULL DEFAULT ''`
+
+	// 舊版帳號資料表加入角色欄位(已有欄位時會失敗，可忽略)
+	accountSQLiteAddRoleColumnString = `ALTER TABLE accounts ADD COLUMN role TEXT NOT NULL DEFAULT ''`
+
+	// 查詢帳號欄位
+	accountSQLiteSelectString = `SELECT user_id, user_password, password_hash, user_name, is_expert, is_frontline, role, area, pic_file, is_demo FROM accounts`
+
+	// 更新帳號密碼雜湊(同時清除舊版明碼密碼)
+	accountSQLiteUpdatePasswordHashString = `UPDATE accounts SET password_hash = ?, user_password = '' WHERE user_id = ?`
+)
+
+// getDatabase - 取得已建好帳號資料表的資料庫
+/**
+ * @return *sql.DB 資料庫指標
+ */
+func (accountSQLiteRepositoryPointer *accountSQLiteRepository) getDatabase() *sql.DB {
+
+	databasePointer := databases.GetSQLiteDatabaseOrPanic() // 取得資料庫
+
+	accountSQLiteRepositoryPointer.tableOncePointer.Do(func() {
+
+		_, execError := databasePointer.Exec(accountSQLiteCreateTableString) // 建立帳號資料表
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`建立帳號資料表 `},
+			[]interface{}{},
+			execError,
+		)
+
+		if nil != execError { // 若建立帳號資料表錯誤
+			logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+		}
+
+		databasePointer.Exec(accountSQLiteAddPasswordHashColumnString) // 舊版帳號資料表加入密碼雜湊欄位
+		databasePointer.Exec(accountSQLiteAddRoleColumnString)         // 舊版帳號資料表加入角色欄位
+
+	})
+
+	return databasePointer // 回傳資料庫指標
+}
+
+// scanAccountRecord - 讀出一列帳號儲存資料
+/**
+ * @param func(...interface{}) error scan 讀出函式
+ * @return accountRecord returnAccountRecord 帳號儲存資料
+ * @return error returnError 錯誤
+ */
+func scanAccountRecord(scan func(...interface{}) error) (returnAccountRecord accountRecord, returnError error) {
+
+	var areaString string // 場域代號(JSON陣列字串)
+
+	returnError = scan(
+		&returnAccountRecord.UserID,
+		&returnAccountRecord.UserPassword,
+		&returnAccountRecord.PasswordHash,
+		&returnAccountRecord.UserName,
+		&returnAccountRecord.IsExpert,
+		&returnAccountRecord.IsFrontline,
+		&returnAccountRecord.Role,
+		&areaString,
+		&returnAccountRecord.PicFile,
+		&returnAccountRecord.IsDemo,
+	)
+
+	if nil == returnError {
+		returnError = json.Unmarshal([]byte(areaString), &returnAccountRecord.Area)
+	}
+
+	return // 回傳
+}
+
+// loadAllAccountRecords - 載入所有帳號儲存資料
+/**
+ * @return []accountRecord returnAccountRecords 帳號儲存資料
+ * @return error returnError 錯誤
+ */
+func (accountSQLiteRepositoryPointer *accountSQLiteRepository) loadAllAccountRecords() (returnAccountRecords []accountRecord, returnError error) {
+
+	rowsPointer, returnError := accountSQLiteRepositoryPointer.getDatabase().Query(accountSQLiteSelectString) // 查詢所有帳號
+
+	if nil != returnError { // 若查詢錯誤
+		return // 回傳
+	}
+
+	defer rowsPointer.Close() // 記得關閉查詢結果
+
+	for rowsPointer.Next() { // 針對每一列
+
+		scannedAccountRecord, scanError := scanAccountRecord(rowsPointer.Scan) // 讀出帳號儲存資料
+
+		if nil != scanError { // 若讀出錯誤
+			returnError = scanError
+			return // 回傳
+		}
+
+		returnAccountRecords = append(returnAccountRecords, scannedAccountRecord)
+	}
+
+	returnError = rowsPointer.Err() // 回傳查詢過程的錯誤
+
+	return // 回傳
+}
+
+// findAccountRecord - 查找帳號儲存資料
+/**
+ * @param string userID 使用者登入帳號
+ * @return *accountRecord returnAccountRecordPointer 帳號儲存資料指標(找不到回傳nil)
+ * @return error returnError 錯誤
+ */
+func (accountSQLiteRepositoryPointer *accountSQLiteRepository) findAccountRecord(userID string) (returnAccountRecordPointer *accountRecord, returnError error) {
+
+	rowPointer := accountSQLiteRepositoryPointer.getDatabase().QueryRow(accountSQLiteSelectString+` WHERE user_id = ?`, userID) // 查詢帳號
+
+	scannedAccountRecord, returnError := scanAccountRecord(rowPointer.Scan) // 讀出帳號儲存資料
+
+	if errors.Is(returnError, sql.ErrNoRows) { // 若找不到帳號，不算錯誤
+		returnError = nil
+		return // 回傳
+	}
+
+	if nil == returnError {
+		returnAccountRecordPointer = &scannedAccountRecord
+	}
+
+	return // 回傳
+}
+
+// updatePasswordHash - 更新帳號密碼雜湊
+/**
+ * @param string userID 使用者登入帳號
+ * @param string passwordHash 密碼雜湊
+ * @return error returnError 錯誤
+ */
+func (accountSQLiteRepositoryPointer *accountSQLiteRepository) updatePasswordHash(userID string, passwordHash string) (returnError error) {
+
+	result, returnError := accountSQLiteRepositoryPointer.getDatabase().Exec(accountSQLiteUpdatePasswordHashString, passwordHash, userID) // 更新密碼雜湊
+
+	if nil != returnError { // 若更新錯誤
+		return // 回傳
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); 0 == rowsAffected { // 若找不到帳號
+		returnError = errors.New(`資料庫中找不到帳號 ` + userID)
+	}
+
+	return // 回傳
+}
+
+// newAccountRepositoryByConfig - 依設定檔建立帳號儲存庫
+/**
+ * @return accountRepository 帳號儲存庫
+ */
+func newAccountRepositoryByConfig() accountRepository {
+
+	storeType := configurations.GetConfigValueOrPanic(`account`, `store`) // 帳號儲存方式
+
+	switch storeType {
+
+	case `json`: // JSON帳號檔
+		return &accountFileRepository{
+			fileName:           configurations.GetConfigValueOrPanic(`account`, `json-file`),
+			fileWriteLock:      new(sync.Mutex),
+			indexReadWriteLock: new(sync.RWMutex),
+		}
+
+	case `yaml`: // YAML帳號檔
+		return &accountFileRepository{
+			fileName:           configurations.GetConfigValueOrPanic(`account`, `yaml-file`),
+			isYAML:             true,
+			fileWriteLock:      new(sync.Mutex),
+			indexReadWriteLock: new(sync.RWMutex),
+		}
+
+	case `sqlite`: // SQLite資料庫
+		return &accountSQLiteRepository{tableOncePointer: new(sync.Once)}
+
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`建立帳號儲存庫 %s `},
+		[]interface{}{storeType},
+		errors.New(`[ account ] store 應為 json、yaml 或 sqlite`),
+	)
+
+	logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+
+	return nil
+}
+
+// accountStore - 帳號快取(透過帳號儲存庫讀取，並保持同一帳號只有一個帳號指標)
+type accountStore struct {
+	readWriteLock     *sync.RWMutex       // 讀寫鎖
+	repository        accountRepository   // 帳號儲存庫
+	accountPointerMap map[string]*Account // 使用者登入帳號對應帳號指標
+}
+
+var (
+	accountStorePointer = &accountStore{
+		readWriteLock:     new(sync.RWMutex),
+		accountPointerMap: make(map[string]*Account),
+	} // 帳號快取(初始化網路中心時才依設定檔建立帳號儲存庫)
+
+	// 帳號同步間隔
+	accountSyncIntervalDuration time.Duration
+)
+
+// initializeAccountStore - 依設定檔建立帳號儲存庫與同步間隔
+func initializeAccountStore() {
+	accountStorePointer.repository = newAccountRepositoryByConfig()
+	accountSyncIntervalDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`account`, `sync-interval`)) * time.Second
+}
+
+// isAccountChanged - 判斷帳號基本資料是否不同
+/**
+ * @param *Account oldAccountPointer 原本的帳號指標
+ * @param *Account newAccountPointer 新的帳號指標
+ * @return bool 是否不同
+ */
+func isAccountChanged(oldAccountPointer *Account, newAccountPointer *Account) bool {
+	return oldAccountPointer.UserName != newAccountPointer.UserName ||
+		oldAccountPointer.IsExpert != newAccountPointer.IsExpert ||
+		oldAccountPointer.IsFrontline != newAccountPointer.IsFrontline ||
+		oldAccountPointer.Role != newAccountPointer.Role ||
+		fmt.Sprint(oldAccountPointer.Area) != fmt.Sprint(newAccountPointer.Area) ||
+		oldAccountPointer.Pic != newAccountPointer.Pic ||
+		oldAccountPointer.isDemo != newAccountPointer.isDemo
+}
+
+// mergeAccountRecord - 將帳號儲存資料合併到快取(需已上寫鎖)。帳號基本資料改變時以新的帳號指標取代，不修改連線使用中的帳號，新舊帳號共用密碼與驗證碼
+/**
+ * @param accountRecord newAccountRecord 帳號儲存資料
+ * @return *Account returnAccountPointer 快取中的帳號指標
+ * @return bool returnIsReplaced 是否取代了快取中原本的帳號指標
+ */
+func (accountStorePointer *accountStore) mergeAccountRecord(newAccountRecord accountRecord) (returnAccountPointer *Account, returnIsReplaced bool) {
+
+	returnAccountPointer = newAccountRecord.toAccount() // 新的帳號
+
+	accountPointer, ok := accountStorePointer.accountPointerMap[newAccountRecord.UserID]
+
+	if !ok { // 若快取中沒有此帳號，則加入
+		accountStorePointer.accountPointerMap[newAccountRecord.UserID] = returnAccountPointer
+		return // 回傳
+	}
+
+	// 更新帳號密碼雜湊(驗證碼與密碼分開保存，重新載入時保留驗證碼)
+	accountCredentialReadWriteLock.Lock() // 寫鎖
+	accountPointer.credentialPointer.passwordHash = newAccountRecord.PasswordHash
+	accountCredentialReadWriteLock.Unlock() // 解開寫鎖
+
+	returnAccountPointer.credentialPointer = accountPointer.credentialPointer // 新舊帳號共用密碼與驗證碼
+
+	if !isAccountChanged(accountPointer, returnAccountPointer) { // 基本資料沒變，沿用原本的帳號指標
+		returnAccountPointer = accountPointer
+		return // 回傳
+	}
+
+	accountStorePointer.accountPointerMap[newAccountRecord.UserID] = returnAccountPointer
+	returnIsReplaced = true
+
+	return // 回傳
+}
+
+// reload - 從帳號儲存庫重新載入所有帳號
+/**
+ * @return int returnCount 載入的帳號數
+ * @return map[string]*Account returnReplacedAccountPointerMap 基本資料改變的帳號(使用者登入帳號對應新的帳號指標)
+ * @return error returnError 錯誤
+ */
+func (accountStorePointer *accountStore) reload() (returnCount int, returnReplacedAccountPointerMap map[string]*Account, returnError error) {
+
+	accountRecords, returnError := accountStorePointer.repository.loadAllAccountRecords() // 載入所有帳號儲存資料
+
+	if nil != returnError { // 若載入錯誤，保留原本快取
+		return // 回傳
+	}
+
+	for index := range accountRecords { // 舊版明碼密碼轉為雜湊
+		migrateLegacyPassword(accountStorePointer.repository, &accountRecords[index])
+	}
+
+	accountStorePointer.readWriteLock.Lock()         // 寫鎖
+	defer accountStorePointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	loadedUserIDMap := make(map[string]bool) // 本次載入的帳號
+	returnReplacedAccountPointerMap = make(map[string]*Account)
+
+	for _, loadedAccountRecord := range accountRecords { // 針對每一筆帳號儲存資料
+
+		if accountPointer, isReplaced := accountStorePointer.mergeAccountRecord(loadedAccountRecord); isReplaced {
+			returnReplacedAccountPointerMap[loadedAccountRecord.UserID] = accountPointer
+		}
+
+		loadedUserIDMap[loadedAccountRecord.UserID] = true
+	}
+
+	for userID := range accountStorePointer.accountPointerMap { // 刪除儲存庫已移除的帳號(已登入的連線仍保有帳號指標)
+
+		if !loadedUserIDMap[userID] {
+			delete(accountStorePointer.accountPointerMap, userID)
+		}
+
+	}
+
+	returnCount = len(accountStorePointer.accountPointerMap)
+
+	return // 回傳
+}
+
+// getAccountCount - 取得快取中的帳號數
+/**
+ * @return int returnCount 帳號數
+ */
+func (accountStorePointer *accountStore) getAccountCount() (returnCount int) {
+	accountStorePointer.readWriteLock.RLock()                // 讀鎖
+	returnCount = len(accountStorePointer.accountPointerMap) // 帳號數
+	accountStorePointer.readWriteLock.RUnlock()              // 解開讀鎖
+	return                                                   // 回傳
+}
+
+// getAccount - 取得帳號(快取沒有時向帳號儲存庫查找)
+/**
+ * @param string userID 使用者登入帳號
+ * @return *Account returnAccountPointer 帳號指標(找不到回傳nil)
+ * @return error returnError 錯誤
+ */
+func (accountStorePointer *accountStore) getAccount(userID string) (returnAccountPointer *Account, returnError error) {
+
+	accountStorePointer.readWriteLock.RLock()                            // 讀鎖
+	returnAccountPointer = accountStorePointer.accountPointerMap[userID] // 從快取取得帳號
+	accountStorePointer.readWriteLock.RUnlock()                          // 解開讀鎖
+
+	if nil != returnAccountPointer { // 若快取中有此帳號
+		return // 回傳
+	}
+
+	accountRecordPointer, returnError := accountStorePointer.repository.findAccountRecord(userID) // 向帳號儲存庫查找
+
+	if nil != returnError || nil == accountRecordPointer { // 若查找錯誤或找不到帳號
+		return // 回傳
+	}
+
+	migrateLegacyPassword(accountStorePointer.repository, accountRecordPointer) // 舊版明碼密碼轉為雜湊
+
+	accountStorePointer.readWriteLock.Lock() // 寫鎖
+
+	if returnAccountPointer = accountStorePointer.accountPointerMap[userID]; nil == returnAccountPointer { // 查找期間沒有被其他連線加入快取，才加入快取
+		returnAccountPointer, _ = accountStorePointer.mergeAccountRecord(*accountRecordPointer)
+	}
+
+	accountStorePointer.readWriteLock.Unlock() // 解開寫鎖
+
+	return // 回傳
+}
+
+// findAccountPointer - 透過帳號快取取得帳號
+/**
+ * @param string userID 使用者登入帳號
+ * @return *Account 帳號指標(找不到回傳nil)
+ */
+func findAccountPointer(userID string) *Account {
+
+	accountPointer, getAccountError := accountStorePointer.getAccount(userID) // 取得帳號
+
+	if nil != getAccountError { // 若取得帳號錯誤，則記錄錯誤
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`從帳號儲存庫取得帳號 %s `},
+			[]interface{}{userID},
+			getAccountError,
+		)
+
+		logger.Errorf(formatString, args...) // 記錄錯誤
+	}
+
+	return accountPointer // 回傳帳號指標
+}
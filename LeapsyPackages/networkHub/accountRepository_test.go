@@ -0,0 +1,55 @@
+package networkHub
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestAccountRecordToAccountPic - 頭像檔讀不到時以空頭像載入帳號，不結束程式
+func TestAccountRecordToAccountPic(t *testing.T) {
+
+	directory, tempDirError := ioutil.TempDir(``, `accountPic`)
+
+	if nil != tempDirError {
+		t.Fatal(tempDirError)
+	}
+
+	defer os.RemoveAll(directory)
+
+	picFile := filepath.Join(directory, `pic.txt`)
+
+	if writeFileError := ioutil.WriteFile(picFile, []byte(`base64-pic`), 0600); nil != writeFileError {
+		t.Fatal(writeFileError)
+	}
+
+	testCases := []struct {
+		name    string
+		picFile string
+		wantPic string
+	}{
+		{`有頭像檔`, picFile, `base64-pic`},
+		{`頭像檔不存在`, filepath.Join(directory, `missing.txt`), ``},
+		{`未設定頭像檔`, ``, ``},
+	}
+
+	for _, testCase := range testCases {
+
+		t.Run(testCase.name, func(t *testing.T) {
+
+			accountPointer := (&accountRecord{UserID: `user@leapsy.com`, PicFile: testCase.picFile}).toAccount()
+
+			if nil == accountPointer || accountPointer.UserID != `user@leapsy.com` {
+				t.Fatalf(`帳號未載入: %+v`, accountPointer)
+			}
+
+			if testCase.wantPic != accountPointer.Pic {
+				t.Errorf(`頭像 = %q, 應為 %q`, accountPointer.Pic, testCase.wantPic)
+			}
+
+		})
+
+	}
+
+}
@@ -0,0 +1,404 @@
+package networkHub
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"../configurations"
+	"../databases"
+	"../logings"
+)
+
+// Area - 場域
+type Area struct {
+	AreaID       int    `json:"areaID"`       // 場域代號
+	AreaName     string `json:"areaName"`     // 場域名稱
+	Description  string `json:"description"`  // 場域說明
+	ParentAreaID int    `json:"parentAreaID"` // 上層場域代號(0為最上層，例如:廠區→樓層→產線)
+	IsActive     int    `json:"isActive"`     // 是否啟用:1是,2否
+}
+
+// areaRepository - 場域儲存庫
+type areaRepository interface {
+
+	// loadAllAreas - 載入所有場域
+	loadAllAreas() ([]Area, error)
+}
+
+// areaJSONFileRepository - JSON場域檔儲存庫
+type areaJSONFileRepository struct {
+	fileName string // 場域檔名
+}
+
+// loadAllAreas - 載入所有場域
+/**
+ * @return []Area returnAreas 所有場域
+ * @return error returnError 錯誤
+ */
+func (areaJSONFileRepositoryPointer *areaJSONFileRepository) loadAllAreas() (returnAreas []Area, returnError error) {
+
+	fileBytes, returnError := ioutil.ReadFile(areaJSONFileRepositoryPointer.fileName) // 讀取場域檔
+
+	if nil != returnError { // 若讀取場域檔錯誤
+		return // 回傳
+	}
+
+	returnError = json.Unmarshal(fileBytes, &returnAreas) // 解譯場域檔
+
+	return // 回傳
+}
+
+// areaSQLiteRepository - SQLite場域儲存庫
+type areaSQLiteRepository struct {
+	tableOncePointer *sync.Once // 只建立一次資料表
+}
+
+const (
+	// 建立場域資料表
+	areaSQLiteCreateTableString = `CREATE TABLE IF NOT EXISTS areas (
+		area_id INTEGER PRIMARY KEY,
+		area_name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		parent_area_id INTEGER NOT NULL DEFAULT 0,
+		is_active INTEGER NOT NULL DEFAULT 1
+	)`
+
+	// 查詢場域欄位
+	areaSQLiteSelectString = `SELECT area_id, area_name, description, parent_area_id, is_active FROM areas`
+)
+
+// getDatabase - 取得已建好場域資料表的資料庫
+/**
+ * @return *sql.DB 資料庫指標
+ */
+func (areaSQLiteRepositoryPointer *areaSQLiteRepository) getDatabase() *sql.DB {
+
+	databasePointer := databases.GetSQLiteDatabaseOrPanic() // 取得資料庫
+
+	areaSQLiteRepositoryPointer.tableOncePointer.Do(func() {
+
+		_, execError := databasePointer.Exec(areaSQLiteCreateTableString) // 建立場域資料表
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`建立場域資料表 `},
+			[]interface{}{},
+			execError,
+		)
+
+		if nil != execError { // 若建立場域資料表錯誤
+			logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+		}
+
+	})
+
+	return databasePointer // 回傳資料庫指標
+}
+
+// loadAllAreas - 載入所有場域
+/**
+ * @return []Area returnAreas 所有場域
+ * @return error returnError 錯誤
+ */
+func (areaSQLiteRepositoryPointer *areaSQLiteRepository) loadAllAreas() (returnAreas []Area, returnError error) {
+
+	rowsPointer, returnError := areaSQLiteRepositoryPointer.getDatabase().Query(areaSQLiteSelectString) // 查詢所有場域
+
+	if nil != returnError { // 若查詢錯誤
+		return // 回傳
+	}
+
+	defer rowsPointer.Close() // 記得關閉查詢結果
+
+	for rowsPointer.Next() { // 針對每一列
+
+		var scannedArea Area // 場域
+
+		returnError = rowsPointer.Scan(
+			&scannedArea.AreaID,
+			&scannedArea.AreaName,
+			&scannedArea.Description,
+			&scannedArea.ParentAreaID,
+			&scannedArea.IsActive,
+		)
+
+		if nil != returnError { // 若讀出錯誤
+			return // 回傳
+		}
+
+		returnAreas = append(returnAreas, scannedArea)
+	}
+
+	returnError = rowsPointer.Err() // 回傳查詢過程的錯誤
+
+	return // 回傳
+}
+
+// newAreaRepositoryByConfig - 依設定檔建立場域儲存庫
+/**
+ * @return areaRepository 場域儲存庫
+ */
+func newAreaRepositoryByConfig() areaRepository {
+
+	storeType := configurations.GetConfigValueOrPanic(`area`, `store`) // 場域儲存方式
+
+	switch storeType {
+
+	case `json`: // JSON場域檔
+		return &areaJSONFileRepository{fileName: configurations.GetConfigValueOrPanic(`area`, `json-file`)}
+
+	case `sqlite`: // SQLite資料庫
+		return &areaSQLiteRepository{tableOncePointer: new(sync.Once)}
+
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`建立場域儲存庫 %s `},
+		[]interface{}{storeType},
+		errors.New(`[ area ] store 應為 json 或 sqlite`),
+	)
+
+	logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+
+	return nil
+}
+
+var (
+	areaRepositoryValue areaRepository // 場域儲存庫
+
+	// 場域同步間隔
+	areaSyncIntervalDuration time.Duration
+
+	areaMapReadWriteLock = new(sync.RWMutex) // 場域對應表讀寫鎖
+
+	areaPointerMap = make(map[int]*Area) // 場域代號對應場域
+
+	areaChildrenMap = make(map[int][]int) // 場域代號對應下層場域代號
+)
+
+// initializeAreaRepository - 依設定檔建立場域儲存庫與同步間隔
+func initializeAreaRepository() {
+	areaRepositoryValue = newAreaRepositoryByConfig()
+	areaSyncIntervalDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`area`, `sync-interval`)) * time.Second
+}
+
+// syncAllAreas - 從場域儲存庫同步所有場域
+func syncAllAreas() {
+
+	areas, loadError := areaRepositoryValue.loadAllAreas() // 載入所有場域
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`從場域儲存庫同步場域 %d 筆 `},
+		[]interface{}{len(areas)},
+		loadError,
+	)
+
+	if nil != loadError { // 若載入錯誤，保留原本場域
+		logger.Errorf(formatString, args...) // 記錄錯誤
+		return                               // 回傳
+	}
+
+	newAreaPointerMap := make(map[int]*Area)
+	newAreaNumberNameMap := make(map[int]string)
+	newAreaChildrenMap := make(map[int][]int)
+
+	for index := range areas { // 針對每一個場域
+		areaPointer := &areas[index]
+		newAreaPointerMap[areaPointer.AreaID] = areaPointer
+		newAreaNumberNameMap[areaPointer.AreaID] = areaPointer.AreaName
+	}
+
+	for _, areaPointer := range newAreaPointerMap { // 建立上下層關係
+
+		if 0 == areaPointer.ParentAreaID {
+			continue
+		}
+
+		if _, ok := newAreaPointerMap[areaPointer.ParentAreaID]; !ok { // 上層場域不存在
+
+			// 取得記錄器格式字串與參數
+			warnFormatString, warnArgs := logings.GetLogFuncFormatAndArguments(
+				[]string{`場域 %d 的上層場域 %d 不存在，視為最上層場域 `},
+				[]interface{}{areaPointer.AreaID, areaPointer.ParentAreaID},
+				nil,
+			)
+
+			logger.Warnf(warnFormatString, warnArgs...) // 記錄警告
+			continue
+		}
+
+		newAreaChildrenMap[areaPointer.ParentAreaID] = append(newAreaChildrenMap[areaPointer.ParentAreaID], areaPointer.AreaID)
+	}
+
+	areaMapReadWriteLock.Lock() // 寫鎖
+	areaPointerMap = newAreaPointerMap
+	areaNumberNameMap = newAreaNumberNameMap
+	areaChildrenMap = newAreaChildrenMap
+	areaMapReadWriteLock.Unlock() // 解開寫鎖
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+}
+
+// getAreaName - 取得場域名稱
+/**
+ * @param int areaNumber 場域代號
+ * @return string returnAreaName 場域名稱
+ * @return bool returnIsFound 是否有此場域
+ */
+func getAreaName(areaNumber int) (returnAreaName string, returnIsFound bool) {
+	areaMapReadWriteLock.RLock()                                  // 讀鎖
+	returnAreaName, returnIsFound = areaNumberNameMap[areaNumber] // 取得場域名稱
+	areaMapReadWriteLock.RUnlock()                                // 解開讀鎖
+	return                                                        // 回傳
+}
+
+// getAreaNames - 依場域代號取得對應的場域名稱(找不到的場域名稱為空字串，與場域代號一一對應)
+/**
+ * @param []int area 場域代號
+ * @return []string returnAreaNames 場域名稱
+ */
+func getAreaNames(area []int) (returnAreaNames []string) {
+
+	returnAreaNames = []string{} // 回傳給客戶端時維持空陣列
+
+	for _, areaNumber := range area {
+		areaName, _ := getAreaName(areaNumber)
+		returnAreaNames = append(returnAreaNames, areaName)
+	}
+
+	return // 回傳
+}
+
+// isAreaActive - 判斷場域是否存在且啟用
+/**
+ * @param int areaNumber 場域代號
+ * @return bool 是否存在且啟用
+ */
+func isAreaActive(areaNumber int) bool {
+	areaMapReadWriteLock.RLock()         // 讀鎖
+	defer areaMapReadWriteLock.RUnlock() // 記得解開讀鎖
+	areaPointer, ok := areaPointerMap[areaNumber]
+	return ok && 1 == areaPointer.IsActive
+}
+
+// IsAreaActive - 判斷場域是否存在且啟用(供產生切換場域QRcode使用)
+/**
+ * @param int areaNumber 場域代號
+ * @return bool 是否存在且啟用
+ */
+func IsAreaActive(areaNumber int) bool {
+	return isAreaActive(areaNumber)
+}
+
+// getAreaCount - 取得場域數
+/**
+ * @return int returnCount 場域數
+ */
+func getAreaCount() (returnCount int) {
+	areaMapReadWriteLock.RLock()      // 讀鎖
+	returnCount = len(areaPointerMap) // 場域數
+	areaMapReadWriteLock.RUnlock()    // 解開讀鎖
+	return                            // 回傳
+}
+
+// getAreaWithDescendants - 取得場域與其所有下層場域的代號
+/**
+ * @param []int area 場域代號
+ * @return []int returnArea 場域與所有下層場域代號
+ */
+func getAreaWithDescendants(area []int) (returnArea []int) {
+
+	areaMapReadWriteLock.RLock()         // 讀鎖
+	defer areaMapReadWriteLock.RUnlock() // 記得解開讀鎖
+
+	visitedMap := make(map[int]bool)        // 已加入的場域(避免上下層設定成環)
+	pendingArea := append([]int{}, area...) // 待展開的場域
+
+	for len(pendingArea) > 0 {
+
+		areaNumber := pendingArea[0]
+		pendingArea = pendingArea[1:]
+
+		if visitedMap[areaNumber] {
+			continue
+		}
+
+		visitedMap[areaNumber] = true
+		returnArea = append(returnArea, areaNumber)
+		pendingArea = append(pendingArea, areaChildrenMap[areaNumber]...)
+	}
+
+	return // 回傳
+}
+
+// getParentAreaIDs - 取得場域的上層場域代號(不重複，不含已在場域中的代號)
+/**
+ * @param []int area 場域代號
+ * @return []int returnParentArea 上層場域代號
+ */
+func getParentAreaIDs(area []int) (returnParentArea []int) {
+
+	areaMapReadWriteLock.RLock()         // 讀鎖
+	defer areaMapReadWriteLock.RUnlock() // 記得解開讀鎖
+
+	includedMap := make(map[int]bool) // 已包含的場域
+	for _, areaNumber := range area {
+		includedMap[areaNumber] = true
+	}
+
+	for _, areaNumber := range area {
+
+		areaPointer, ok := areaPointerMap[areaNumber]
+
+		if !ok || 0 == areaPointer.ParentAreaID || includedMap[areaPointer.ParentAreaID] { // 最上層或已包含
+			continue
+		}
+
+		includedMap[areaPointer.ParentAreaID] = true
+		returnParentArea = append(returnParentArea, areaPointer.ParentAreaID)
+	}
+
+	return // 回傳
+}
+
+// MarshalJSON - 回傳給客戶端時，依場域代號帶出場域名稱
+/**
+ * @return []byte 轉換後的json
+ * @return error 錯誤
+ */
+func (account Account) MarshalJSON() ([]byte, error) {
+
+	type accountAlias Account // 避免遞迴呼叫MarshalJSON
+
+	return json.Marshal(struct {
+		accountAlias
+		AreaName []string `json:"areaName"` // 專家所屬場域名稱
+	}{
+		accountAlias: accountAlias(account),
+		AreaName:     getAreaNames(account.Area),
+	})
+}
+
+// MarshalJSON - 回傳給客戶端時，依場域代號帶出場域名稱
+/**
+ * @return []byte 轉換後的json
+ * @return error 錯誤
+ */
+func (device Device) MarshalJSON() ([]byte, error) {
+
+	type deviceAlias Device // 避免遞迴呼叫MarshalJSON
+
+	return json.Marshal(struct {
+		deviceAlias
+		AreaName []string `json:"areaName"` // 場域名稱
+	}{
+		deviceAlias: deviceAlias(device),
+		AreaName:    getAreaNames(device.Area),
+	})
+}
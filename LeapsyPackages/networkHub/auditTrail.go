@@ -0,0 +1,622 @@
+package networkHub
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"../configurations"
+	"../databases"
+	"../logings"
+	"../paths"
+)
+
+// 稽核事件
+const (
+	AuditEventLogin            = `login`             // 登入(含QRcode登入與恢復連線)
+	AuditEventLogout           = `logout`            // 登出
+	AuditEventLoginFailed      = `login_failed`      // 登入失敗(含退避或鎖定中被拒)
+	AuditEventVerificationMail = `verification_mail` // 寄送驗證信(成功或失敗)
+	AuditEventHelpRequested    = `help_requested`    // 求助
+	AuditEventHelpAnswered     = `help_answered`     // 回應求助(含自動指派、專家加入求助中的房間)
+	AuditEventHelpCancelled    = `help_cancelled`    // 取消求助
+	AuditEventHangUp           = `hang_up`           // 掛斷通話(含主管結束通話)
+	AuditEventAreaSwitched     = `area_switched`     // 切換場域
+	AuditEventTimeout          = `timeout`           // 逾時(連線逾時，含開始保留等待恢復連線；求助逾時)
+	AuditEventResumeExpired    = `resume_expired`    // 恢復連線保留期限已到(逾時時已記錄timeout，此時裝置才離線)
+)
+
+// AuditRecord - 稽核紀錄
+type AuditRecord struct {
+	Time        time.Time `json:"time"`                  // 發生時間
+	Event       string    `json:"event"`                 // 稽核事件
+	UserID      string    `json:"userID"`                // 執行者帳號
+	DeviceID    string    `json:"deviceID"`              // 執行者裝置ID
+	DeviceBrand string    `json:"deviceBrand"`           // 執行者裝置品牌
+	Area        []int     `json:"area"`                  // 執行者場域
+	RoomID      int       `json:"roomID"`                // 房號
+	Target      string    `json:"target,omitempty"`      // 對象(如回應求助時的求助者裝置關鍵字)
+	CallSeconds int       `json:"callSeconds,omitempty"` // 通話秒數(掛斷通話時才有)
+	Details     string    `json:"details,omitempty"`     // 說明
+}
+
+// AuditQuery - 稽核紀錄查詢條件(空值表示不篩選)
+type AuditQuery struct {
+	FromTime    time.Time // 起始時間(含)
+	ToTime      time.Time // 結束時間(不含)
+	UserID      string    // 執行者帳號
+	DeviceID    string    // 執行者裝置ID
+	DeviceBrand string    // 執行者裝置品牌
+	AreaNumber  int       // 場域代號(紀錄的場域包含此場域即符合)
+	Event       string    // 稽核事件
+	Limit       int       // 最多筆數(超過時只取最新的紀錄)
+}
+
+// isMatched - 判斷稽核紀錄是否符合查詢條件
+/**
+ * @param AuditRecord auditRecord 稽核紀錄
+ * @return bool 是否符合
+ */
+func (auditQuery AuditQuery) isMatched(auditRecord AuditRecord) bool {
+
+	switch {
+
+	case !auditQuery.FromTime.IsZero() && auditRecord.Time.Before(auditQuery.FromTime):
+		return false
+
+	case !auditQuery.ToTime.IsZero() && !auditRecord.Time.Before(auditQuery.ToTime):
+		return false
+
+	case `` != auditQuery.UserID && auditQuery.UserID != auditRecord.UserID:
+		return false
+
+	case `` != auditQuery.DeviceID && auditQuery.DeviceID != auditRecord.DeviceID:
+		return false
+
+	case `` != auditQuery.DeviceBrand && auditQuery.DeviceBrand != auditRecord.DeviceBrand:
+		return false
+
+	case `` != auditQuery.Event && auditQuery.Event != auditRecord.Event:
+		return false
+
+	case 0 != auditQuery.AreaNumber && !containsInt(auditRecord.Area, auditQuery.AreaNumber):
+		return false
+
+	}
+
+	return true
+}
+
+// containsInt - 判斷整數陣列是否包含某整數
+/**
+ * @param []int values 整數陣列
+ * @param int value 整數
+ * @return bool 是否包含
+ */
+func containsInt(values []int, value int) bool {
+
+	for _, element := range values {
+		if element == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// auditRepository - 稽核紀錄儲存庫(只能新增，不能修改或刪除)
+type auditRepository interface {
+
+	// appendAuditRecord - 新增稽核紀錄
+	appendAuditRecord(auditRecord AuditRecord) error
+
+	// queryAuditRecords - 依查詢條件取得稽核紀錄(依時間先後排序)
+	queryAuditRecords(auditQuery AuditQuery) ([]AuditRecord, error)
+}
+
+// auditJSONLinesFileRepository - JSON Lines稽核檔儲存庫(每筆稽核紀錄一行，只附加在檔尾)
+type auditJSONLinesFileRepository struct {
+	fileName      string        // 稽核檔名
+	readWriteLock *sync.RWMutex // 讀寫鎖(避免讀到寫一半的紀錄)
+}
+
+// appendAuditRecord - 新增稽核紀錄
+/**
+ * @param AuditRecord auditRecord 稽核紀錄
+ * @return error returnError 錯誤
+ */
+func (auditJSONLinesFileRepositoryPointer *auditJSONLinesFileRepository) appendAuditRecord(auditRecord AuditRecord) (returnError error) {
+
+	lineBytes, returnError := json.Marshal(auditRecord)
+
+	if nil != returnError { // 若轉換錯誤
+		return // 回傳
+	}
+
+	fileName := auditJSONLinesFileRepositoryPointer.fileName
+
+	paths.CreateIfPathNotExisted(filepath.Dir(fileName)) // 若稽核檔所在路徑不存在，則建立路徑
+
+	auditJSONLinesFileRepositoryPointer.readWriteLock.Lock()         // 寫鎖
+	defer auditJSONLinesFileRepositoryPointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	filePointer, returnError := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) // 以附加模式開啟稽核檔
+
+	if nil != returnError { // 若開啟錯誤
+		return // 回傳
+	}
+
+	if _, returnError = filePointer.Write(append(lineBytes, '\n')); nil != returnError { // 若寫入錯誤
+		filePointer.Close()
+		return // 回傳
+	}
+
+	returnError = filePointer.Close()
+
+	return // 回傳
+}
+
+// queryAuditRecords - 依查詢條件取得稽核紀錄
+/**
+ * @param AuditQuery auditQuery 查詢條件
+ * @return []AuditRecord returnAuditRecords 稽核紀錄(依時間先後排序)
+ * @return error returnError 錯誤
+ */
+func (auditJSONLinesFileRepositoryPointer *auditJSONLinesFileRepository) queryAuditRecords(auditQuery AuditQuery) (returnAuditRecords []AuditRecord, returnError error) {
+
+	auditJSONLinesFileRepositoryPointer.readWriteLock.RLock()         // 讀鎖
+	defer auditJSONLinesFileRepositoryPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	filePointer, returnError := os.Open(auditJSONLinesFileRepositoryPointer.fileName) // 開啟稽核檔
+
+	if os.IsNotExist(returnError) { // 若稽核檔不存在，視為尚無紀錄
+		returnError = nil
+		return // 回傳
+	}
+
+	if nil != returnError { // 若開啟錯誤
+		return // 回傳
+	}
+
+	defer filePointer.Close() // 記得關閉稽核檔
+
+	scannerPointer := bufio.NewScanner(filePointer)
+	scannerPointer.Buffer(make([]byte, 64*1024), 1024*1024) // 單筆紀錄最多1MB
+
+	for scannerPointer.Scan() { // 針對每一行
+
+		var auditRecord AuditRecord // 稽核紀錄
+
+		if unmarshalError := json.Unmarshal(scannerPointer.Bytes(), &auditRecord); nil != unmarshalError { // 略過損毀的行(如寫入時程式中斷)
+			continue
+		}
+
+		if !auditQuery.isMatched(auditRecord) {
+			continue
+		}
+
+		returnAuditRecords = append(returnAuditRecords, auditRecord)
+
+		if 0 < auditQuery.Limit && len(returnAuditRecords) > auditQuery.Limit { // 超過筆數只保留最新的紀錄
+			returnAuditRecords = returnAuditRecords[1:]
+		}
+
+	}
+
+	returnError = scannerPointer.Err() // 回傳讀取過程的錯誤
+
+	return // 回傳
+}
+
+// auditSQLiteRepository - SQLite稽核紀錄儲存庫
+type auditSQLiteRepository struct {
+	tableOncePointer *sync.Once // 只建立一次資料表
+}
+
+const (
+	// 建立稽核紀錄資料表(以觸發器拒絕修改與刪除，場域以前後加逗號的字串保存以便篩選)
+	auditSQLiteCreateTableString = `CREATE TABLE IF NOT EXISTS audit_records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		user_id TEXT NOT NULL DEFAULT '',
+		device_id TEXT NOT NULL DEFAULT '',
+		device_brand TEXT NOT NULL DEFAULT '',
+		area TEXT NOT NULL DEFAULT ',',
+		room_id INTEGER NOT NULL DEFAULT 0,
+		target TEXT NOT NULL DEFAULT '',
+		call_seconds INTEGER NOT NULL DEFAULT 0,
+		details TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS audit_records_time ON audit_records (time);
+	CREATE TRIGGER IF NOT EXISTS audit_records_no_update BEFORE UPDATE ON audit_records
+	BEGIN
+		SELECT RAISE(ABORT, 'audit records are append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_records_no_delete BEFORE DELETE ON audit_records
+	BEGIN
+		SELECT RAISE(ABORT, 'audit records are append-only');
+	END`
+
+	// 新增稽核紀錄
+	auditSQLiteInsertString = `INSERT INTO audit_records (time, event, user_id, device_id, device_brand, area, room_id, target, call_seconds, details)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// 查詢稽核紀錄欄位
+	auditSQLiteSelectString = `SELECT time, event, user_id, device_id, device_brand, area, room_id, target, call_seconds, details FROM audit_records`
+)
+
+// getDatabase - 取得已建好稽核紀錄資料表的資料庫
+/**
+ * @return *sql.DB 資料庫指標
+ */
+func (auditSQLiteRepositoryPointer *auditSQLiteRepository) getDatabase() *sql.DB {
+
+	databasePointer := databases.GetSQLiteDatabaseOrPanic() // 取得資料庫
+
+	auditSQLiteRepositoryPointer.tableOncePointer.Do(func() {
+
+		_, execError := databasePointer.Exec(auditSQLiteCreateTableString) // 建立稽核紀錄資料表
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`建立稽核紀錄資料表 `},
+			[]interface{}{},
+			execError,
+		)
+
+		if nil != execError { // 若建立稽核紀錄資料表錯誤
+			logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+		}
+
+	})
+
+	return databasePointer // 回傳資料庫指標
+}
+
+// getAuditAreaString - 將場域轉成前後加逗號的字串(如 [1 2] 轉成 ,1,2,)
+/**
+ * @param []int area 場域代號
+ * @return string 場域字串
+ */
+func getAuditAreaString(area []int) string {
+
+	areaStrings := make([]string, 0, len(area))
+
+	for _, areaNumber := range area {
+		areaStrings = append(areaStrings, strconv.Itoa(areaNumber))
+	}
+
+	return `,` + strings.Join(areaStrings, `,`) + `,`
+}
+
+// getAuditArea - 將前後加逗號的場域字串轉回場域
+/**
+ * @param string areaString 場域字串
+ * @return []int returnArea 場域代號
+ */
+func getAuditArea(areaString string) (returnArea []int) {
+
+	for _, areaNumberString := range strings.Split(strings.Trim(areaString, `,`), `,`) {
+		if areaNumber, strconvAtoiError := strconv.Atoi(areaNumberString); nil == strconvAtoiError {
+			returnArea = append(returnArea, areaNumber)
+		}
+	}
+
+	return // 回傳
+}
+
+// appendAuditRecord - 新增稽核紀錄
+/**
+ * @param AuditRecord auditRecord 稽核紀錄
+ * @return error returnError 錯誤
+ */
+func (auditSQLiteRepositoryPointer *auditSQLiteRepository) appendAuditRecord(auditRecord AuditRecord) (returnError error) {
+
+	_, returnError = auditSQLiteRepositoryPointer.getDatabase().Exec(
+		auditSQLiteInsertString,
+		auditRecord.Time.UnixNano(),
+		auditRecord.Event,
+		auditRecord.UserID,
+		auditRecord.DeviceID,
+		auditRecord.DeviceBrand,
+		getAuditAreaString(auditRecord.Area),
+		auditRecord.RoomID,
+		auditRecord.Target,
+		auditRecord.CallSeconds,
+		auditRecord.Details,
+	)
+
+	return // 回傳
+}
+
+// queryAuditRecords - 依查詢條件取得稽核紀錄
+/**
+ * @param AuditQuery auditQuery 查詢條件
+ * @return []AuditRecord returnAuditRecords 稽核紀錄(依時間先後排序)
+ * @return error returnError 錯誤
+ */
+func (auditSQLiteRepositoryPointer *auditSQLiteRepository) queryAuditRecords(auditQuery AuditQuery) (returnAuditRecords []AuditRecord, returnError error) {
+
+	conditions := []string{`1 = 1`} // 查詢條件
+	args := []interface{}{}         // 查詢參數
+
+	if !auditQuery.FromTime.IsZero() {
+		conditions = append(conditions, `time >= ?`)
+		args = append(args, auditQuery.FromTime.UnixNano())
+	}
+
+	if !auditQuery.ToTime.IsZero() {
+		conditions = append(conditions, `time < ?`)
+		args = append(args, auditQuery.ToTime.UnixNano())
+	}
+
+	for column, value := range map[string]string{
+		`user_id`:      auditQuery.UserID,
+		`device_id`:    auditQuery.DeviceID,
+		`device_brand`: auditQuery.DeviceBrand,
+		`event`:        auditQuery.Event,
+	} {
+		if `` != value {
+			conditions = append(conditions, column+` = ?`)
+			args = append(args, value)
+		}
+	}
+
+	if 0 != auditQuery.AreaNumber {
+		conditions = append(conditions, `area LIKE ?`)
+		args = append(args, `%,`+strconv.Itoa(auditQuery.AreaNumber)+`,%`)
+	}
+
+	queryString := auditSQLiteSelectString + ` WHERE ` + strings.Join(conditions, ` AND `) + ` ORDER BY id DESC` // 先取最新的紀錄
+
+	if 0 < auditQuery.Limit {
+		queryString += ` LIMIT ` + strconv.Itoa(auditQuery.Limit)
+	}
+
+	rowsPointer, returnError := auditSQLiteRepositoryPointer.getDatabase().Query(queryString, args...) // 查詢稽核紀錄
+
+	if nil != returnError { // 若查詢錯誤
+		return // 回傳
+	}
+
+	defer rowsPointer.Close() // 記得關閉查詢結果
+
+	for rowsPointer.Next() { // 針對每一列
+
+		var auditRecord AuditRecord // 稽核紀錄
+		var timeUnixNano int64      // 發生時間
+		var areaString string       // 場域字串
+
+		returnError = rowsPointer.Scan(
+			&timeUnixNano,
+			&auditRecord.Event,
+			&auditRecord.UserID,
+			&auditRecord.DeviceID,
+			&auditRecord.DeviceBrand,
+			&areaString,
+			&auditRecord.RoomID,
+			&auditRecord.Target,
+			&auditRecord.CallSeconds,
+			&auditRecord.Details,
+		)
+
+		if nil != returnError { // 若讀出錯誤
+			return // 回傳
+		}
+
+		auditRecord.Time = time.Unix(0, timeUnixNano)
+		auditRecord.Area = getAuditArea(areaString)
+
+		returnAuditRecords = append([]AuditRecord{auditRecord}, returnAuditRecords...) // 轉回時間先後排序
+	}
+
+	returnError = rowsPointer.Err() // 回傳查詢過程的錯誤
+
+	return // 回傳
+}
+
+// newAuditRepositoryByConfig - 依設定檔建立稽核紀錄儲存庫
+/**
+ * @return auditRepository 稽核紀錄儲存庫
+ */
+func newAuditRepositoryByConfig() auditRepository {
+
+	storeType := configurations.GetConfigValueOrPanic(`audit`, `store`) // 稽核紀錄儲存方式
+
+	switch storeType {
+
+	case `json`: // JSON Lines稽核檔
+		return &auditJSONLinesFileRepository{
+			fileName:      configurations.GetConfigValueOrPanic(`audit`, `json-file`),
+			readWriteLock: new(sync.RWMutex),
+		}
+
+	case `sqlite`: // SQLite資料庫
+		return &auditSQLiteRepository{tableOncePointer: new(sync.Once)}
+
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`建立稽核紀錄儲存庫 %s `},
+		[]interface{}{storeType},
+		errors.New(`[ audit ] store 應為 json 或 sqlite`),
+	)
+
+	logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+
+	return nil
+}
+
+var (
+	auditRepositoryValue auditRepository // 稽核紀錄儲存庫
+
+	auditQueryLimit int // 查詢稽核紀錄的筆數上限
+
+	auditRecordChannel chan AuditRecord // 稽核紀錄通道(依序寫入，容量同預設通道大小，已滿時捨棄並計入指標，不阻塞指令處理)
+
+	errAuditRecordChannelFull = errors.New(`稽核紀錄通道已滿，捨棄此筆稽核紀錄`)
+)
+
+// initializeAuditTrail - 依設定檔建立稽核紀錄儲存庫，並開始依序寫入稽核紀錄
+func initializeAuditTrail() {
+	auditRepositoryValue = newAuditRepositoryByConfig()
+	auditQueryLimit = configurations.GetConfigPositiveIntValueOrPanic(`audit`, `query-limit`)
+	auditRecordChannel = make(chan AuditRecord, channelSize)
+
+	go writeAuditRecords() // 依序寫入稽核紀錄
+}
+
+// writeAuditRecords - 從稽核紀錄通道依序寫入稽核紀錄儲存庫
+func writeAuditRecords() {
+
+	for auditRecord := range auditRecordChannel { // 針對每一筆稽核紀錄
+
+		appendError := auditRepositoryValue.appendAuditRecord(auditRecord) // 寫入稽核紀錄
+
+		if nil != appendError { // 若寫入錯誤
+
+			// 取得記錄器格式字串與參數
+			formatString, args := logings.GetLogFuncFormatAndArguments(
+				[]string{`寫入稽核紀錄 %s %s `},
+				[]interface{}{auditRecord.Event, auditRecord.UserID},
+				appendError,
+			)
+
+			logger.Errorf(formatString, args...) // 記錄錯誤
+		}
+
+	}
+
+}
+
+// recordAudit - 記錄稽核紀錄(未指定時間則為現在)。稽核紀錄通道已滿時捨棄此筆並記錄警告，不阻塞指令處理
+/**
+ * @param AuditRecord auditRecord 稽核紀錄
+ */
+func recordAudit(auditRecord AuditRecord) {
+
+	if auditRecord.Time.IsZero() {
+		auditRecord.Time = time.Now()
+	}
+
+	select {
+
+	case auditRecordChannel <- auditRecord: // 傳給稽核紀錄通道
+
+	default: // 稽核紀錄通道已滿(寫入儲存庫跟不上)
+
+		recordAuditDroppedMetrics() // 記錄捨棄筆數
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`記錄稽核紀錄 %s %s %s `},
+			[]interface{}{auditRecord.Event, auditRecord.UserID, auditRecord.Time.Format(time.RFC3339)},
+			errAuditRecordChannelFull,
+		)
+
+		logger.Warnf(formatString, args...) // 記錄警告
+
+	}
+
+}
+
+// newAuditRecord - 依帳號與裝置建立稽核紀錄(帳號或裝置可為空)
+/**
+ * @param string event 稽核事件
+ * @param *Account accountPointer 帳號指標
+ * @param *Device devicePointer 裝置指標
+ * @return AuditRecord returnAuditRecord 稽核紀錄
+ */
+func newAuditRecord(event string, accountPointer *Account, devicePointer *Device) (returnAuditRecord AuditRecord) {
+
+	returnAuditRecord.Event = event
+
+	if nil != accountPointer {
+		returnAuditRecord.UserID = accountPointer.UserID
+	}
+
+	if nil != devicePointer {
+		returnAuditRecord.DeviceID = devicePointer.DeviceID
+		returnAuditRecord.DeviceBrand = devicePointer.DeviceBrand
+		returnAuditRecord.Area = append([]int{}, devicePointer.Area...)
+		returnAuditRecord.RoomID = devicePointer.RoomID
+	}
+
+	return // 回傳
+}
+
+// newAuditRecordOfClient - 依連線目前的帳號與裝置建立稽核紀錄(連線可為空)
+/**
+ * @param string event 稽核事件
+ * @param *client clientPointer 連線指標
+ * @return AuditRecord 稽核紀錄
+ */
+func newAuditRecordOfClient(event string, clientPointer *client) AuditRecord {
+
+	var accountPointer *Account // 帳號指標
+	var devicePointer *Device   // 裝置指標
+
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok && nil != infoPointer {
+		accountPointer = infoPointer.AccountPointer
+		devicePointer = infoPointer.DevicePointer
+	}
+
+	return newAuditRecord(event, accountPointer, devicePointer)
+}
+
+// recordAuditOfClient - 依連線目前的帳號與裝置記錄稽核紀錄
+/**
+ * @param string event 稽核事件
+ * @param *client clientPointer 連線指標
+ * @param string target 對象
+ * @param string details 說明
+ */
+func recordAuditOfClient(event string, clientPointer *client, target string, details string) {
+
+	auditRecord := newAuditRecordOfClient(event, clientPointer)
+	auditRecord.Target = target
+	auditRecord.Details = details
+
+	recordAudit(auditRecord)
+}
+
+// recordAuditOfCommand - 依尚未登入連線的指令記錄稽核紀錄(如登入失敗、寄送驗證信)
+/**
+ * @param string event 稽核事件
+ * @param string userID 帳號(QRcode登入時指令的帳號欄位為權杖，需傳入解密後的帳號，未知則傳空字串)
+ * @param Command command 客戶端的指令
+ * @param string details 說明
+ */
+func recordAuditOfCommand(event string, userID string, command Command, details string) {
+	recordAudit(AuditRecord{
+		Event:       event,
+		UserID:      userID,
+		DeviceID:    command.DeviceID,
+		DeviceBrand: command.DeviceBrand,
+		Details:     details,
+	})
+}
+
+// QueryAuditRecords - 依查詢條件取得稽核紀錄(筆數上限依設定檔)
+/**
+ * @param AuditQuery auditQuery 查詢條件
+ * @return []AuditRecord 稽核紀錄(依時間先後排序)
+ * @return error 錯誤
+ */
+func QueryAuditRecords(auditQuery AuditQuery) ([]AuditRecord, error) {
+
+	if auditQuery.Limit <= 0 || auditQuery.Limit > auditQueryLimit {
+		auditQuery.Limit = auditQueryLimit
+	}
+
+	return auditRepositoryValue.queryAuditRecords(auditQuery)
+}
@@ -0,0 +1,414 @@
+package networkHub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"../logings"
+	"github.com/gobwas/ws"
+)
+
+// CommandHandler - 指令處理器(依指令代碼登記於指令登記表，由分派器經過中介層後呼叫)
+type CommandHandler interface {
+
+	// GetCommandNumber - 取得指令代碼
+	GetCommandNumber() int
+
+	// GetName - 取得指令名稱(記錄用)
+	GetName() string
+
+	// GetRequiredFields - 取得必填欄位
+	GetRequiredFields() []string
+
+	// IsLoginRequired - 是否需要先登入
+	IsLoginRequired() bool
+
+	// GetAllowedDeviceTypes - 取得允許的裝置類型(空陣列表示不限制)
+	GetAllowedDeviceTypes() []int
+
+	// IsHeartbeatRefreshed - 是否更新心跳包時間
+	IsHeartbeatRefreshed() bool
+
+	// Handle - 處理指令
+	Handle(commandContextPointer *CommandContext)
+}
+
+// CommandContext - 指令執行環境
+type CommandContext struct {
+	Command               Command        // 客戶端的指令
+	WhatKindCommandString string         // 指令名稱
+	Handler               CommandHandler // 指令處理器
+
+	clientPointer      *client        // 連線指標
+	commandTimeChannel chan time.Time // 連線逾時計算之通道
+}
+
+// GetInfoPointer - 取得此連線的登入資訊(未登入回傳nil)
+/**
+ * @return *Info 登入資訊
+ */
+func (commandContextPointer *CommandContext) GetInfoPointer() *Info {
+	return sessionRegistryPointer.getInfoPointer(commandContextPointer.clientPointer)
+}
+
+// Respond - 回應客戶端(結果名稱與訊息由結果代碼產生)
+/**
+ * @param int resultCode 結果代碼
+ * @param ...interface{} messageArguments 訊息範本參數
+ */
+func (commandContextPointer *CommandContext) Respond(resultCode int, messageArguments ...interface{}) {
+	command := commandContextPointer.Command
+	jsonBytes := getResponseJsonBytes(commandContextPointer.clientPointer.getLocale(), command.Command, resultCode, command.TransactionID, messageArguments...)
+	commandContextPointer.clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+}
+
+// CommandHandleFunc - 指令處理函式
+type CommandHandleFunc func(commandContextPointer *CommandContext)
+
+// CommandMiddleware - 指令中介層(包住下一層處理函式)
+type CommandMiddleware func(next CommandHandleFunc) CommandHandleFunc
+
+// commandHandlerStruct - 以函式實作的指令處理器
+type commandHandlerStruct struct {
+	commandNumber        int                                                       // 指令代碼
+	name                 string                                                    // 指令名稱
+	requiredFields       []string                                                  // 必填欄位
+	isLoginRequired      bool                                                      // 是否需要先登入
+	allowedDeviceTypes   []int                                                     // 允許的裝置類型
+	isHeartbeatRefreshed bool                                                      // 是否更新心跳包時間
+	handleFunc           func(clientPointer *client, command Command, name string) // 處理函式
+}
+
+// GetCommandNumber - 取得指令代碼
+func (commandHandlerPointer *commandHandlerStruct) GetCommandNumber() int {
+	return commandHandlerPointer.commandNumber
+}
+
+// GetName - 取得指令名稱
+func (commandHandlerPointer *commandHandlerStruct) GetName() string {
+	return commandHandlerPointer.name
+}
+
+// GetRequiredFields - 取得必填欄位
+func (commandHandlerPointer *commandHandlerStruct) GetRequiredFields() []string {
+	return commandHandlerPointer.requiredFields
+}
+
+// IsLoginRequired - 是否需要先登入
+func (commandHandlerPointer *commandHandlerStruct) IsLoginRequired() bool {
+	return commandHandlerPointer.isLoginRequired
+}
+
+// GetAllowedDeviceTypes - 取得允許的裝置類型
+func (commandHandlerPointer *commandHandlerStruct) GetAllowedDeviceTypes() []int {
+	return commandHandlerPointer.allowedDeviceTypes
+}
+
+// IsHeartbeatRefreshed - 是否更新心跳包時間
+func (commandHandlerPointer *commandHandlerStruct) IsHeartbeatRefreshed() bool {
+	return commandHandlerPointer.isHeartbeatRefreshed
+}
+
+// Handle - 處理指令
+func (commandHandlerPointer *commandHandlerStruct) Handle(commandContextPointer *CommandContext) {
+	commandHandlerPointer.handleFunc(commandContextPointer.clientPointer, commandContextPointer.Command, commandContextPointer.WhatKindCommandString)
+}
+
+var (
+	commandHandlerMapReadWriteLock = new(sync.RWMutex)            // 指令登記表讀寫鎖
+	commandHandlerMap              = make(map[int]CommandHandler) // 指令代碼對應指令處理器
+
+	commandMiddlewaresReadWriteLock = new(sync.RWMutex) // 自訂中介層讀寫鎖
+	commandMiddlewares              []CommandMiddleware // 自訂中介層(在內建中介層之後、指令處理器之前執行)
+)
+
+// RegisterCommandHandler - 登記指令處理器
+/**
+ * @param CommandHandler commandHandler 指令處理器
+ * @return error returnError 錯誤(指令代碼已被登記)
+ */
+func RegisterCommandHandler(commandHandler CommandHandler) (returnError error) {
+
+	if nil == commandHandler { // 若指令處理器為空
+		returnError = errors.New(`指令處理器不可為空`)
+		return // 回傳
+	}
+
+	commandHandlerMapReadWriteLock.Lock()         // 寫鎖
+	defer commandHandlerMapReadWriteLock.Unlock() // 記得解開寫鎖
+
+	commandNumber := commandHandler.GetCommandNumber()
+
+	if _, ok := commandHandlerMap[commandNumber]; ok { // 若指令代碼已被登記
+		returnError = errors.New(`指令代碼 ` + strconv.Itoa(commandNumber) + ` 已被登記`)
+		return // 回傳
+	}
+
+	commandHandlerMap[commandNumber] = commandHandler
+
+	return // 回傳
+}
+
+// UseCommandMiddleware - 加入自訂中介層
+/**
+ * @param CommandMiddleware commandMiddleware 自訂中介層
+ */
+func UseCommandMiddleware(commandMiddleware CommandMiddleware) {
+
+	if nil != commandMiddleware {
+		commandMiddlewaresReadWriteLock.Lock()                             // 寫鎖
+		commandMiddlewares = append(commandMiddlewares, commandMiddleware) // 加入自訂中介層
+		commandMiddlewaresReadWriteLock.Unlock()                           // 解開寫鎖
+	}
+
+}
+
+// registerCommandHandlerOrPanic - 登記內建指令處理器，失敗則結束程式
+/**
+ * @param CommandHandler commandHandler 指令處理器
+ */
+func registerCommandHandlerOrPanic(commandHandler CommandHandler) {
+
+	registerError := RegisterCommandHandler(commandHandler)
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`登記指令處理器 `},
+		[]interface{}{},
+		registerError,
+	)
+
+	if nil != registerError { // 若登記失敗
+		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+	}
+
+}
+
+// getCommandHandler - 取得指令處理器
+/**
+ * @param int commandNumber 指令代碼
+ * @return CommandHandler returnCommandHandler 指令處理器
+ * @return bool returnOK 是否有登記
+ */
+func getCommandHandler(commandNumber int) (returnCommandHandler CommandHandler, returnOK bool) {
+	commandHandlerMapReadWriteLock.RLock()                            // 讀鎖
+	returnCommandHandler, returnOK = commandHandlerMap[commandNumber] // 取得指令處理器
+	commandHandlerMapReadWriteLock.RUnlock()                          // 解開讀鎖
+	return                                                            // 回傳
+}
+
+// getRegisteredCommandNumbers - 取得所有已登記的指令代碼(記錄用)
+/**
+ * @return []int returnCommandNumbers 指令代碼
+ */
+func getRegisteredCommandNumbers() (returnCommandNumbers []int) {
+
+	commandHandlerMapReadWriteLock.RLock()         // 讀鎖
+	defer commandHandlerMapReadWriteLock.RUnlock() // 記得解開讀鎖
+
+	for commandNumber := range commandHandlerMap {
+		returnCommandNumbers = append(returnCommandNumbers, commandNumber)
+	}
+
+	sort.Ints(returnCommandNumbers)
+
+	return // 回傳
+}
+
+// recoveryCommandMiddleware - 中介層:攔截指令處理過程的panic，避免整條連線中斷
+func recoveryCommandMiddleware(next CommandHandleFunc) CommandHandleFunc {
+	return func(commandContextPointer *CommandContext) {
+
+		defer func() {
+
+			if recovered := recover(); nil != recovered {
+
+				details := fmt.Sprintf(`-執行指令發生錯誤:%v`, recovered)
+
+				// Response:失敗
+				commandContextPointer.Respond(ResultCodeInternalError)
+
+				// 錯誤logger
+				loggerFields := getLoggerFields(commandContextPointer.clientPointer) //取當下的記錄欄位
+				processLoggerErrorf(commandContextPointer.WhatKindCommandString, details, commandContextPointer.Command, loggerFields)
+			}
+
+		}()
+
+		next(commandContextPointer)
+	}
+}
+
+// authCommandMiddleware - 中介層:需登入的指令先檢查是否已登入
+func authCommandMiddleware(next CommandHandleFunc) CommandHandleFunc {
+	return func(commandContextPointer *CommandContext) {
+
+		if commandContextPointer.Handler.IsLoginRequired() &&
+			!checkLogedInAndResponseIfFail(commandContextPointer.clientPointer, commandContextPointer.Command, commandContextPointer.WhatKindCommandString) {
+			return // 尚未登入，已回應客戶端
+		}
+
+		next(commandContextPointer)
+	}
+}
+
+// deviceTypeCommandMiddleware - 中介層:檢查裝置類型是否允許使用此指令
+func deviceTypeCommandMiddleware(next CommandHandleFunc) CommandHandleFunc {
+	return func(commandContextPointer *CommandContext) {
+
+		allowedDeviceTypes := commandContextPointer.Handler.GetAllowedDeviceTypes()
+
+		if len(allowedDeviceTypes) > 0 { // 有限制裝置類型
+
+			infoPointer := commandContextPointer.GetInfoPointer()
+
+			if nil == infoPointer { // 找不到連線
+				processResponseInfoNil(commandContextPointer.clientPointer, commandContextPointer.WhatKindCommandString, commandContextPointer.Command, `-找不到連線`)
+				return // 回傳
+			}
+
+			if nil == infoPointer.DevicePointer { // 找不到裝置
+				processResponseDeviceNil(commandContextPointer.clientPointer, commandContextPointer.WhatKindCommandString, commandContextPointer.Command, `-找不到裝置`)
+				return // 回傳
+			}
+
+			isAllowed := false
+
+			for _, allowedDeviceType := range allowedDeviceTypes {
+				if allowedDeviceType == infoPointer.DevicePointer.DeviceType {
+					isAllowed = true
+					break
+				}
+			}
+
+			if !isAllowed { // 此裝置類型不可使用此指令
+
+				details := `-此裝置類型無法使用此指令,裝置類型=` + strconv.Itoa(infoPointer.DevicePointer.DeviceType)
+
+				// 失敗:Response
+				commandContextPointer.Respond(ResultCodeDeviceTypeNotAllowed)
+
+				// 警告logger
+				loggerFields := getLoggerFields(commandContextPointer.clientPointer) //取當下的記錄欄位
+				processLoggerWarnf(commandContextPointer.WhatKindCommandString, details, commandContextPointer.Command, loggerFields)
+
+				return // 回傳
+			}
+
+		}
+
+		next(commandContextPointer)
+	}
+}
+
+// validationCommandMiddleware - 中介層:檢查指令必填欄位是否齊全
+func validationCommandMiddleware(next CommandHandleFunc) CommandHandleFunc {
+	return func(commandContextPointer *CommandContext) {
+
+		requiredFields := commandContextPointer.Handler.GetRequiredFields()
+
+		if len(requiredFields) > 0 &&
+			!checkFieldsCompletedAndResponseIfFail(requiredFields, commandContextPointer.clientPointer, commandContextPointer.Command, commandContextPointer.WhatKindCommandString) {
+			return // 欄位不齊全，已回應客戶端
+		}
+
+		next(commandContextPointer)
+	}
+}
+
+// heartbeatCommandMiddleware - 中介層:當送來指令，更新心跳包通道時間
+func heartbeatCommandMiddleware(next CommandHandleFunc) CommandHandleFunc {
+	return func(commandContextPointer *CommandContext) {
+
+		if commandContextPointer.Handler.IsHeartbeatRefreshed() && nil != commandContextPointer.commandTimeChannel {
+			commandContextPointer.commandTimeChannel <- time.Now() // 更新心跳包通道時間
+		}
+
+		next(commandContextPointer)
+	}
+}
+
+// loggingCommandMiddleware - 中介層:記錄收到指令
+func loggingCommandMiddleware(next CommandHandleFunc) CommandHandleFunc {
+	return func(commandContextPointer *CommandContext) {
+
+		details := `-收到指令`
+
+		// 一般logger
+		loggerFields := getLoggerFields(commandContextPointer.clientPointer) //取當下的記錄欄位
+		processLoggerInfof(commandContextPointer.WhatKindCommandString, details, commandContextPointer.Command, loggerFields)
+
+		next(commandContextPointer)
+	}
+}
+
+// dispatchCommand - 分派指令:依指令代碼找到指令處理器，經過中介層後執行
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param chan time.Time commandTimeChannel 連線逾時計算之通道
+ */
+func dispatchCommand(clientPointer *client, command Command, commandTimeChannel chan time.Time) {
+
+	if `` != command.Locale { // 有指定語系則切換此連線的回應訊息語系(登入或任何指令皆可指定)
+		clientPointer.setLocale(command.Locale)
+	}
+
+	commandHandler, ok := getCommandHandler(command.Command)
+
+	if !ok { // 未登記的指令
+
+		whatKindCommandString := `未知指令`
+		details := `-執行失敗,無此指令,指令代碼=` + strconv.Itoa(command.Command) + `,可用指令代碼=` + strings.Trim(fmt.Sprint(getRegisteredCommandNumbers()), `[]`)
+
+		// 失敗:Response
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeUnknownCommand, command.TransactionID, command.Command)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// 警告logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+
+		return // 回傳
+	}
+
+	// 中介層順序:攔截panic → 登入 → 角色權限 → 裝置類型 → 欄位 → 心跳包 → 記錄 → 自訂中介層 → 指令處理器
+	handleFunc := CommandHandleFunc(commandHandler.Handle)
+
+	commandMiddlewaresReadWriteLock.RLock() // 讀鎖
+	for index := len(commandMiddlewares) - 1; index >= 0; index-- {
+		handleFunc = commandMiddlewares[index](handleFunc)
+	}
+	commandMiddlewaresReadWriteLock.RUnlock() // 解開讀鎖
+
+	builtInMiddlewares := []CommandMiddleware{
+		recoveryCommandMiddleware,
+		authCommandMiddleware,
+		permissionCommandMiddleware,
+		deviceTypeCommandMiddleware,
+		validationCommandMiddleware,
+		heartbeatCommandMiddleware,
+		loggingCommandMiddleware,
+	}
+
+	for index := len(builtInMiddlewares) - 1; index >= 0; index-- {
+		handleFunc = builtInMiddlewares[index](handleFunc)
+	}
+
+	startTime := time.Now() // 開始執行時間
+
+	handleFunc(&CommandContext{
+		Command:               command,
+		WhatKindCommandString: commandHandler.GetName(),
+		Handler:               commandHandler,
+		clientPointer:         clientPointer,
+		commandTimeChannel:    commandTimeChannel,
+	})
+
+	recordCommandMetrics(command.Command, time.Since(startTime)) // 記錄指令執行次數與執行時間
+}
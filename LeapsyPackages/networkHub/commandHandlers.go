@@ -0,0 +1,1982 @@
+package networkHub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"../jwts"
+	"github.com/gobwas/ws"
+)
+
+// 內建指令處理器(新增指令時，於此或其他檔案呼叫 RegisterCommandHandler 登記即可，不需修改 clientStruct.go)
+func init() {
+
+	// 登入(+廣播改變狀態)
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfLogin,
+		name:                 `登入`,
+		requiredFields:       []string{`userID`, `userPassword`, `deviceID`, `deviceBrand`},
+		isLoginRequired:      false,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleLoginCommand,
+	})
+
+	// 專家帳號要取得跟專家自己同場域的所有眼鏡裝置清單
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfGetGlassesInMyArea,
+		name:                 `專家帳號要取得跟專家自己同場域的所有眼鏡裝置清單`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleGetGlassesInMyAreaCommand,
+	})
+
+	// 取得空房號
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfGetEmptyRoomID,
+		name:                 `取得空房號`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleGetEmptyRoomIDCommand,
+	})
+
+	// 求助
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfAskForHelp,
+		name:                 `求助`,
+		requiredFields:       []string{`pic`, `roomID`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleAskForHelpCommand,
+	})
+
+	// 回應求助
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfAnswerHelp,
+		name:                 `回應求助`,
+		requiredFields:       []string{`deviceID`, `deviceBrand`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleAnswerHelpCommand,
+	})
+
+	// 變更 cam+mic 狀態
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfChangeCameraAndMicStatus,
+		name:                 `變更攝影機+麥克風狀態`,
+		requiredFields:       []string{`cameraStatus`, `micStatus`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleChangeCameraAndMicStatusCommand,
+	})
+
+	// 掛斷通話
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfHangUp,
+		name:                 `掛斷通話`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleHangUpCommand,
+	})
+
+	// 登出
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfLogout,
+		name:                 `登出`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: false,
+		handleFunc:           handleLogoutCommand,
+	})
+
+	// 心跳包
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfHeartbeat,
+		name:                 `心跳包`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleHeartbeatCommand,
+	})
+
+	// 加入房間(多方通話)
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfJoinRoom,
+		name:                 `加入房間`,
+		requiredFields:       []string{`roomID`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleJoinRoomCommand,
+	})
+
+	// 取得自己帳號資訊
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfGetMyAccount,
+		name:                 `取得自己帳號資訊`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleGetMyAccountCommand,
+	})
+
+	// 取得自己裝置資訊
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfGetMyDevice,
+		name:                 `取得自己裝置資訊`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleGetMyDeviceCommand,
+	})
+
+	// 判斷帳號是否存在，若存在則寄出驗證信
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfSendVerificationCode,
+		name:                 `判斷帳號是否存在，若存在寄出驗證信`,
+		requiredFields:       nil,
+		isLoginRequired:      false,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleSendVerificationCodeCommand,
+	})
+
+	// 取得現在同場域空閒專家人數
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfGetOnlineIdleExpertsCount,
+		name:                 `取得現在同場域空閒專家人數`,
+		requiredFields:       nil,
+		isLoginRequired:      false,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleGetOnlineIdleExpertsCountCommand,
+	})
+
+	// QRcode登入 (+廣播)
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfQRCodeLogin,
+		name:                 `QRcode登入`,
+		requiredFields:       []string{`userID`, `deviceID`, `deviceBrand`},
+		isLoginRequired:      false,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleQRCodeLoginCommand,
+	})
+
+	// 眼鏡切換場域
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfChangeArea,
+		name:                 `眼鏡切換場域`,
+		requiredFields:       []string{`area`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   []int{1},
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleChangeAreaCommand,
+	})
+
+	// 取消求助
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfCancelHelp,
+		name:                 `取消求助`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleCancelHelpCommand,
+	})
+
+	// 取得同場域房間清單
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfGetRoomsInMyArea,
+		name:                 `取得同場域房間清單`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleGetRoomsInMyAreaCommand,
+	})
+
+	// 離開房間
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfLeaveRoom,
+		name:                 `離開房間`,
+		requiredFields:       nil,
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleLeaveRoomCommand,
+	})
+
+	// 邀請加入房間
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfInviteToRoom,
+		name:                 `邀請加入房間`,
+		requiredFields:       []string{`deviceID`, `deviceBrand`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleInviteToRoomCommand,
+	})
+
+	// 變更密碼
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfChangePassword,
+		name:                 `變更密碼`,
+		requiredFields:       []string{`userPassword`, `newPassword`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleChangePasswordCommand,
+	})
+
+	// 結束通話(主管)
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfEndCall,
+		name:                 `結束通話`,
+		requiredFields:       []string{`roomID`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleEndCallCommand,
+	})
+}
+
+// handleLoginCommand - 處理<登入>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleLoginCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	remoteHost := getClientRemoteHost(clientPointer) // 來源位址
+
+	// 登入失敗過多:退避中或鎖定中
+	if throttleError := checkLoginAllowed(command.UserID, remoteHost); nil != throttleError {
+		details += `-拒絕登入,來源位址=` + remoteHost
+		recordAuditOfCommand(AuditEventLoginFailed, command.UserID, command, `拒絕登入,來源位址=`+remoteHost+`:`+throttleError.Error())
+		processResponseError(clientPointer, whatKindCommandString, command, details, throttleError)
+		return // 跳出
+	}
+
+	// 準備驗證密碼:拿ID+密碼去資料庫比對密碼，若正確則進行登入
+
+	// 若為demo模式，且為demo帳號，不驗證密碼，直接成功
+	// fmt.Println("測試中A！expertdemoMode＝", expertdemoMode)
+	// if (1 == expertdemoMode) &&
+	// 	("expertA@leapsyworld.com" == command.UserID ||
+	// 		"expertB@leapsyworld.com" == command.UserID ||
+	// 		"expertAB@leapsyworld.com" == command.UserID) {
+	// 	check = true
+	// 	// 取帳號指標
+	// 	accountPointer = getAccountByUserID(command.UserID)
+
+	// } else {
+	// 若為一般帳號，進行密碼驗證
+
+	// demo 帳號完全可以登入；專家帳號使用驗證信中的驗證碼(有效期限內且只能使用一次)，一線人員帳號使用密碼
+	accountPointer, checkError := checkPassword(command.UserID, command.UserPassword)
+
+	// }
+
+	// 驗證密碼成功:
+	if nil == checkError {
+
+		details += `-驗證密碼成功`
+
+		recordLoginSuccess(command.UserID) // 清除帳號登入失敗紀錄
+
+		if nil == accountPointer {
+
+			details += `-找不到帳號`
+
+			// Response：失敗
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeAccountNotFound, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// 警告logger
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+			return // 跳出
+		}
+
+		// 取得裝置Pointer
+		devicePointer := getDevice(command.DeviceID, command.DeviceBrand)
+
+		// 資料庫找不到此裝置
+		if devicePointer == nil {
+
+			details += `-找不裝置`
+
+			// Response：失敗
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeDeviceNotFound, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// 警告logger
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+			return // 跳出
+		}
+
+		// 進行裝置、帳號登入 (加入Map。包含處理裝置重複登入)
+		if otherMessage, loginError := processLoginWithDuplicate(whatKindCommandString, clientPointer, command, devicePointer, accountPointer); nil == loginError {
+			// 成功
+
+			details += `-登入成功,回應客戶端`
+
+			// Response:成功(附上恢復連線權杖)
+			jsonBytes := []byte(fmt.Sprintf(baseResponseJsonStringExtend+`,"resumeToken":"%s"}`, command.Command, CommandTypeNumberOfAPIResponse, ResultCodeSuccess, getResultName(ResultCodeSuccess), getResultMessage(clientPointer.getLocale(), ResultCodeSuccess), command.TransactionID, issueResumeToken(clientPointer)))
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// 一般logger(登入的狀態轉換掛勾已進行廣播)
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+		} else {
+			// 失敗
+
+			details += `-登入失敗:` + loginError.Error()
+
+			// Response：失敗
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, getErrorResultCode(loginError), command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// 警告logger
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerWarnf(whatKindCommandString, details+otherMessage, command, loggerFields)
+			return // 跳出
+		}
+
+	} else {
+		// logger:帳密錯誤
+
+		details += `-驗證密碼失敗-` + checkError.Error()
+
+		recordLoginFailure(command.UserID, remoteHost) // 記錄登入失敗(累計退避與鎖定)
+		recordAuditOfCommand(AuditEventLoginFailed, command.UserID, command, `驗證密碼失敗,來源位址=`+remoteHost+`:`+checkError.Error())
+
+		// Response：失敗(驗證碼過期或鎖定時帶回對應結果代碼)
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, getErrorResultCode(checkError), command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// 警告logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+		return // 跳出
+	}
+}
+
+// handleGetGlassesInMyAreaCommand - 處理<專家帳號要取得跟專家自己同場域的所有眼鏡裝置清單>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleGetGlassesInMyAreaCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 取得指定場域裝置清單
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok {
+
+		// 檢查裝置
+		if nil != infoPointer.DevicePointer {
+
+			//取得跟專家場域一樣的、眼鏡裝置、並排除自己的所有裝置info
+			infosInAreasExceptMineDevice, otherMessage := getDevicesWithInfoByAreaAndDeviceTypeExeptOneDevice(infoPointer.AccountPointer.Area, 1, infoPointer.DevicePointer) //待改
+
+			// Response:成功
+			// 此處json不直接轉成string,因為有 device Array型態，轉string不好轉
+			if jsonBytes, err := json.Marshal(InfosInTheSameAreaResponse{Command: 2, CommandType: 2, ResultCode: ResultCodeSuccess, ResultName: getResultName(ResultCodeSuccess), Results: getResultMessage(clientPointer.getLocale(), ResultCodeSuccess), TransactionID: command.TransactionID, Info: infosInAreasExceptMineDevice}); err == nil {
+
+				clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes} //Response
+
+				infoPointerString := getStringByInfoPointerArray(infosInAreasExceptMineDevice) // 將查詢結果轉成字串
+
+				// 一般logger
+				details += `-指令執行成功,取得清單為:` + infoPointerString + `,執行細節:` + otherMessage
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+			} else {
+
+				// logger
+				details += `-指令執行失敗,後端json轉換出錯,執行細節:` + otherMessage
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerErrorf(whatKindCommandString, details, command, loggerFields)
+				return // 跳出
+
+			}
+		} else {
+			// 找不到裝置
+			details += `-找不到裝置`
+
+			// Response:失敗
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeDeviceNotFound, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// 警告logger
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+			return // 跳出
+
+		}
+	} else {
+		// 尚未建立連線
+		details += `-執行失敗，尚未建立連線`
+
+		// Response:失敗
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeInfoNotFound, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+
+		return // 跳出
+	}
+}
+
+// handleGetEmptyRoomIDCommand - 處理<取得空房號>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleGetEmptyRoomIDCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 建立房間並配發房號
+	room, createError := roomManagerPointer.createRoom(sessionRegistryPointer.getInfoPointer(clientPointer))
+
+	if nil != createError {
+
+		details += `-執行失敗:無法配發房號,錯誤訊息:` + createError.Error()
+
+		// Response:失敗
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeRoomIDUnavailable, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerErrorf(whatKindCommandString, details, command, loggerFields)
+		return // 跳出
+	}
+
+	// Response:成功
+	jsonBytes := []byte(fmt.Sprintf(baseResponseJsonStringExtend+`, "roomID":%d}`, command.Command, CommandTypeNumberOfAPIResponse, ResultCodeSuccess, getResultName(ResultCodeSuccess), getResultMessage(clientPointer.getLocale(), ResultCodeSuccess), command.TransactionID, room.RoomID))
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+	// logger
+	details += `-指令執行成功,取得房號為` + strconv.Itoa(room.RoomID)
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+}
+
+// handleAskForHelpCommand - 處理<求助>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleAskForHelpCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 設定Pic, RoomID, 裝置狀態
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok {
+
+		devicePointer := infoPointer.DevicePointer
+
+		if nil != devicePointer {
+
+			// 檢核:房間需開啟
+			room, isRoomOpen := roomManagerPointer.getOpenRoom(command.RoomID)
+
+			if !isRoomOpen {
+				details += `-執行失敗:房間不存在或已關閉`
+				processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeRoomNotOpen)
+				return // 跳出
+			}
+
+			deviceKey := getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)
+			isAlreadyInRoom := containsString(room.Participants, deviceKey) // 已在此房間內(再次求助)，失敗時不可移出
+			area := getSessionIndexKeys(infoPointer).area                   // 求助者所屬場域
+
+			if room.CreatorDeviceID == devicePointer.DeviceID && room.CreatorDeviceBrand == devicePointer.DeviceBrand { // 自己建立的房間(建立後切換場域也可在此房間求助)
+				area = room.Area
+			}
+
+			// 檢核並保留座位:房間需開啟、未滿，且是自己建立的、同場域或受邀請
+			if joinError := roomManagerPointer.tryJoinRoom(command.RoomID, deviceKey, area); nil != joinError {
+				details += `-執行失敗:` + joinError.Error()
+				processResponseFail(clientPointer, whatKindCommandString, command, details, getErrorResultCode(joinError))
+				return // 跳出
+			}
+
+			// 設備狀態:求助中(通話中不可求助，檢核後狀態已改變則釋放保留的座位)
+			if transitionError := changeDeviceStatus(clientPointer, devicePointer, deviceStatusEventAskForHelp, func() {
+				devicePointer.Pic = command.Pic       // 求助截圖
+				devicePointer.RoomID = command.RoomID // 求助房號
+			}, `優先順序=`+strconv.Itoa(command.Priority)); nil != transitionError {
+
+				if !isAlreadyInRoom {
+					roomManagerPointer.leaveRoom(command.RoomID, deviceKey)
+				}
+
+				processResponseDeviceStatusNotAllowed(clientPointer, whatKindCommandString, command, details, transitionError)
+				return // 跳出
+			}
+
+			sessionRegistryPointer.reindexClient(clientPointer) // 房號已改變，更新索引
+
+			enqueueHelpRequest(infoPointer, command.Priority, command.TransactionID) // 加入求助佇列
+
+			// Response:成功
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// logger
+			details += `-指令執行成功,優先順序=` + strconv.Itoa(command.Priority)
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+			pushHelpQueuePositions()                                                     // 通知排隊位置
+			processAutoAssignHelpRequests(whatKindCommandString, command, clientPointer) // 自動指派給閒置最久的專家
+
+		} else {
+			processResponseDeviceNil(clientPointer, whatKindCommandString, command, ``)
+			return
+		}
+
+	} else {
+		// Response:失敗
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, ``)
+		return
+	}
+}
+
+// handleAnswerHelpCommand - 處理<回應求助>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleAnswerHelpCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 準備設定-求助者狀態
+
+	// (求助者)裝置
+	askerDevicePointer := getDevice(command.DeviceID, command.DeviceBrand)
+
+	// 檢查有此裝置
+	if askerDevicePointer != nil {
+
+		details += `-找到(求助者)裝置`
+
+		// 準備設定-回應者設備狀態+房間(自己)
+
+		// (回應者)info
+		giverInfoPointer := sessionRegistryPointer.getInfoPointer(clientPointer)
+		if nil != giverInfoPointer {
+			details += `-找到(回應者)連線Info`
+
+			// (回應者)裝置
+			giverDeivcePointer := giverInfoPointer.DevicePointer
+			if nil != giverDeivcePointer {
+				details += `-找到(回應者)裝置ID=` + giverDeivcePointer.DeviceID + `,(回應者)裝置Brand=` + giverDeivcePointer.DeviceBrand
+
+				// 檢核:求助者需為求助中，回應者需為閒置
+				if transitionError := checkDeviceStatusTransition(askerDevicePointer, deviceStatusEventHelpAnswered); nil != transitionError {
+					details += `-(求助者)`
+					processResponseDeviceStatusNotAllowed(clientPointer, whatKindCommandString, command, details, transitionError)
+					return // 跳出
+				}
+
+				if transitionError := checkDeviceStatusTransition(giverDeivcePointer, deviceStatusEventAnswerHelp); nil != transitionError {
+					details += `-(回應者)`
+					processResponseDeviceStatusNotAllowed(clientPointer, whatKindCommandString, command, details, transitionError)
+					return // 跳出
+				}
+
+				// 認領求助並設定:求助者與回應者設備狀態+房間(同一求助只有一位專家能認領成功，指派失敗則放回佇列)
+				if claimError := claimHelpRequestForGiver(clientPointer, giverInfoPointer, askerDevicePointer); nil != claimError {
+					processResponseError(clientPointer, whatKindCommandString, command, details, claimError)
+					return // 跳出
+				}
+
+				pushHelpQueuePositions() // 佇列已改變，通知其他排隊者
+
+				// Response：成功
+				jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+				clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+				// logger
+				details += `-指令執行成功` +
+					`,(回應者)房號=` + strconv.Itoa(giverDeivcePointer.RoomID) +
+					`,(回應者)裝置狀態DeviceStatus=` + strconv.Itoa(int(giverDeivcePointer.DeviceStatus)) +
+					`,(求助者)房號=` + strconv.Itoa(askerDevicePointer.RoomID) +
+					`,(求助者)裝置狀態DeviceStatus=` + strconv.Itoa(int(askerDevicePointer.DeviceStatus)) +
+					`,(求助者)裝置ID=` + askerDevicePointer.DeviceID +
+					`,(求助者)裝置Brand=` + askerDevicePointer.DeviceBrand
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+			} else {
+				details += `-(回應者)裝置不存在`
+				processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+				return // 跳出
+			}
+
+		} else {
+			details += `-(回應者)連線Info不存在`
+			processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+			return // 跳出
+
+		}
+
+	} else {
+		details += `-(求助者)裝置不存在`
+		processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+}
+
+// handleChangeCameraAndMicStatusCommand - 處理<變更攝影機+麥克風狀態>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleChangeCameraAndMicStatusCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 設定攝影機、麥克風
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok {
+
+		devicePointer := infoPointer.DevicePointer // 取裝置
+		if nil != devicePointer {
+
+			details += `-找到裝置`
+
+			applyDeviceChange(func() {
+				devicePointer.CameraStatus = command.CameraStatus // 攝影機
+				devicePointer.MicStatus = command.MicStatus       // 麥克風
+			})
+
+			device := getDeviceCopy(devicePointer) // 變更後的裝置(記錄與廣播用)
+
+			// Response:成功
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// logger
+			details += `-指令執行成功,變更裝置ID=` + device.DeviceID + `,裝置品牌=` + device.DeviceBrand + `,攝影機狀態改為=` + strconv.Itoa(device.CameraStatus) + `,麥克風狀態改為=` + strconv.Itoa(device.MicStatus)
+
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+			// 準備廣播:包成Array:放入 Response Devices
+			deviceArray := getArrayPointer(&device)
+
+			messages := processBroadcastingDeviceChangeStatusInRoom(whatKindCommandString, command, clientPointer, deviceArray, details)
+
+			// logger
+			details += `-進行(房間)廣播,詳細訊息:` + messages
+			loggerFields = getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+		} else {
+			//找不到裝置
+			details += `-找不到裝置`
+			processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+			return
+		}
+
+	} else {
+		// 找不到要求端連線info
+		details += `-找不到要求端連線info`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return
+	}
+}
+
+// handleHangUpCommand - 處理<掛斷通話>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleHangUpCommand(clientPointer *client, command Command, whatKindCommandString string) {
+	processLeaveRoom(clientPointer, command, whatKindCommandString, true) // 一線人員掛斷時結束整個通話
+}
+
+// handleLeaveRoomCommand - 處理<離開房間>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleLeaveRoomCommand(clientPointer *client, command Command, whatKindCommandString string) {
+	processLeaveRoom(clientPointer, command, whatKindCommandString, false) // 只有自己離開
+}
+
+// processLeaveRoom - 依角色處理離開房間(掛斷通話與離開房間共用)
+/**
+ * 自己離開後(一線人員與專家依帳號角色判斷，主管與管理者視為專家，強制靜音的旁聽角色兩者皆不是):
+ * 1. 一線人員掛斷，或房間內已沒有一線人員:結束整個通話，所有人離開房間
+ * 2. 離開的是最後一位專家:一線人員回到求助中，留在房間等待其他專家
+ * 3. 其他情況(包括旁聽者離開):通話繼續，其他人狀態不變
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ * @param bool isHangUp 是否為掛斷通話
+ */
+func processLeaveRoom(clientPointer *client, command Command, whatKindCommandString string, isHangUp bool) {
+
+	details := `-收到指令`
+
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer) // 取info
+
+	// 找不到要求端連線info
+	if nil == infoPointer {
+		details += `-找不到要求端連線info`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return
+	}
+
+	devicePointer := infoPointer.DevicePointer   // 取device
+	accountPointer := infoPointer.AccountPointer // 取account
+
+	// 找不到裝置
+	if nil == devicePointer {
+		details += `-找不到裝置`
+		processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+		return
+	}
+
+	// 找不到帳號
+	if nil == accountPointer {
+		details += `-找不到帳號`
+		processResponseAccountNil(clientPointer, whatKindCommandString, command, details)
+		return
+	}
+
+	details += `-找到裝置ID=` + devicePointer.DeviceID + `,裝置Brand=` + devicePointer.DeviceBrand + `,帳號userID=` + accountPointer.UserID
+
+	thisRoomID := devicePointer.RoomID
+
+	// 離開房間時必須在房間中(掛斷通話只檢核設備狀態)
+	if 0 == thisRoomID && !isHangUp {
+		details += `-執行失敗:不在任何房間中`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeNotInRoom)
+		return
+	}
+
+	// 檢核:需為求助中或通話中
+	if transitionError := checkDeviceStatusTransition(devicePointer, deviceStatusEventLeaveCall); nil != transitionError {
+		processResponseDeviceStatusNotAllowed(clientPointer, whatKindCommandString, command, details, transitionError)
+		return
+	}
+
+	// 其他同房間的連線info
+	otherInfoPointers := getOtherInfosInTheSameRoom(thisRoomID, clientPointer)
+
+	// 計算自己離開後，房間內剩下的一線人員與專家人數
+	remainingFrontlineCount := 0
+	remainingExpertCount := 0
+
+	for _, otherInfoPointer := range otherInfoPointers {
+
+		if isFrontlineInCall(otherInfoPointer) {
+			remainingFrontlineCount++
+		}
+
+		if isExpertInCall(otherInfoPointer) {
+			remainingExpertCount++
+		}
+
+	}
+
+	isFrontline := isFrontlineInCall(infoPointer)
+	isCallEnded := (isHangUp && isFrontline) || 0 == remainingFrontlineCount        // 一線人員掛斷，或房間內已沒有一線人員
+	isLastExpertLeaving := isExpertInCall(infoPointer) && 0 == remainingExpertCount // 離開的是最後一位專家
+
+	// 自己 離開通話並離開房間(狀態不允許則房號也不改變)
+	if transitionError := changeDeviceStatus(clientPointer, devicePointer, deviceStatusEventLeaveCall, func() {
+		devicePointer.CameraStatus = 0 // 關閉
+		devicePointer.MicStatus = 0    // 關閉
+		devicePointer.RoomID = 0       // 沒有房間
+
+		if isFrontline {
+			devicePointer.Pic = "" //清空
+		}
+	}, ``); nil != transitionError {
+		processResponseDeviceStatusNotAllowed(clientPointer, whatKindCommandString, command, details, transitionError)
+		return
+	}
+
+	// 其他人 依角色改變狀態
+	leftDevicePointers := []*Device{} // 一併離開房間的其他裝置
+
+	for _, otherInfoPointer := range otherInfoPointers {
+
+		dPointer := otherInfoPointer.DevicePointer
+
+		var transitionError error // 狀態轉換錯誤
+
+		if isCallEnded {
+			// 結束整個通話:所有人閒置並離開房間
+			if transitionError = changeDeviceStatus(clientPointer, dPointer, deviceStatusEventLeaveCall, func() {
+				dPointer.CameraStatus = 0 // 關閉
+				dPointer.MicStatus = 0    // 關閉
+				dPointer.RoomID = 0       // 沒有房間
+			}, ``); nil == transitionError {
+				leftDevicePointers = append(leftDevicePointers, dPointer)
+			}
+
+		} else if isLastExpertLeaving && isFrontlineInCall(otherInfoPointer) {
+			// 沒有專家了:通話中的一線人員 求助中(房間不變)
+			if transitionError = changeDeviceStatus(clientPointer, dPointer, deviceStatusEventWaitForExpert, func() {
+				dPointer.CameraStatus = 0 // 關閉
+				dPointer.MicStatus = 0    // 關閉
+			}, ``); nil == transitionError {
+				enqueueHelpRequest(otherInfoPointer, 0, ``) // 重新排隊等待其他專家
+			}
+		}
+
+		if nil != transitionError {
+			// 狀態與房號皆不改變
+			otherDetails := details + `-其他裝置ID=` + dPointer.DeviceID + `,裝置Brand=` + dPointer.DeviceBrand + `,狀態未改變:` + transitionError.Error()
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerWarnf(whatKindCommandString, otherDetails, command, loggerFields)
+		}
+	}
+
+	// 掛斷通話:趁房間還在(尚未更新索引)時計算通話秒數並記錄稽核紀錄
+	if isHangUp {
+
+		auditRecord := newAuditRecord(AuditEventHangUp, accountPointer, devicePointer)
+		auditRecord.RoomID = thisRoomID
+
+		if room, ok := roomManagerPointer.getOpenRoom(thisRoomID); ok && !room.callStartedTime.IsZero() {
+			auditRecord.CallSeconds = int(time.Since(room.callStartedTime).Seconds())
+		}
+
+		if isCallEnded {
+			auditRecord.Details = `結束整個通話`
+		}
+
+		recordAudit(auditRecord)
+	}
+
+	// 房號已改變，全部轉換後才更新索引(更新索引時房間管理器會移除參與者，最後一位參與者離開時關閉房間)
+	sessionRegistryPointer.reindexClient(clientPointer)
+
+	for _, dPointer := range leftDevicePointers {
+		sessionRegistryPointer.reindexDevicePointer(dPointer)
+	}
+
+	// Response:成功
+	jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+	// logger:執行成功(狀態轉換掛勾已進行房間與區域廣播)
+	details += `-指令執行成功,從房號=` + strconv.Itoa(thisRoomID) + `中退出,剩下一線人員` + strconv.Itoa(remainingFrontlineCount) + `人,專家` + strconv.Itoa(remainingExpertCount) + `人`
+	if isCallEnded {
+		details += `,結束整個通話`
+	}
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+	pushHelpQueuePositions()                                                     // 佇列可能已改變，通知排隊位置
+	processAutoAssignHelpRequests(whatKindCommandString, command, clientPointer) // 可能有專家變閒置，自動指派求助
+}
+
+// handleLogoutCommand - 處理<登出>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleLogoutCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 準備設定登出者
+
+	// 取出連線info
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer)
+	if nil != infoPointer {
+		details += `-找到要求端連線資訊Info`
+
+		// 取出裝置
+		devicePointer := infoPointer.DevicePointer
+		if nil != devicePointer {
+			details += `-找到裝置,裝置ID=` + devicePointer.DeviceID + `,裝置Brand=` + devicePointer.DeviceBrand
+
+			recordAuditOfClient(AuditEventLogout, clientPointer, ``, ``) // 稽核紀錄:登出(趁裝置尚未離線，保留房號與場域)
+
+			// 重設裝置為預設離線狀態(狀態轉換掛勾會廣播)
+			_, message := setDevicePointerOffline(clientPointer, devicePointer)
+			details += `-設置裝置為離線狀態` + message
+
+			// Response:成功
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// logger
+			details += `-指令執行成功，連線已登出`
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+			// 移除連線
+			// 帳號包在連線登入資訊裡面,會一併進行清空
+			revokeResumeToken(clientPointer)                   // 恢復連線權杖失效
+			sessionRegistryPointer.deleteClient(clientPointer) //刪除
+			disconnectHub(clientPointer)                       //斷線
+
+		} else {
+			details += `-找不到裝置`
+			processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+		}
+
+	} else {
+		details += `-找不到要求端連線Info`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+	}
+}
+
+// handleHeartbeatCommand - 處理<心跳包>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleHeartbeatCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 成功:Response
+	jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+	// logger
+	details += `-指令執行成功`
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+}
+
+// handleGetMyAccountCommand - 處理<取得自己帳號資訊>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleGetMyAccountCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 準備隱匿密碼
+
+	// 取出連線info
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok {
+		details += `-找到要求端連線info`
+
+		//取出帳號
+		accountPointer := infoPointer.AccountPointer
+		if nil != accountPointer {
+			details += `-找到自己帳號資訊,userID=` + accountPointer.UserID
+
+			accountNoPassword := *(accountPointer) //取帳號copy複本(密碼雜湊與驗證碼不回傳給client)
+
+			details += `-指令執行成功`
+			// Response:成功 (此處仍使用Marshal工具轉型，因考量有 物件Account{}形態，轉成string較為複雜。)
+			if jsonBytes, err := json.Marshal(MyAccountResponse{
+				Command:       command.Command,
+				CommandType:   CommandTypeNumberOfAPIResponse,
+				ResultCode:    ResultCodeSuccess,
+				ResultName:    getResultName(ResultCodeSuccess),
+				Results:       getResultMessage(clientPointer.getLocale(), ResultCodeSuccess),
+				TransactionID: command.TransactionID,
+				Account:       accountNoPassword}); err == nil {
+
+				// fmt.Printf("測試accountNoPassword=%+v", clientInfoMap[clientPointer].AccountPointer)
+				// Response(場域、排除個人)
+				clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+				// logger
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+			} else {
+				details += `-後端json轉換出錯`
+
+				// logger
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerErrorf(whatKindCommandString, details, command, loggerFields)
+			}
+		} else {
+			//帳戶為空
+			details += `-找不到帳戶`
+			processResponseAccountNil(clientPointer, whatKindCommandString, command, details)
+		}
+
+	} else {
+		//info為空
+		details += `-找不到要求端連線info`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+	}
+}
+
+// handleGetMyDeviceCommand - 處理<取得自己裝置資訊>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleGetMyDeviceCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok { //取info
+
+		devicePointer := infoPointer.DevicePointer //取裝置
+
+		if nil != devicePointer {
+
+			details += `-找到裝置,裝置ID=` + devicePointer.DeviceID + `,裝置Brand=` + devicePointer.DeviceBrand
+
+			device := getDeviceCopy(devicePointer) // 自己的裝置(用連線登記的裝置，裝置同步可能已從清單移除或換掉此裝置)
+
+			// Response:成功 (此處仍使用Marshal工具轉型，因考量有 物件{}形態，轉成string較為複雜。)
+			if jsonBytes, err := json.Marshal(MyDeviceResponse{Command: command.Command, CommandType: CommandTypeNumberOfAPIResponse, ResultCode: ResultCodeSuccess, ResultName: getResultName(ResultCodeSuccess), Results: getResultMessage(clientPointer.getLocale(), ResultCodeSuccess), TransactionID: command.TransactionID, Device: device}); err == nil {
+				//jsonBytes = []byte(fmt.Sprintf(baseBroadCastingJsonString1, CommandNumberOfBroadcastingInArea, CommandTypeNumberOfBroadcast, device))
+
+				// Response(場域、排除個人)
+				clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+				// logger
+				details += `-指令執行成功`
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+			} else {
+
+				// logger
+				details += `-執行指令失敗，後端json轉換出錯。`
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerErrorf(whatKindCommandString, details, command, loggerFields)
+
+			}
+		} else {
+			// 找不到裝置
+			details += `-找不到裝置`
+			processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+			return
+		}
+
+	} else {
+		// 找不到info
+		details += `-找不到要求端連線`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return
+	}
+}
+
+// handleSendVerificationCodeCommand - 處理<判斷帳號是否存在，若存在寄出驗證信>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleSendVerificationCodeCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 是否有此帳號
+	haveAccount, accountPointer := checkAccountExist(command.UserID)
+
+	if haveAccount && (nil != accountPointer) {
+
+		details += `-找到帳號,userID=` + accountPointer.UserID
+
+		var success bool
+		var otherMessages string
+		resultCode := ResultCodeMailSendFailed // 失敗時的結果代碼(預設為寄信失敗)
+
+		// 準備寄送寄信
+
+		// 若為demo模式,且為demo帳號，不驗證、不寄信、直接成功
+		if isDemoAccountInDemoMode(accountPointer) {
+			success = true
+			otherMessages = ""
+
+		} else if reserveError := reserveVerificationCodeSending(clientPointer, accountPointer); nil != reserveError {
+			// 驗證碼鎖定中或寄送過於頻繁，不寄信
+			success = false
+			otherMessages = reserveError.Error()
+			resultCode = getErrorResultCode(reserveError)
+
+		} else {
+			// 若為一般帳號，進行驗證並寄信
+			success, otherMessages = processSendVerificationCodeMail(accountPointer, whatKindCommandString, details, command, clientPointer)
+		}
+
+		if success {
+
+			details += `-驗證信已寄出`
+
+			recordAuditOfCommand(AuditEventVerificationMail, accountPointer.UserID, command, `驗證信已寄出`) // 稽核紀錄:寄送驗證信
+
+			// Response:成功
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// 一般logger
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+		} else {
+
+			details += `-驗證信寄出失敗,訊息:` + otherMessages
+
+			recordAuditOfCommand(AuditEventVerificationMail, accountPointer.UserID, command, `驗證信寄出失敗:`+otherMessages) // 稽核紀錄:寄送驗證信
+
+			// Response:失敗
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, resultCode, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// 警告logger
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+
+		}
+
+	} else {
+		details += `-找不到此帳號`
+
+		// Response:失敗
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeAccountNotFound, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// 警告logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+
+	}
+}
+
+// handleGetOnlineIdleExpertsCountCommand - 處理<取得現在同場域空閒專家人數>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleGetOnlineIdleExpertsCountCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok {
+		details += `-找到要求端連線`
+
+		devicePointer := infoPointer.DevicePointer //取裝置
+		if nil != devicePointer {
+			details += `-找到裝置,裝置ID=` + devicePointer.DeviceID + `,裝置Brand=` + devicePointer.DeviceBrand
+
+			// 取得線上同場域閒置專家數
+			onlinExperts := getOnlineIdleExpertsCountInArea(devicePointer.Area, whatKindCommandString, command, clientPointer)
+
+			// Response:成功
+			jsonBytes := []byte(fmt.Sprintf(baseResponseJsonStringExtend+`,"onlineExpertsIdle":%d}`, command.Command, CommandTypeNumberOfAPIResponse, ResultCodeSuccess, getResultName(ResultCodeSuccess), getResultMessage(clientPointer.getLocale(), ResultCodeSuccess), command.TransactionID, onlinExperts))
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// logger
+			details += `-指令執行成功,取得現在同場域空閒專家人數=` + strconv.Itoa(onlinExperts)
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+		} else {
+			//找不到裝置
+			details += `-找不到裝置`
+			processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+			return
+		}
+
+	} else {
+		//找不到info
+		details += `-找不到要求端連線`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return
+	}
+}
+
+// handleQRCodeLoginCommand - 處理<QRcode登入>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleQRCodeLoginCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	remoteHost := getClientRemoteHost(clientPointer) // 來源位址
+
+	// 來源位址登入失敗過多:退避中或鎖定中
+	if throttleError := checkLoginAllowed(``, remoteHost); nil != throttleError {
+		details += `-拒絕登入,來源位址=` + remoteHost
+		recordAuditOfCommand(AuditEventLoginFailed, ``, command, `拒絕登入,來源位址=`+remoteHost+`:`+throttleError.Error())
+		processResponseError(clientPointer, whatKindCommandString, command, details, throttleError)
+		return // 跳出
+	}
+
+	// QRcode登入不需要密碼，只要確認是否有此帳號
+
+	// 登入QRcode由管理者API產生: GET /admin/qrcode/login?userID=帳號
+
+	// 進行解密
+	token, tokenError := jwts.ParseToken(command.UserID, jwts.PurposeLogin)
+
+	// 解密後字串
+	var decryptedString string
+
+	//若非nil 取出
+	if token != nil {
+		// 解密成功
+		details += `-QR cdoe 解密成功`
+
+		decryptedString = token.Data
+	} else {
+		// 解密出現錯誤，找不到token
+		details += `-QR code 解密錯誤:` + tokenError.Error()
+
+		recordLoginFailure(``, remoteHost) // 記錄登入失敗(累計退避與鎖定)
+		recordAuditOfCommand(AuditEventLoginFailed, ``, command, `QR code 解密錯誤,來源位址=`+remoteHost+`:`+tokenError.Error())
+
+		// Response：失敗
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeTokenInvalid, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// 錯誤logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerErrorf(whatKindCommandString, details, command, loggerFields)
+
+		return // 跳出
+
+	}
+
+	userid := decryptedString
+
+	// 帳號登入失敗過多:退避中或鎖定中
+	if throttleError := checkLoginAllowed(userid, remoteHost); nil != throttleError {
+		details += `-拒絕登入,來源位址=` + remoteHost
+		recordAuditOfCommand(AuditEventLoginFailed, userid, command, `拒絕登入,來源位址=`+remoteHost+`:`+throttleError.Error())
+		processResponseError(clientPointer, whatKindCommandString, command, details, throttleError)
+		return // 跳出
+	}
+
+	// 是否有此帳號
+	check, accountPointer := checkAccountExist(userid)
+
+	// 找到帳號
+	if check {
+
+		details += `-找到帳號`
+
+		recordLoginSuccess(userid) // 清除帳號登入失敗紀錄
+
+		// 找裝置Pointer
+		devicePointer := getDevice(command.DeviceID, command.DeviceBrand)
+
+		// 找到裝置
+		if devicePointer != nil {
+
+			details += `-找到裝置`
+
+			// 登入:裝置、帳號 (加入Map。包含處理裝置重複登入)
+			if otherMeessage, loginError := processLoginWithDuplicate(whatKindCommandString, clientPointer, command, devicePointer, accountPointer); nil == loginError {
+				// 登入成功
+
+				details += `-登入成功`
+
+				// Response:成功(附上恢復連線權杖)
+				jsonBytes := []byte(fmt.Sprintf(baseResponseJsonStringExtend+`,"resumeToken":"%s"}`, command.Command, CommandTypeNumberOfAPIResponse, ResultCodeSuccess, getResultName(ResultCodeSuccess), getResultMessage(clientPointer.getLocale(), ResultCodeSuccess), command.TransactionID, issueResumeToken(clientPointer)))
+				clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+				// 一般logger(登入的狀態轉換掛勾已進行廣播)
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+			} else {
+				// 登入失敗
+				details += `-登入失敗:` + loginError.Error() + otherMeessage
+
+				// Response:失敗
+				jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, getErrorResultCode(loginError), command.TransactionID)
+				clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+				// 一般logger
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+				return // 跳出
+
+			}
+
+		} else {
+			// 找不到裝置
+			details += `-找不到裝置`
+
+			// Response：失敗
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeDeviceNotFound, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// 警告logger
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+
+			return // 跳出
+		}
+
+	} else {
+		// logger:帳密錯誤
+		details += `-找不到帳號或密碼錯誤`
+
+		recordLoginFailure(userid, remoteHost) // 記錄登入失敗(累計退避與鎖定)
+		recordAuditOfCommand(AuditEventLoginFailed, userid, command, `找不到帳號,來源位址=`+remoteHost)
+
+		// Response：失敗
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeCredentialIncorrect, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// 警告logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+
+		return // 跳出
+	}
+}
+
+// handleChangeAreaCommand - 處理<眼鏡切換場域>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleChangeAreaCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	// 閒置中才可以切換場域（通話中、求助中無法切換場域）
+	if !checkDeviceStatusIsIdleAndResponseIfFail(clientPointer, command, whatKindCommandString, "-檢查設備狀態為閒置才可切換場域") {
+		return //跳出
+	}
+
+	details := `-收到指令`
+
+	// QRCode解密
+
+	// 切換場域QRcode由管理者API產生: GET /admin/qrcode/area?areaID=場域代號
+
+	// 進行解密
+	token, tokenError := jwts.ParseToken(command.AreaEncryptionString, jwts.PurposeArea)
+
+	// 要取出的 data
+	var newAreaString string
+
+	// 取出內容 data
+	if token != nil {
+		newAreaString = token.Data
+	} else {
+		details += `-QR code 解密錯誤:` + tokenError.Error()
+	}
+
+	// 新場域代碼：data轉成 場域數字代碼
+	newAreaNumber, err := strconv.Atoi(newAreaString)
+	if err == nil {
+		// 轉換數字成功
+		details += `-字符或數字轉換成功`
+	} else {
+		// 失敗：轉換數字失敗
+		details += `-執行指令失敗-字符轉換失敗-字符或數字轉換錯誤或解密錯誤`
+
+		// Response：失敗
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeTokenInvalid, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+
+		return //跳出
+	}
+
+	// 查找是否有此場域代碼
+	areaName, isAreaFound := getAreaName(newAreaNumber) //場域名稱
+	if isAreaFound && isAreaActive(newAreaNumber) {
+		details += `-找到此場域代碼,場域代碼=` + strconv.Itoa(newAreaNumber) + `,場域名稱=` + areaName
+	} else {
+		//失敗：沒有此場域區域
+		details += `-執行指令失敗-找不到此場域代碼與其對應名稱或場域未啟用,場域代碼=` + strconv.Itoa(newAreaNumber)
+
+		// Response：失敗
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeAreaNotFound, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+
+		return //跳出
+	}
+
+	// 暫存
+	var oldArea []int //舊場域代碼
+
+	var newAreaNumberArray []int                                   //新場域代碼
+	newAreaNumberArray = append(newAreaNumberArray, newAreaNumber) //封裝成array
+
+	// 檢查Info
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok {
+		details += `-找到要求端連線`
+
+		// 檢查裝置
+		devicePointer := infoPointer.DevicePointer
+		if devicePointer != nil {
+			details += `-找到裝置,裝置ID=` + devicePointer.DeviceID + `,裝置Brand=` + devicePointer.DeviceBrand
+
+			isAreaChanged := false // 是否已換成新場域
+
+			// 若場域代碼與現在場域不相同(沒有場域的裝置也可切換)，換成新場域(檢查與變更在設備狀態鎖內一起完成)
+			applyDeviceChange(func() {
+				if 0 == len(devicePointer.Area) || newAreaNumber != devicePointer.Area[0] {
+					oldArea = devicePointer.Area            //暫存舊場域
+					devicePointer.Area = newAreaNumberArray //換成新場域代號
+					isAreaChanged = true
+				}
+			})
+
+			if isAreaChanged {
+				// 成功
+
+				sessionRegistryPointer.reindexClient(clientPointer) // 場域已改變，更新索引
+
+				recordAuditOfClient(AuditEventAreaSwitched, clientPointer, ``, `舊場域代號=`+fmt.Sprint(oldArea)) // 稽核紀錄:切換場域
+
+				// Response:成功
+				jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+				clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+				// logger
+				// int [] string[] 轉換成string
+				newAreaString := fmt.Sprint(newAreaNumberArray)
+				newAreaNameString := fmt.Sprint(getAreaNames(newAreaNumberArray))
+				oldAreaString := fmt.Sprint(oldArea)
+				oldAreaNameString := fmt.Sprint(getAreaNames(oldArea))
+
+				details += `-指令執行成功,已換成新場域,新場域代號=` + newAreaString + `,新場域名=` + newAreaNameString + `,舊場域代號=` + oldAreaString + `,舊場域名=` + oldAreaNameString
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+				// 準備廣播:包成Array:放入 Response Devices
+				//deviceArray := getArray(clientInfoMap[clientPointer].DevicePointer) // 包成array
+				device := getDeviceCopy(devicePointer)  // 變更後的裝置(廣播用)
+				deviceArray := getArrayPointer(&device) // 包成array
+
+				// 廣播給舊場域的
+				processBroadcastingDeviceChangeStatusInSomeArea(whatKindCommandString, command, clientPointer, deviceArray, oldArea, details)
+
+				// 廣播給現在新場域的連線裝置
+				messages := processBroadcastingDeviceChangeStatusInMyArea(whatKindCommandString, command, clientPointer, deviceArray, details)
+
+				// logger
+				details += `-執行(區域)廣播,詳細訊息:` + messages
+				loggerFields = getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+			} else {
+				//失敗：此裝置已經在這個場域，不進行切換
+				details += `-指令執行失敗,此裝置已經在這個場域(` + areaName + `)，不進行切換`
+
+				// Response：失敗
+				jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeAlreadyInArea, command.TransactionID)
+				clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+				// logger
+				loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+				processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+				return
+			}
+		} else {
+			//找不到裝置
+			details += `-找不到裝置`
+			processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+			return
+		}
+	} else {
+		//找不到Info
+		details += `-找不到要求端連線`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return
+	}
+}
+
+// handleCancelHelpCommand - 處理<取消求助>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleCancelHelpCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	// 設定Pic, RoomID, 裝置狀態
+	if infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok {
+		details += `-找到要求端連線`
+
+		devicePointer := infoPointer.DevicePointer
+		if devicePointer != nil {
+			//成功
+			details += `-找到裝置,裝置ID=` + devicePointer.DeviceID + `,裝置Brand=` + devicePointer.DeviceBrand
+
+			// 設備狀態:閒置(同時移出求助佇列)，求助中才可取消
+			if transitionError := changeDeviceStatus(clientPointer, devicePointer, deviceStatusEventCancelHelp, func() {
+				devicePointer.Pic = ""   // Pic還原預設
+				devicePointer.RoomID = 0 // RoomID還原預設
+			}, ``); nil != transitionError {
+				processResponseDeviceStatusNotAllowed(clientPointer, whatKindCommandString, command, details, transitionError)
+				return
+			}
+
+			sessionRegistryPointer.reindexClient(clientPointer) // 房號已改變，更新索引
+
+			// Response:成功
+			jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+			// logger
+			details += `-指令執行成功,取消了求助,裝置RoomID=` + strconv.Itoa(devicePointer.RoomID) + `,設備狀態DeviceStatus=` + strconv.Itoa(int(devicePointer.DeviceStatus))
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+		} else {
+			// 裝置為空
+			details += `-找不到裝置`
+			processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+			return
+
+		}
+
+	} else {
+		// Info 為空
+		details += `-找不到要求端連線`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return
+
+	}
+}
+
+// handleGetRoomsInMyAreaCommand - 處理<取得同場域房間清單>指令
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleGetRoomsInMyAreaCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer)
+
+	if nil == infoPointer {
+		details += `-找不到要求端連線`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	area := getSessionIndexKeys(infoPointer).area // 眼鏡端依裝置場域，平板端依帳號場域
+	rooms := roomManagerPointer.getRoomsByArea(area)
+
+	jsonBytes, err := json.Marshal(RoomsInMyAreaResponse{
+		Command:       command.Command,
+		CommandType:   CommandTypeNumberOfAPIResponse,
+		ResultCode:    ResultCodeSuccess,
+		ResultName:    getResultName(ResultCodeSuccess),
+		Results:       getResultMessage(clientPointer.getLocale(), ResultCodeSuccess),
+		TransactionID: command.TransactionID,
+		Rooms:         rooms,
+	})
+
+	if nil != err {
+
+		details += `-執行失敗:房間清單轉json失敗`
+
+		// Response:失敗
+		jsonBytes = getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeInternalError, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerErrorf(whatKindCommandString, details, command, loggerFields)
+		return // 跳出
+	}
+
+	// Response:成功
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+	// logger
+	details += `-指令執行成功,場域` + fmt.Sprint(area) + `共有房間` + strconv.Itoa(len(rooms)) + `間`
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+}
+
+// handleJoinRoomCommand - 處理<加入房間>指令(多方通話:專家加入進行中的房間)
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleJoinRoomCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer) // 取info
+
+	if nil == infoPointer {
+		details += `-找不到要求端連線info`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	devicePointer := infoPointer.DevicePointer   // 取device
+	accountPointer := infoPointer.AccountPointer // 取account
+
+	if nil == devicePointer {
+		details += `-找不到裝置`
+		processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	if nil == accountPointer {
+		details += `-找不到帳號`
+		processResponseAccountNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	// 檢核:不可同時在兩個房間
+	if 0 != devicePointer.RoomID {
+		details += `-執行失敗:已在房號` + strconv.Itoa(devicePointer.RoomID) + `中，請先離開房間`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeAlreadyInRoom, devicePointer.RoomID)
+		return // 跳出
+	}
+
+	// 檢核:裝置需為閒置
+	if !checkDeviceStatusIsIdleAndResponseIfFail(clientPointer, command, whatKindCommandString, details) {
+		return // 跳出
+	}
+
+	// 檢核並保留座位:房間需開啟、未滿，且同場域或受邀請
+	deviceKey := getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)
+
+	if joinError := roomManagerPointer.tryJoinRoom(command.RoomID, deviceKey, getSessionIndexKeys(infoPointer).area); nil != joinError {
+		details += `-執行失敗:` + joinError.Error()
+		processResponseFail(clientPointer, whatKindCommandString, command, details, getErrorResultCode(joinError))
+		return // 跳出
+	}
+
+	isMuted := isMutedInRoom(infoPointer) // 角色是否只能靜音旁聽
+
+	// 自己 進入房間:通話中(檢核後狀態已改變則釋放保留的座位)
+	if transitionError := changeDeviceStatus(clientPointer, devicePointer, deviceStatusEventJoinRoom, func() {
+		devicePointer.CameraStatus = 1 // 預設開啟相機
+		devicePointer.MicStatus = 1    // 預設開啟麥克風
+		devicePointer.RoomID = command.RoomID
+
+		if isMuted {
+			devicePointer.CameraStatus = 0 // 關閉
+			devicePointer.MicStatus = 0    // 關閉
+		}
+	}, ``); nil != transitionError {
+		roomManagerPointer.leaveRoom(command.RoomID, deviceKey)
+		processResponseDeviceStatusNotAllowed(clientPointer, whatKindCommandString, command, details, transitionError)
+		return // 跳出
+	}
+
+	sessionRegistryPointer.reindexClient(clientPointer) // 房號已改變，更新索引
+
+	// 其他同房間裝置:求助中的一線人員開始通話(靜音旁聽者加入不算回應求助)
+	otherDevicesPointer := getOtherDevicesInTheSameRoom(command.RoomID, clientPointer)
+
+	if !isMuted {
+		for _, dPointer := range otherDevicesPointer {
+			changeDeviceStatus(clientPointer, dPointer, deviceStatusEventHelpAnswered, func() { // 求助中才會轉為通話中(同時移出求助佇列)，其他狀態不改變
+				dPointer.CameraStatus = 1 // 預設開啟相機
+				dPointer.MicStatus = 1    // 預設開啟麥克風
+			}, ``)
+		}
+	}
+
+	// Response:成功
+	jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+	// logger
+	details += `-指令執行成功,加入房號=` + strconv.Itoa(command.RoomID) + `,房間內其他裝置` + strconv.Itoa(len(otherDevicesPointer)) + `台`
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+}
+
+// handleInviteToRoomCommand - 處理<邀請加入房間>指令(房間內的參與者邀請其他專家)
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令(deviceID、deviceBrand為受邀者裝置)
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleInviteToRoomCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer) // 取info
+
+	if nil == infoPointer {
+		details += `-找不到要求端連線info`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	devicePointer := infoPointer.DevicePointer // 取device
+
+	if nil == devicePointer {
+		details += `-找不到裝置`
+		processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	// 檢核:邀請者需在房間中
+	if 0 == devicePointer.RoomID {
+		details += `-執行失敗:不在任何房間中，無法邀請`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeNotInRoom)
+		return // 跳出
+	}
+
+	// 檢核:受邀者需在線上
+	inviteeDeviceKey := getDeviceKey(command.DeviceID, command.DeviceBrand)
+	inviteeClientPointer, inviteeInfoPointer, ok := sessionRegistryPointer.getClientPointerByDeviceKey(inviteeDeviceKey)
+
+	if !ok || nil == inviteeInfoPointer || nil == inviteeInfoPointer.DevicePointer || nil == inviteeInfoPointer.AccountPointer {
+		details += `-執行失敗:受邀者裝置ID=` + command.DeviceID + `,裝置品牌=` + command.DeviceBrand + `不在線上`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeTargetNotAvailable)
+		return // 跳出
+	}
+
+	// 檢核:受邀者的角色需可加入房間
+	if permissionError := checkCommandPermission(inviteeInfoPointer, CommandNumberOfJoinRoom); nil != permissionError {
+		details += `-執行失敗:受邀者` + permissionError.Error()
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodePermissionDenied)
+		return // 跳出
+	}
+
+	// 檢核:受邀者需閒置且不在其他房間
+	if DeviceStatusIdle != inviteeInfoPointer.DevicePointer.DeviceStatus || 0 != inviteeInfoPointer.DevicePointer.RoomID {
+		details += `-執行失敗:受邀者非閒置`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeTargetNotIdle)
+		return // 跳出
+	}
+
+	// 檢核並登記邀請:房間需開啟、未滿，且邀請者在房間內
+	if inviteError := roomManagerPointer.inviteToRoom(devicePointer.RoomID, getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand), inviteeDeviceKey); nil != inviteError {
+		details += `-執行失敗:` + inviteError.Error()
+		processResponseFail(clientPointer, whatKindCommandString, command, details, getErrorResultCode(inviteError))
+		return // 跳出
+	}
+
+	invitation := RoomInvitation{
+		Command:            CommandNumberOfInviteToRoom,
+		CommandType:        CommandTypeNumberOfBroadcast,
+		RoomID:             devicePointer.RoomID,
+		InviterDeviceID:    devicePointer.DeviceID,
+		InviterDeviceBrand: devicePointer.DeviceBrand,
+	}
+
+	if nil != infoPointer.AccountPointer {
+		invitation.InviterUserID = infoPointer.AccountPointer.UserID
+	}
+
+	invitationJsonBytes, err := json.Marshal(invitation)
+
+	if nil != err {
+		details += `-執行失敗:邀請轉json失敗`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeInternalError)
+		return // 跳出
+	}
+
+	// 通知受邀者
+	inviteeClientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: invitationJsonBytes}
+
+	// Response:成功
+	jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+	// logger
+	details += `-指令執行成功,邀請裝置ID=` + command.DeviceID + `,裝置品牌=` + command.DeviceBrand + `加入房號=` + strconv.Itoa(devicePointer.RoomID)
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+}
+
+// handleChangePasswordCommand - 處理<變更密碼>指令(需輸入舊密碼，新密碼需符合密碼規則)
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleChangePasswordCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer)
+
+	if nil == infoPointer {
+		details += `-找不到要求端連線`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	accountPointer := infoPointer.AccountPointer
+
+	if nil == accountPointer {
+		details += `-找不到帳號`
+		processResponseAccountNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	details += `-找到帳號,userID=` + accountPointer.UserID
+
+	if err := changeAccountPassword(accountPointer, command.UserPassword, command.NewPassword); nil != err {
+		details += `-變更密碼失敗:` + err.Error()
+		processResponseFail(clientPointer, whatKindCommandString, command, details, getErrorResultCode(err))
+		return // 跳出
+	}
+
+	// Response:成功
+	jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+	// logger
+	details += `-指令執行成功,密碼已變更`
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+}
+
+// handleEndCallCommand - 處理<結束通話>指令(結束所屬場域內某房間的通話，所有參與者離開房間)
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令(roomID為要結束的房號)
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleEndCallCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer) // 取info
+
+	if nil == infoPointer {
+		details += `-找不到要求端連線info`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	// 檢核:房間需開啟
+	room, ok := roomManagerPointer.getOpenRoom(command.RoomID)
+
+	if !ok {
+		details += `-執行失敗:` + errRoomNotOpen.Error()
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeRoomNotOpen)
+		return // 跳出
+	}
+
+	// 檢核:房間需在自己可管理的場域內
+	if permissionError := checkAreaPermission(infoPointer, room.Area); nil != permissionError {
+
+		details += `-權限不足-` + permissionError.Error() + `,房間場域=` + fmt.Sprint(room.Area)
+
+		// Response:權限不足
+		jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodePermissionDenied, command.TransactionID)
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		// 警告logger
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerWarnf(whatKindCommandString, details, command, loggerFields)
+		return // 跳出
+	}
+
+	// 稽核紀錄:結束通話(執行者為主管，房號與通話秒數為被結束的房間)
+	auditRecord := newAuditRecord(AuditEventHangUp, infoPointer.AccountPointer, infoPointer.DevicePointer)
+	auditRecord.RoomID = room.RoomID
+	auditRecord.Details = `結束整個通話`
+
+	if !room.callStartedTime.IsZero() {
+		auditRecord.CallSeconds = int(time.Since(room.callStartedTime).Seconds())
+	}
+
+	recordAudit(auditRecord)
+
+	// 房間內所有參與者(含自己)離開通話並離開房間(狀態轉換掛勾會進行房間與區域廣播)
+	devicesPointer := []*Device{}
+
+	for _, participantInfoPointer := range getOtherInfosInTheSameRoom(command.RoomID, nil) {
+
+		dPointer := participantInfoPointer.DevicePointer
+
+		if transitionError := changeDeviceStatus(clientPointer, dPointer, deviceStatusEventLeaveCall, func() { // 裝置閒置(同時移出求助佇列)
+			dPointer.CameraStatus = 0 // 關閉
+			dPointer.MicStatus = 0    // 關閉
+			dPointer.RoomID = 0       // 沒有房間
+		}, ``); nil != transitionError {
+
+			// 狀態與房號皆不改變
+			otherDetails := details + `-參與者裝置ID=` + dPointer.DeviceID + `,裝置Brand=` + dPointer.DeviceBrand + `,狀態未改變:` + transitionError.Error()
+			loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+			processLoggerWarnf(whatKindCommandString, otherDetails, command, loggerFields)
+			continue
+		}
+
+		devicesPointer = append(devicesPointer, dPointer)
+	}
+
+	// 房號已改變，全部轉換後才更新索引(最後一位參與者離開時房間管理器會關閉房間)
+	for _, dPointer := range devicesPointer {
+		sessionRegistryPointer.reindexDevicePointer(dPointer)
+	}
+
+	// Response:成功
+	jsonBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+	// logger
+	details += `-指令執行成功,結束房號=` + strconv.Itoa(command.RoomID) + `的通話,離開房間的裝置` + strconv.Itoa(len(devicesPointer)) + `台`
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+	pushHelpQueuePositions()                                                     // 佇列可能已改變，通知排隊位置
+	processAutoAssignHelpRequests(whatKindCommandString, command, clientPointer) // 可能有專家變閒置，自動指派求助
+}
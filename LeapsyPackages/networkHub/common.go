@@ -1,12 +1,11 @@
 package networkHub
 
 import (
-	"../configurations"
 	"../logings"
 )
 
 var (
-	logger      = logings.GetLogger()                                                      // 記錄器
-	channelSize = configurations.GetConfigPositiveIntValueOrPanic(`local`, `channel-size`) // 取得預設通道大小                                                              // 讀寫鎖
+	logger      = logings.GetLogger() // 記錄器(與主程式共用，主程式依設定檔初始化後生效)
+	channelSize int                   // 預設通道大小(初始化網路中心時由設定檔取得)
 )
 
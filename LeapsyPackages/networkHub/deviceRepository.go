@@ -0,0 +1,462 @@
+package networkHub
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"../configurations"
+	"../databases"
+	"../logings"
+	"github.com/gobwas/ws"
+)
+
+// deviceRecord - 裝置儲存資料(裝置檔或資料庫中的一筆裝置)
+type deviceRecord struct {
+	DeviceID    string `json:"deviceID"`    // 裝置ID
+	DeviceBrand string `json:"deviceBrand"` // 裝置品牌(怕平板裝置的ID會重複)
+	DeviceType  int    `json:"deviceType"`  // 裝置類型
+	Area        []int  `json:"area"`        // 場域
+	DeviceName  string `json:"deviceName"`  // 裝置名稱
+}
+
+// getDeviceKey - 取得裝置關鍵字
+/**
+ * @return string 裝置關鍵字(DeviceID+DeviceBrand)
+ */
+func (deviceRecordPointer *deviceRecord) getDeviceKey() string {
+	return getDeviceKey(deviceRecordPointer.DeviceID, deviceRecordPointer.DeviceBrand)
+}
+
+// toDevice - 將裝置儲存資料轉成離線的裝置
+/**
+ * @return *Device 裝置指標
+ */
+func (deviceRecordPointer *deviceRecord) toDevice() *Device {
+
+	devicePointer := &Device{
+		DeviceID:     deviceRecordPointer.DeviceID,
+		DeviceBrand:  deviceRecordPointer.DeviceBrand,
+		DeviceType:   deviceRecordPointer.DeviceType,
+		Area:         deviceRecordPointer.Area,
+		DeviceName:   deviceRecordPointer.DeviceName,
+		Pic:          "",                  // <求助>時才會從客戶端得到
+		OnlineStatus: OnlineStatusOffline, // 離線
+		DeviceStatus: DeviceStatusOffline, // 未設定
+		MicStatus:    0,                   // 未設定
+		CameraStatus: 0,                   // 未設定
+		RoomID:       0,                   // 無房間
+	}
+
+	if nil == devicePointer.Area { // 回傳給客戶端時維持空陣列
+		devicePointer.Area = []int{}
+	}
+
+	return devicePointer
+}
+
+// getDeviceKey - 取得裝置關鍵字
+/**
+ * @param string deviceID 裝置ID
+ * @param string deviceBrand 裝置品牌
+ * @return string 裝置關鍵字
+ */
+func getDeviceKey(deviceID, deviceBrand string) string {
+	return fmt.Sprintf(`%s|%s`, deviceID, deviceBrand)
+}
+
+// deviceRepository - 裝置儲存庫
+type deviceRepository interface {
+
+	// loadAllDeviceRecords - 載入所有裝置儲存資料
+	loadAllDeviceRecords() ([]deviceRecord, error)
+}
+
+// deviceJSONFileRepository - JSON裝置檔儲存庫
+type deviceJSONFileRepository struct {
+	fileName string // 裝置檔名
+}
+
+// loadAllDeviceRecords - 載入所有裝置儲存資料
+/**
+ * @return []deviceRecord returnDeviceRecords 裝置儲存資料
+ * @return error returnError 錯誤
+ */
+func (deviceJSONFileRepositoryPointer *deviceJSONFileRepository) loadAllDeviceRecords() (returnDeviceRecords []deviceRecord, returnError error) {
+
+	fileBytes, returnError := ioutil.ReadFile(deviceJSONFileRepositoryPointer.fileName) // 讀取裝置檔
+
+	if nil != returnError { // 若讀取裝置檔錯誤
+		return // 回傳
+	}
+
+	returnError = json.Unmarshal(fileBytes, &returnDeviceRecords) // 解譯裝置檔
+
+	return // 回傳
+}
+
+// deviceSQLiteRepository - SQLite裝置儲存庫
+type deviceSQLiteRepository struct {
+	tableOncePointer *sync.Once // 只建立一次資料表
+}
+
+const (
+	// 建立裝置資料表
+	deviceSQLiteCreateTableString = `CREATE TABLE IF NOT EXISTS devices (
+		device_id TEXT NOT NULL,
+		device_brand TEXT NOT NULL,
+		device_type INTEGER NOT NULL,
+		area TEXT NOT NULL DEFAULT '[]',
+		device_name TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (device_id, device_brand)
+	)`
+
+	// 查詢裝置欄位
+	deviceSQLiteSelectString = `SELECT device_id, device_brand, device_type, area, device_name FROM devices`
+)
+
+// getDatabase - 取得已建好裝置資料表的資料庫
+/**
+ * @return *sql.DB 資料庫指標
+ */
+func (deviceSQLiteRepositoryPointer *deviceSQLiteRepository) getDatabase() *sql.DB {
+
+	databasePointer := databases.GetSQLiteDatabaseOrPanic() // 取得資料庫
+
+	deviceSQLiteRepositoryPointer.tableOncePointer.Do(func() {
+
+		_, execError := databasePointer.Exec(deviceSQLiteCreateTableString) // 建立裝置資料表
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`建立裝置資料表 `},
+			[]interface{}{},
+			execError,
+		)
+
+		if nil != execError { // 若建立裝置資料表錯誤
+			logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+		}
+
+	})
+
+	return databasePointer // 回傳資料庫指標
+}
+
+// loadAllDeviceRecords - 載入所有裝置儲存資料
+/**
+ * @return []deviceRecord returnDeviceRecords 裝置儲存資料
+ * @return error returnError 錯誤
+ */
+func (deviceSQLiteRepositoryPointer *deviceSQLiteRepository) loadAllDeviceRecords() (returnDeviceRecords []deviceRecord, returnError error) {
+
+	rowsPointer, returnError := deviceSQLiteRepositoryPointer.getDatabase().Query(deviceSQLiteSelectString) // 查詢所有裝置
+
+	if nil != returnError { // 若查詢錯誤
+		return // 回傳
+	}
+
+	defer rowsPointer.Close() // 記得關閉查詢結果
+
+	for rowsPointer.Next() { // 針對每一列
+
+		var scannedDeviceRecord deviceRecord // 裝置儲存資料
+		var areaString string                // 場域代號(JSON陣列字串)
+
+		returnError = rowsPointer.Scan(
+			&scannedDeviceRecord.DeviceID,
+			&scannedDeviceRecord.DeviceBrand,
+			&scannedDeviceRecord.DeviceType,
+			&areaString,
+			&scannedDeviceRecord.DeviceName,
+		)
+
+		if nil == returnError {
+			returnError = json.Unmarshal([]byte(areaString), &scannedDeviceRecord.Area)
+		}
+
+		if nil != returnError { // 若讀出錯誤
+			return // 回傳
+		}
+
+		returnDeviceRecords = append(returnDeviceRecords, scannedDeviceRecord)
+	}
+
+	returnError = rowsPointer.Err() // 回傳查詢過程的錯誤
+
+	return // 回傳
+}
+
+// newDeviceRepositoryByConfig - 依設定檔建立裝置儲存庫
+/**
+ * @return deviceRepository 裝置儲存庫
+ */
+func newDeviceRepositoryByConfig() deviceRepository {
+
+	storeType := configurations.GetConfigValueOrPanic(`device`, `store`) // 裝置儲存方式
+
+	switch storeType {
+
+	case `json`: // JSON裝置檔
+		return &deviceJSONFileRepository{fileName: configurations.GetConfigValueOrPanic(`device`, `json-file`)}
+
+	case `sqlite`: // SQLite資料庫
+		return &deviceSQLiteRepository{tableOncePointer: new(sync.Once)}
+
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`建立裝置儲存庫 %s `},
+		[]interface{}{storeType},
+		errors.New(`[ device ] store 應為 json 或 sqlite`),
+	)
+
+	logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+
+	return nil
+}
+
+var (
+	deviceRepositoryValue deviceRepository // 裝置儲存庫
+
+	// 裝置清單同步間隔
+	deviceSyncIntervalDuration time.Duration
+
+	allDevicePointerListReadWriteLock = new(sync.RWMutex) // 所有裝置清單讀寫鎖
+)
+
+// initializeDeviceRepository - 依設定檔建立裝置儲存庫與同步間隔
+func initializeDeviceRepository() {
+	deviceRepositoryValue = newDeviceRepositoryByConfig()
+	deviceSyncIntervalDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`device`, `sync-interval`)) * time.Second
+}
+
+// getAllDevicePointerList - 取得所有裝置清單(複製清單，裝置指標不變)
+/**
+ * @return []*Device 所有裝置清單
+ */
+func getAllDevicePointerList() []*Device {
+	allDevicePointerListReadWriteLock.RLock()         // 讀鎖
+	defer allDevicePointerListReadWriteLock.RUnlock() // 記得解開讀鎖
+	return append([]*Device{}, allDevicePointerList...)
+}
+
+// setInfoPointerWithListedDevice - 以所有裝置清單中目前的裝置設定連線的登入資訊(登入用)
+/**
+ * 查找與登記在同一段清單讀鎖內完成，與裝置同步互斥，登記的裝置不會在同步時被換掉或被當成離線裝置取代
+ * @param *client clientPointer 連線指標
+ * @param *Info infoPointer 登入資訊(裝置換成清單中目前的裝置，清單中已無此裝置則維持原裝置)
+ */
+func setInfoPointerWithListedDevice(clientPointer *client, infoPointer *Info) {
+
+	allDevicePointerListReadWriteLock.RLock()         // 讀鎖
+	defer allDevicePointerListReadWriteLock.RUnlock() // 記得解開讀鎖
+
+	if nil != infoPointer && nil != infoPointer.DevicePointer {
+
+		deviceKey := getDeviceKey(infoPointer.DevicePointer.DeviceID, infoPointer.DevicePointer.DeviceBrand)
+
+		for _, devicePointer := range allDevicePointerList {
+			if nil != devicePointer && deviceKey == getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand) {
+				infoPointer.DevicePointer = devicePointer
+				break
+			}
+		}
+
+	}
+
+	sessionRegistryPointer.setInfoPointer(clientPointer, infoPointer) // 先清單鎖再登記表鎖
+}
+
+// isDeviceRecordChanged - 判斷裝置基本資料是否與裝置儲存資料不同
+/**
+ * @param *Device devicePointer 裝置指標
+ * @param deviceRecord newDeviceRecord 裝置儲存資料
+ * @return bool 是否不同
+ */
+func isDeviceRecordChanged(devicePointer *Device, newDeviceRecord deviceRecord) bool {
+	return devicePointer.DeviceType != newDeviceRecord.DeviceType ||
+		devicePointer.DeviceName != newDeviceRecord.DeviceName ||
+		fmt.Sprint(devicePointer.Area) != fmt.Sprint(newDeviceRecord.toDevice().Area)
+}
+
+// getOnlineDevicePointerMap - 取得連線中的裝置(以連線登入資訊中的裝置為準)
+/**
+ * @return map[string]*Device returnOnlineDevicePointerMap 裝置關鍵字對應連線中的裝置
+ */
+func getOnlineDevicePointerMap() (returnOnlineDevicePointerMap map[string]*Device) {
+
+	returnOnlineDevicePointerMap = make(map[string]*Device)
+
+	for _, infoPointer := range sessionRegistryPointer.getClientInfoMapCopy() {
+		if nil != infoPointer && nil != infoPointer.DevicePointer {
+			devicePointer := infoPointer.DevicePointer
+			returnOnlineDevicePointerMap[getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)] = devicePointer
+		}
+	}
+
+	return // 回傳
+}
+
+// mergeDeviceRecords - 將裝置儲存資料合併到所有裝置清單
+/**
+ * 連線中的裝置會被各指令直接改變狀態，保留原本的裝置，到離線後的下次同步才更新基本資料；
+ * 其餘變更的裝置以新的裝置取代，不修改其他執行緒可能正在讀取的裝置
+ * @param []deviceRecord deviceRecords 裝置儲存資料
+ * @return []*Device returnChangedDevicePointers 新增、變更或移除的裝置
+ */
+func mergeDeviceRecords(deviceRecords []deviceRecord) (returnChangedDevicePointers []*Device) {
+
+	allDevicePointerListReadWriteLock.Lock()         // 寫鎖
+	defer allDevicePointerListReadWriteLock.Unlock() // 記得解開寫鎖
+
+	// 連線中的裝置(與換掉清單在同一段清單鎖內取得，期間登入、登出不會讓保留或取代的裝置與連線狀態不符；
+	// 鎖的順序固定為先清單鎖再登記表鎖，登記表鎖內不會取清單鎖)
+	onlineDevicePointerMap := getOnlineDevicePointerMap()
+
+	devicePointerMap := make(map[string]*Device) // 裝置關鍵字對應現有裝置
+
+	for _, devicePointer := range allDevicePointerList {
+		if nil != devicePointer {
+			devicePointerMap[getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)] = devicePointer
+		}
+	}
+
+	loadedDeviceKeyMap := make(map[string]bool) // 本次載入的裝置
+	newDevicePointerList := []*Device{}         // 新的所有裝置清單
+
+	for _, loadedDeviceRecord := range deviceRecords { // 針對每一筆裝置儲存資料
+
+		deviceKey := loadedDeviceRecord.getDeviceKey()
+
+		if loadedDeviceKeyMap[deviceKey] { // 重複的裝置只取第一筆
+			continue
+		}
+
+		loadedDeviceKeyMap[deviceKey] = true
+
+		if onlineDevicePointer, ok := onlineDevicePointerMap[deviceKey]; ok { // 連線中的裝置(場域可能已被<眼鏡切換場域>改變)，保留到離線後的下次同步
+			newDevicePointerList = append(newDevicePointerList, onlineDevicePointer)
+			continue
+		}
+
+		devicePointer, ok := devicePointerMap[deviceKey]
+
+		if !ok || isDeviceRecordChanged(devicePointer, loadedDeviceRecord) { // 新增或變更的裝置
+			devicePointer = loadedDeviceRecord.toDevice()
+			returnChangedDevicePointers = append(returnChangedDevicePointers, devicePointer)
+		}
+
+		newDevicePointerList = append(newDevicePointerList, devicePointer)
+	}
+
+	for deviceKey, devicePointer := range devicePointerMap { // 針對儲存庫已移除的裝置
+
+		if loadedDeviceKeyMap[deviceKey] {
+			continue
+		}
+
+		if onlineDevicePointer, ok := onlineDevicePointerMap[deviceKey]; ok { // 連線中的裝置保留到離線後的下次同步
+			newDevicePointerList = append(newDevicePointerList, onlineDevicePointer)
+		} else { // 已移除的裝置以離線狀態廣播
+			returnChangedDevicePointers = append(returnChangedDevicePointers, devicePointer)
+		}
+
+	}
+
+	allDevicePointerList = newDevicePointerList // 更新所有裝置清單
+
+	return // 回傳
+}
+
+// broadcastChangedDevices - 對各場域廣播裝置清單的變更(先整理每個連線應收到的裝置，每個連線只廣播一次)
+/**
+ * @param []*Device changedDevicePointers 新增、變更或移除的裝置
+ */
+func broadcastChangedDevices(changedDevicePointers []*Device) {
+
+	whatKindCommandString := `伺服器-同步裝置清單`
+
+	recipientClientPointers := []*client{}                    // 廣播對象(依找到的順序)
+	recipientDevicePointersMap := make(map[*client][]*Device) // 廣播對象對應應收到的裝置
+
+	for _, devicePointer := range changedDevicePointers {
+		for _, clientPointer := range sessionRegistryPointer.getClientPointersByArea(getAreaWithDescendants(devicePointer.Area)) { // 裝置場域與其所有下層場域的連線(不重複)
+
+			if _, ok := recipientDevicePointersMap[clientPointer]; !ok {
+				recipientClientPointers = append(recipientClientPointers, clientPointer)
+			}
+
+			recipientDevicePointersMap[clientPointer] = append(recipientDevicePointersMap[clientPointer], devicePointer)
+		}
+	}
+
+	fanOut := 0 // 廣播對象數
+
+	for _, clientPointer := range recipientClientPointers { // 針對每一個廣播對象
+
+		// 進行廣播:(此處仍使用Marshal工具轉型，因考量有 Device[] 陣列形態，轉成string較為複雜。)
+		jsonBytes, jsonMarshalError := json.Marshal(DeviceStatusChangeByPointer{Command: CommandNumberOfBroadcastingInArea, CommandType: CommandTypeNumberOfBroadcast, DevicePointer: recipientDevicePointersMap[clientPointer]})
+
+		if nil != jsonMarshalError { // 若後端json轉換出錯
+
+			// 取得記錄器格式字串與參數
+			formatString, args := logings.GetLogFuncFormatAndArguments(
+				[]string{`%s 廣播裝置變更 `},
+				[]interface{}{whatKindCommandString},
+				jsonMarshalError,
+			)
+
+			logger.Errorf(formatString, args...) // 記錄錯誤
+			return                               // 回傳
+		}
+
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes} // 廣播(不排除任何連線)
+		fanOut++
+	}
+
+	recordBroadcastFanOutMetrics(broadcastKindOfArea, fanOut) // 記錄廣播對象數
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`%s 對 %d 個連線廣播 %d 台裝置變更 `},
+		[]interface{}{whatKindCommandString, fanOut, len(changedDevicePointers)},
+		nil,
+	)
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+}
+
+// syncAllDevicesList - 從裝置儲存庫同步所有裝置清單，並廣播變更
+func syncAllDevicesList() {
+
+	deviceRecords, loadError := deviceRepositoryValue.loadAllDeviceRecords() // 載入所有裝置儲存資料
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`從裝置儲存庫同步裝置 %d 筆 `},
+		[]interface{}{len(deviceRecords)},
+		loadError,
+	)
+
+	if nil != loadError { // 若載入錯誤，保留原本裝置清單
+		logger.Errorf(formatString, args...) // 記錄錯誤
+		return                               // 回傳
+	}
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+
+	changedDevicePointers := mergeDeviceRecords(deviceRecords) // 合併到所有裝置清單
+
+	sessionRegistryPointer.reindexAll() // 裝置類型可能已改變，更新連線索引
+
+	if len(changedDevicePointers) > 0 { // 若裝置清單有變更，則廣播
+		broadcastChangedDevices(changedDevicePointers)
+	}
+
+}
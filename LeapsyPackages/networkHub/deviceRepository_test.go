@@ -0,0 +1,81 @@
+package networkHub
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestMergeDeviceRecordsKeepsOnlineDevices - 同步時連線中的裝置保留原本的裝置，離線且變更的裝置以新裝置取代
+func TestMergeDeviceRecordsKeepsOnlineDevices(t *testing.T) {
+
+	defer func(originalSessionRegistryPointer *SessionRegistry, originalAllDevicePointerList []*Device) {
+		sessionRegistryPointer = originalSessionRegistryPointer
+		allDevicePointerList = originalAllDevicePointerList
+	}(sessionRegistryPointer, allDevicePointerList)
+
+	sessionRegistryPointer = NewSessionRegistry()
+
+	onlineDevicePointer := &Device{DeviceID: `online`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceName: `舊名稱`}
+	offlineDevicePointer := &Device{DeviceID: `offline`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceName: `舊名稱`}
+	allDevicePointerList = []*Device{onlineDevicePointer, offlineDevicePointer}
+
+	sessionRegistryPointer.setInfoPointer(&client{}, &Info{AccountPointer: &Account{UserID: `user`}, DevicePointer: onlineDevicePointer})
+
+	changedDevicePointers := mergeDeviceRecords([]deviceRecord{
+		{DeviceID: `online`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceName: `新名稱`},
+		{DeviceID: `offline`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceName: `新名稱`},
+	})
+
+	if 1 != len(changedDevicePointers) || `offline` != changedDevicePointers[0].DeviceID {
+		t.Fatalf(`變更的裝置 %d 台，預期只有離線的裝置`, len(changedDevicePointers))
+	}
+
+	if allDevicePointerList[0] != onlineDevicePointer {
+		t.Errorf(`連線中的裝置被取代`)
+	}
+
+	if allDevicePointerList[1] == offlineDevicePointer || `新名稱` != allDevicePointerList[1].DeviceName {
+		t.Errorf(`離線的裝置未以新裝置取代`)
+	}
+
+}
+
+// TestSetInfoPointerWithListedDeviceDuringSync - 登入與同步同時進行時，登記的裝置一定是同步後清單中的裝置
+func TestSetInfoPointerWithListedDeviceDuringSync(t *testing.T) {
+
+	defer func(originalSessionRegistryPointer *SessionRegistry, originalAllDevicePointerList []*Device) {
+		sessionRegistryPointer = originalSessionRegistryPointer
+		allDevicePointerList = originalAllDevicePointerList
+	}(sessionRegistryPointer, allDevicePointerList)
+
+	for round := 0; round < 200; round++ {
+
+		sessionRegistryPointer = NewSessionRegistry()
+
+		stalePointer := &Device{DeviceID: `d1`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceName: `舊名稱`}
+		allDevicePointerList = []*Device{stalePointer}
+
+		clientPointer := &client{}
+
+		var waitGroup sync.WaitGroup
+		waitGroup.Add(2)
+
+		go func() {
+			defer waitGroup.Done()
+			setInfoPointerWithListedDevice(clientPointer, &Info{AccountPointer: &Account{UserID: `user`}, DevicePointer: stalePointer}) // 登入前查到的是舊裝置
+		}()
+
+		go func() {
+			defer waitGroup.Done()
+			mergeDeviceRecords([]deviceRecord{{DeviceID: `d1`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceName: `新名稱`}})
+		}()
+
+		waitGroup.Wait()
+
+		if listedDevicePointers := getAllDevicePointerList(); sessionRegistryPointer.getInfoPointer(clientPointer).DevicePointer != listedDevicePointers[0] {
+			t.Fatalf(`第 %d 次:登記的裝置不在裝置清單中`, round)
+		}
+
+	}
+
+}
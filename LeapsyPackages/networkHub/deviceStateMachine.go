@@ -0,0 +1,448 @@
+package networkHub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"../logings"
+	"github.com/gobwas/ws"
+)
+
+// DeviceStatusCode - 設備狀態
+type DeviceStatusCode int
+
+const (
+	DeviceStatusOffline       DeviceStatusCode = 0 // 離線(未設定)
+	DeviceStatusIdle          DeviceStatusCode = 1 // 閒置
+	DeviceStatusAskingForHelp DeviceStatusCode = 2 // 求助中
+	DeviceStatusInCall        DeviceStatusCode = 3 // 通話中
+)
+
+// String - 設備狀態名稱(for log)
+/**
+ * @return string 設備狀態名稱
+ */
+func (deviceStatus DeviceStatusCode) String() string {
+
+	switch deviceStatus {
+	case DeviceStatusOffline:
+		return `離線`
+	case DeviceStatusIdle:
+		return `閒置`
+	case DeviceStatusAskingForHelp:
+		return `求助中`
+	case DeviceStatusInCall:
+		return `通話中`
+	}
+
+	return fmt.Sprintf(`未知狀態(%d)`, int(deviceStatus))
+}
+
+// OnlineStatusCode - 在線狀態
+type OnlineStatusCode int
+
+const (
+	OnlineStatusOnline  OnlineStatusCode = 1 // 上線
+	OnlineStatusOffline OnlineStatusCode = 2 // 離線
+)
+
+// deviceStatusEvent - 改變設備狀態的事件
+type deviceStatusEvent int
+
+const (
+	deviceStatusEventLogin         deviceStatusEvent = iota + 1 // 登入(重複登入時不論原本狀態皆重設為閒置)
+	deviceStatusEventOffline                                    // 登出、斷線或逾時
+	deviceStatusEventAskForHelp                                 // 求助(求助中可再次求助以更新求助內容)
+	deviceStatusEventCancelHelp                                 // 取消求助
+	deviceStatusEventHelpTimeout                                // 求助逾時
+	deviceStatusEventHelpAnswered                               // 求助者:求助被回應
+	deviceStatusEventAnswerHelp                                 // 回應者:回應求助
+	deviceStatusEventJoinRoom                                   // 加入房間
+	deviceStatusEventLeaveCall                                  // 掛斷通話、離開房間或通話結束
+	deviceStatusEventWaitForExpert                              // 房間內已沒有專家，一線人員回到求助中
+)
+
+// deviceStatusTransitionRule - 設備狀態轉換規則
+type deviceStatusTransitionRule struct {
+	name         string             // 事件名稱
+	fromStatuses []DeviceStatusCode // 允許的原本狀態
+	toStatus     DeviceStatusCode   // 轉換後狀態
+}
+
+// 所有設備狀態轉換規則(未列出的轉換一律拒絕)
+var deviceStatusTransitionRuleMap = map[deviceStatusEvent]deviceStatusTransitionRule{
+	deviceStatusEventLogin: {
+		name:         `登入`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusOffline, DeviceStatusIdle, DeviceStatusAskingForHelp, DeviceStatusInCall},
+		toStatus:     DeviceStatusIdle,
+	},
+	deviceStatusEventOffline: {
+		name:         `離線`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusOffline, DeviceStatusIdle, DeviceStatusAskingForHelp, DeviceStatusInCall},
+		toStatus:     DeviceStatusOffline,
+	},
+	deviceStatusEventAskForHelp: {
+		name:         `求助`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusIdle, DeviceStatusAskingForHelp},
+		toStatus:     DeviceStatusAskingForHelp,
+	},
+	deviceStatusEventCancelHelp: {
+		name:         `取消求助`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusAskingForHelp},
+		toStatus:     DeviceStatusIdle,
+	},
+	deviceStatusEventHelpTimeout: {
+		name:         `求助逾時`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusAskingForHelp},
+		toStatus:     DeviceStatusIdle,
+	},
+	deviceStatusEventHelpAnswered: {
+		name:         `求助被回應`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusAskingForHelp},
+		toStatus:     DeviceStatusInCall,
+	},
+	deviceStatusEventAnswerHelp: {
+		name:         `回應求助`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusIdle},
+		toStatus:     DeviceStatusInCall,
+	},
+	deviceStatusEventJoinRoom: {
+		name:         `加入房間`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusIdle, DeviceStatusAskingForHelp},
+		toStatus:     DeviceStatusInCall,
+	},
+	deviceStatusEventLeaveCall: {
+		name:         `離開通話`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusAskingForHelp, DeviceStatusInCall},
+		toStatus:     DeviceStatusIdle,
+	},
+	deviceStatusEventWaitForExpert: {
+		name:         `等待其他專家`,
+		fromStatuses: []DeviceStatusCode{DeviceStatusInCall},
+		toStatus:     DeviceStatusAskingForHelp,
+	},
+}
+
+// DeviceStatusTransition - 已完成的設備狀態轉換(傳給掛勾)
+type DeviceStatusTransition struct {
+	ActorClientPointer *client           // 發起轉換的連線(廣播時排除，可為nil)
+	DevicePointer      *Device           // 裝置指標
+	Event              deviceStatusEvent // 事件
+	EventName          string            // 事件名稱
+	FromStatus         DeviceStatusCode  // 原本狀態
+	ToStatus           DeviceStatusCode  // 轉換後狀態
+	FromRoomID         int               // 原本房號
+	ToRoomID           int               // 轉換後房號
+	Details            string            // 說明(寫入稽核紀錄)
+}
+
+// deviceStatusHookFunc - 設備狀態轉換後執行的掛勾
+type deviceStatusHookFunc func(transition DeviceStatusTransition)
+
+var (
+	deviceStatusReadWriteLock = new(sync.RWMutex) // 設備狀態讀寫鎖(檢查、轉換與附帶變更在同一把鎖內完成)
+
+	deviceStatusHooks = []deviceStatusHookFunc{} // 設備狀態轉換掛勾(依註冊順序執行)
+
+	errDeviceStatusNotAllowed = errors.New(`裝置目前狀態不允許此操作`) // 不允許的轉換(所有轉換錯誤皆包裝此錯誤)
+)
+
+// init - 初始函式
+func init() {
+	registerDeviceStatusHook(logDeviceStatusTransition)       // 記錄狀態轉換
+	registerDeviceStatusHook(updateIdleSinceTime)             // 記錄開始閒置時間
+	registerDeviceStatusHook(removeHelpRequestIfNotAsking)    // 不再求助則移出求助佇列
+	registerDeviceStatusHook(broadcastDeviceStatusTransition) // 廣播狀態轉換
+	registerDeviceStatusHook(recordDeviceStatusAudit)         // 記錄求助相關稽核紀錄
+}
+
+// registerDeviceStatusHook - 註冊設備狀態轉換掛勾
+/**
+ * @param deviceStatusHookFunc hook 掛勾
+ */
+func registerDeviceStatusHook(hook deviceStatusHookFunc) {
+	deviceStatusHooks = append(deviceStatusHooks, hook)
+}
+
+// getDeviceStatusTransitionError - 取得不允許轉換的錯誤
+/**
+ * @param DeviceStatusCode fromStatus 原本狀態
+ * @param deviceStatusEvent event 事件
+ * @return error 錯誤
+ */
+func getDeviceStatusTransitionError(fromStatus DeviceStatusCode, event deviceStatusEvent) error {
+
+	rule, ok := deviceStatusTransitionRuleMap[event]
+
+	if !ok {
+		return fmt.Errorf(`%w:未定義的設備狀態事件%d`, errDeviceStatusNotAllowed, int(event))
+	}
+
+	return fmt.Errorf(`%w:設備狀態為%s,不可%s`, errDeviceStatusNotAllowed, fromStatus, rule.name)
+}
+
+// isDeviceStatusTransitionAllowed - 判斷裝置目前狀態是否允許此事件
+/**
+ * @param DeviceStatusCode fromStatus 原本狀態
+ * @param deviceStatusEvent event 事件
+ * @return bool 是否允許
+ */
+func isDeviceStatusTransitionAllowed(fromStatus DeviceStatusCode, event deviceStatusEvent) bool {
+
+	if rule, ok := deviceStatusTransitionRuleMap[event]; ok {
+		for _, allowedStatus := range rule.fromStatuses {
+			if fromStatus == allowedStatus {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkDeviceStatusTransition - 檢查裝置能否進行此事件(不改變狀態，用於一次改變多台裝置前的檢核)
+/**
+ * @param *Device devicePointer 裝置指標
+ * @param deviceStatusEvent event 事件
+ * @return error returnError 不允許時的錯誤
+ */
+func checkDeviceStatusTransition(devicePointer *Device, event deviceStatusEvent) (returnError error) {
+
+	deviceStatusReadWriteLock.RLock() // 讀鎖
+
+	if !isDeviceStatusTransitionAllowed(devicePointer.DeviceStatus, event) {
+		returnError = getDeviceStatusTransitionError(devicePointer.DeviceStatus, event)
+	}
+
+	deviceStatusReadWriteLock.RUnlock() // 解開讀鎖
+
+	return // 回傳
+}
+
+// getDeviceCopy - 在設備狀態讀鎖內複製裝置(回應或廣播時使用，避免與狀態轉換的附帶變更交錯)
+/**
+ * @param *Device devicePointer 裝置指標
+ * @return Device returnDevice 裝置複本
+ */
+func getDeviceCopy(devicePointer *Device) (returnDevice Device) {
+
+	deviceStatusReadWriteLock.RLock() // 讀鎖
+	returnDevice = *devicePointer
+	deviceStatusReadWriteLock.RUnlock() // 解開讀鎖
+
+	return // 回傳
+}
+
+// applyDeviceChange - 在設備狀態寫鎖內套用不改變設備狀態的變更(如相機、麥克風、場域)，與狀態轉換的附帶變更互斥
+/**
+ * @param func() applyFunc 變更(不可再改變或檢查設備狀態)
+ */
+func applyDeviceChange(applyFunc func()) {
+	deviceStatusReadWriteLock.Lock()   // 寫鎖
+	applyFunc()                        // 套用變更
+	deviceStatusReadWriteLock.Unlock() // 解開寫鎖
+}
+
+// changeDeviceStatus - 依事件改變設備狀態(不允許的轉換回傳錯誤且不改變狀態)，成功後套用附帶變更並執行所有掛勾
+/**
+ * 房號改變時，呼叫端需在轉換後才更新索引(一次轉換多台裝置時在全部轉換後才更新)，離開房間的參與者才收得到房間廣播
+ * @param *client actorClientPointer 發起轉換的連線(廣播時排除，可為nil)
+ * @param *Device devicePointer 裝置指標
+ * @param deviceStatusEvent event 事件
+ * @param func() applyFunc 轉換成功後、執行掛勾前一併套用的變更(如房號、截圖、相機與麥克風)，在設備狀態鎖內執行，不可再改變或檢查設備狀態，可為nil
+ * @param string details 說明(寫入稽核紀錄)
+ * @return error returnError 不允許時的錯誤
+ */
+func changeDeviceStatus(actorClientPointer *client, devicePointer *Device, event deviceStatusEvent, applyFunc func(), details string) (returnError error) {
+
+	deviceStatusReadWriteLock.Lock() // 寫鎖
+
+	fromStatus := devicePointer.DeviceStatus
+
+	if !isDeviceStatusTransitionAllowed(fromStatus, event) {
+		deviceStatusReadWriteLock.Unlock() // 解開寫鎖
+		returnError = getDeviceStatusTransitionError(fromStatus, event)
+		return // 回傳
+	}
+
+	rule := deviceStatusTransitionRuleMap[event]
+
+	devicePointer.DeviceStatus = rule.toStatus
+
+	if DeviceStatusOffline == rule.toStatus {
+		devicePointer.OnlineStatus = OnlineStatusOffline
+	} else {
+		devicePointer.OnlineStatus = OnlineStatusOnline
+	}
+
+	fromRoomID := devicePointer.RoomID
+
+	if nil != applyFunc {
+		applyFunc() // 套用附帶變更(與狀態在同一把鎖內完成，同一裝置的轉換不會交錯)
+	}
+
+	transition := DeviceStatusTransition{
+		ActorClientPointer: actorClientPointer,
+		DevicePointer:      devicePointer,
+		Event:              event,
+		EventName:          rule.name,
+		FromStatus:         fromStatus,
+		ToStatus:           rule.toStatus,
+		FromRoomID:         fromRoomID,
+		ToRoomID:           devicePointer.RoomID,
+		Details:            details,
+	}
+
+	deviceStatusReadWriteLock.Unlock() // 解開寫鎖(掛勾在鎖外執行)
+
+	for _, hook := range deviceStatusHooks {
+		hook(transition)
+	}
+
+	return // 回傳
+}
+
+// logDeviceStatusTransition - 掛勾:記錄設備狀態轉換
+/**
+ * @param DeviceStatusTransition transition 設備狀態轉換
+ */
+func logDeviceStatusTransition(transition DeviceStatusTransition) {
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`裝置 %s(%s) %s:%s -> %s `},
+		[]interface{}{
+			transition.DevicePointer.DeviceID,
+			transition.DevicePointer.DeviceBrand,
+			transition.EventName,
+			transition.FromStatus.String(),
+			transition.ToStatus.String(),
+		},
+		nil,
+	)
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+}
+
+// updateIdleSinceTime - 掛勾:變為閒置時記錄開始閒置時間
+/**
+ * @param DeviceStatusTransition transition 設備狀態轉換
+ */
+func updateIdleSinceTime(transition DeviceStatusTransition) {
+
+	if DeviceStatusIdle == transition.ToStatus {
+		transition.DevicePointer.idleSinceTime = time.Now()
+	}
+
+}
+
+// removeHelpRequestIfNotAsking - 掛勾:離開求助中狀態時移出求助佇列
+/**
+ * @param DeviceStatusTransition transition 設備狀態轉換
+ */
+func removeHelpRequestIfNotAsking(transition DeviceStatusTransition) {
+
+	if DeviceStatusAskingForHelp == transition.FromStatus && DeviceStatusAskingForHelp != transition.ToStatus {
+		removeHelpRequest(transition.DevicePointer)
+	}
+
+}
+
+// broadcastDeviceStatusTransition - 掛勾:廣播設備狀態轉換(房間廣播給原本與轉換後房間的參與者，場域廣播給裝置所屬場域與其下層場域，皆排除發起轉換的連線)
+/**
+ * @param DeviceStatusTransition transition 設備狀態轉換
+ */
+func broadcastDeviceStatusTransition(transition DeviceStatusTransition) {
+
+	whatKindCommandString := `設備狀態轉換-` + transition.EventName
+
+	devicePointer := transition.DevicePointer
+	deviceArray := getArrayPointer(devicePointer) // 包成array
+
+	details := `-裝置ID=` + devicePointer.DeviceID + `,裝置Brand=` + devicePointer.DeviceBrand
+
+	// 房間廣播:依房號索引，離開房間的參與者(尚未更新索引)也收得到
+	roomIDs := []int{}
+
+	if 0 != transition.FromRoomID {
+		roomIDs = append(roomIDs, transition.FromRoomID)
+	}
+
+	if 0 != transition.ToRoomID && transition.FromRoomID != transition.ToRoomID {
+		roomIDs = append(roomIDs, transition.ToRoomID)
+	}
+
+	if 0 < len(roomIDs) {
+		if jsonBytes, err := json.Marshal(DeviceStatusChangeByPointer{Command: CommandNumberOfBroadcastingInRoom, CommandType: CommandTypeNumberOfBroadcast, DevicePointer: deviceArray}); nil == err {
+			broadcastByRoomIDs(roomIDs, websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}, transition.ActorClientPointer)
+		}
+	}
+
+	// 場域廣播:登入中的裝置依連線取場域(平板端為專家帳號場域)，否則取裝置場域
+	area := devicePointer.Area
+
+	if ownerClientPointer, _, ok := sessionRegistryPointer.getClientPointerByDeviceKey(getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)); ok {
+		area = getMyAreaByClientPointer(whatKindCommandString, Command{}, ownerClientPointer, details)
+	}
+
+	if 0 < len(area) {
+		processBroadcastingDeviceChangeStatusInSomeArea(whatKindCommandString, Command{}, transition.ActorClientPointer, deviceArray, area, details)
+	}
+
+}
+
+// 會寫入稽核紀錄的設備狀態事件
+var deviceStatusAuditEventMap = map[deviceStatusEvent]string{
+	deviceStatusEventAskForHelp:   AuditEventHelpRequested,
+	deviceStatusEventHelpAnswered: AuditEventHelpAnswered,
+	deviceStatusEventCancelHelp:   AuditEventHelpCancelled,
+	deviceStatusEventHelpTimeout:  AuditEventTimeout,
+}
+
+// recordDeviceStatusAudit - 掛勾:記錄求助相關的稽核紀錄(回應求助以發起轉換的回應者記錄，對象為求助者，其餘以裝置目前登入的帳號記錄)
+/**
+ * @param DeviceStatusTransition transition 設備狀態轉換
+ */
+func recordDeviceStatusAudit(transition DeviceStatusTransition) {
+
+	event, ok := deviceStatusAuditEventMap[transition.Event]
+
+	if !ok { // 不需記錄
+		return // 回傳
+	}
+
+	devicePointer := transition.DevicePointer
+	deviceKey := getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)
+
+	var auditRecord AuditRecord // 稽核紀錄
+
+	if deviceStatusEventHelpAnswered == transition.Event {
+
+		auditRecord = newAuditRecordOfClient(event, transition.ActorClientPointer)
+		auditRecord.Target = deviceKey
+
+	} else {
+
+		var accountPointer *Account // 帳號指標
+
+		if _, infoPointer, ok := sessionRegistryPointer.getClientPointerByDeviceKey(deviceKey); ok && nil != infoPointer {
+			accountPointer = infoPointer.AccountPointer
+		}
+
+		auditRecord = newAuditRecord(event, accountPointer, devicePointer)
+
+	}
+
+	auditRecord.RoomID = transition.ToRoomID
+
+	if 0 == auditRecord.RoomID { // 離開房間的轉換以原本房號記錄
+		auditRecord.RoomID = transition.FromRoomID
+	}
+
+	auditRecord.Details = transition.Details
+
+	recordAudit(auditRecord)
+}
@@ -0,0 +1,176 @@
+package networkHub
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// TestChangeDeviceStatus - 依事件改變設備狀態，允許的轉換執行掛勾，不允許的轉換回傳錯誤且不改變狀態
+func TestChangeDeviceStatus(t *testing.T) {
+
+	defer func(originalHooks []deviceStatusHookFunc) { deviceStatusHooks = originalHooks }(deviceStatusHooks)
+
+	testCases := []struct {
+		name             string
+		fromStatus       DeviceStatusCode
+		event            deviceStatusEvent
+		wantAllowed      bool
+		wantStatus       DeviceStatusCode
+		wantOnlineStatus OnlineStatusCode
+	}{
+		{`離線時登入`, DeviceStatusOffline, deviceStatusEventLogin, true, DeviceStatusIdle, OnlineStatusOnline},
+		{`通話中重複登入`, DeviceStatusInCall, deviceStatusEventLogin, true, DeviceStatusIdle, OnlineStatusOnline},
+		{`通話中斷線`, DeviceStatusInCall, deviceStatusEventOffline, true, DeviceStatusOffline, OnlineStatusOffline},
+		{`閒置時求助`, DeviceStatusIdle, deviceStatusEventAskForHelp, true, DeviceStatusAskingForHelp, OnlineStatusOnline},
+		{`求助中再次求助`, DeviceStatusAskingForHelp, deviceStatusEventAskForHelp, true, DeviceStatusAskingForHelp, OnlineStatusOnline},
+		{`求助中取消求助`, DeviceStatusAskingForHelp, deviceStatusEventCancelHelp, true, DeviceStatusIdle, OnlineStatusOnline},
+		{`求助逾時`, DeviceStatusAskingForHelp, deviceStatusEventHelpTimeout, true, DeviceStatusIdle, OnlineStatusOnline},
+		{`求助被回應`, DeviceStatusAskingForHelp, deviceStatusEventHelpAnswered, true, DeviceStatusInCall, OnlineStatusOnline},
+		{`閒置時回應求助`, DeviceStatusIdle, deviceStatusEventAnswerHelp, true, DeviceStatusInCall, OnlineStatusOnline},
+		{`求助中加入房間`, DeviceStatusAskingForHelp, deviceStatusEventJoinRoom, true, DeviceStatusInCall, OnlineStatusOnline},
+		{`通話中離開通話`, DeviceStatusInCall, deviceStatusEventLeaveCall, true, DeviceStatusIdle, OnlineStatusOnline},
+		{`通話中等待其他專家`, DeviceStatusInCall, deviceStatusEventWaitForExpert, true, DeviceStatusAskingForHelp, OnlineStatusOnline},
+		{`離線時不可求助`, DeviceStatusOffline, deviceStatusEventAskForHelp, false, DeviceStatusOffline, OnlineStatusOffline},
+		{`通話中不可求助`, DeviceStatusInCall, deviceStatusEventAskForHelp, false, DeviceStatusInCall, OnlineStatusOnline},
+		{`閒置時不可取消求助`, DeviceStatusIdle, deviceStatusEventCancelHelp, false, DeviceStatusIdle, OnlineStatusOnline},
+		{`通話中不可被回應`, DeviceStatusInCall, deviceStatusEventHelpAnswered, false, DeviceStatusInCall, OnlineStatusOnline},
+		{`求助中不可回應他人求助`, DeviceStatusAskingForHelp, deviceStatusEventAnswerHelp, false, DeviceStatusAskingForHelp, OnlineStatusOnline},
+		{`通話中不可再加入房間`, DeviceStatusInCall, deviceStatusEventJoinRoom, false, DeviceStatusInCall, OnlineStatusOnline},
+		{`離線時不可加入房間`, DeviceStatusOffline, deviceStatusEventJoinRoom, false, DeviceStatusOffline, OnlineStatusOffline},
+		{`閒置時不可離開通話`, DeviceStatusIdle, deviceStatusEventLeaveCall, false, DeviceStatusIdle, OnlineStatusOnline},
+		{`閒置時不可等待其他專家`, DeviceStatusIdle, deviceStatusEventWaitForExpert, false, DeviceStatusIdle, OnlineStatusOnline},
+		{`未定義的事件`, DeviceStatusIdle, deviceStatusEvent(0), false, DeviceStatusIdle, OnlineStatusOnline},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			transitions := []DeviceStatusTransition{} // 掛勾收到的轉換
+
+			// 以記錄用掛勾取代廣播、稽核等掛勾
+			deviceStatusHooks = []deviceStatusHookFunc{func(transition DeviceStatusTransition) { transitions = append(transitions, transition) }}
+
+			onlineStatus := OnlineStatusOnline
+
+			if DeviceStatusOffline == testCase.fromStatus {
+				onlineStatus = OnlineStatusOffline
+			}
+
+			devicePointer := &Device{DeviceID: `d1`, DeviceBrand: `b`, DeviceStatus: testCase.fromStatus, OnlineStatus: onlineStatus}
+
+			isApplied := false
+
+			if checkError := checkDeviceStatusTransition(devicePointer, testCase.event); (nil == checkError) != testCase.wantAllowed {
+				t.Errorf(`checkDeviceStatusTransition() 錯誤 = %v，預期允許 %v`, checkError, testCase.wantAllowed)
+			}
+
+			changeError := changeDeviceStatus(nil, devicePointer, testCase.event, func() { isApplied = true }, ``)
+
+			if testCase.wantAllowed {
+
+				if nil != changeError {
+					t.Fatalf(`changeDeviceStatus() 錯誤 = %v，預期允許`, changeError)
+				}
+
+				if !isApplied || 1 != len(transitions) {
+					t.Fatalf(`附帶變更套用 %v、掛勾執行 %d 次，預期套用且執行 1 次`, isApplied, len(transitions))
+				}
+
+				if transitions[0].FromStatus != testCase.fromStatus || transitions[0].ToStatus != testCase.wantStatus {
+					t.Errorf(`掛勾收到 %s -> %s，預期 %s -> %s`, transitions[0].FromStatus, transitions[0].ToStatus, testCase.fromStatus, testCase.wantStatus)
+				}
+
+			} else {
+
+				if !errors.Is(changeError, errDeviceStatusNotAllowed) {
+					t.Fatalf(`changeDeviceStatus() 錯誤 = %v，預期 %v`, changeError, errDeviceStatusNotAllowed)
+				}
+
+				if isApplied || 0 != len(transitions) {
+					t.Fatalf(`不允許的轉換不應套用附帶變更(%v)或執行掛勾(%d 次)`, isApplied, len(transitions))
+				}
+
+			}
+
+			if devicePointer.DeviceStatus != testCase.wantStatus {
+				t.Errorf(`設備狀態 = %s，預期 %s`, devicePointer.DeviceStatus, testCase.wantStatus)
+			}
+
+			if devicePointer.OnlineStatus != testCase.wantOnlineStatus {
+				t.Errorf(`在線狀態 = %d，預期 %d`, devicePointer.OnlineStatus, testCase.wantOnlineStatus)
+			}
+
+		})
+	}
+
+}
+
+// TestChangeDeviceStatusConcurrently - 同一裝置同時轉換時，附帶變更與狀態一起完成，房號不會與狀態矛盾
+func TestChangeDeviceStatusConcurrently(t *testing.T) {
+
+	defer func(originalHooks []deviceStatusHookFunc) { deviceStatusHooks = originalHooks }(deviceStatusHooks)
+
+	testCases := []struct {
+		name        string
+		workerCount int // 同時轉換的連線數(各自反覆加入、離開不同房間)
+		repeatCount int // 每個連線的轉換次數
+	}{
+		{`兩個連線`, 2, 200},
+		{`多個連線`, 16, 50},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			var transitionsLock sync.Mutex
+			transitions := []DeviceStatusTransition{} // 掛勾收到的轉換
+
+			deviceStatusHooks = []deviceStatusHookFunc{func(transition DeviceStatusTransition) {
+				transitionsLock.Lock()
+				transitions = append(transitions, transition)
+				transitionsLock.Unlock()
+			}}
+
+			devicePointer := &Device{DeviceID: `d1`, DeviceBrand: `b`, DeviceStatus: DeviceStatusIdle, OnlineStatus: OnlineStatusOnline}
+
+			var waitGroup sync.WaitGroup
+
+			for worker := 1; worker <= testCase.workerCount; worker++ {
+
+				waitGroup.Add(1)
+
+				go func(roomID int) {
+
+					defer waitGroup.Done()
+
+					for count := 0; count < testCase.repeatCount; count++ {
+						changeDeviceStatus(nil, devicePointer, deviceStatusEventJoinRoom, func() { devicePointer.RoomID = roomID }, ``)
+						changeDeviceStatus(nil, devicePointer, deviceStatusEventLeaveCall, func() { devicePointer.RoomID = 0 }, ``)
+					}
+
+				}(worker)
+
+			}
+
+			waitGroup.Wait()
+
+			for _, transition := range transitions {
+
+				isJoinConsistent := deviceStatusEventJoinRoom == transition.Event && 0 == transition.FromRoomID && 0 != transition.ToRoomID
+				isLeaveConsistent := deviceStatusEventLeaveCall == transition.Event && 0 != transition.FromRoomID && 0 == transition.ToRoomID
+
+				if !isJoinConsistent && !isLeaveConsistent {
+					t.Fatalf(`%s 房號 %d -> %d，與狀態 %s -> %s 矛盾`, transition.EventName, transition.FromRoomID, transition.ToRoomID, transition.FromStatus, transition.ToStatus)
+				}
+
+			}
+
+			if (DeviceStatusInCall == devicePointer.DeviceStatus) != (0 != devicePointer.RoomID) {
+				t.Errorf(`最後狀態 %s 與房號 %d 矛盾`, devicePointer.DeviceStatus, devicePointer.RoomID)
+			}
+
+		})
+	}
+
+}
@@ -0,0 +1,98 @@
+package networkHub
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"../configurations"
+)
+
+// HealthCheck - 就緒檢查項目結果
+type HealthCheck struct {
+	Name    string `json:"name"`    // 檢查項目名稱
+	IsOK    bool   `json:"isOK"`    // 是否通過
+	Message string `json:"message"` // 說明(數量或錯誤原因)
+}
+
+var (
+	mailHost string // 寄送驗證信的SMTP主機
+	mailPort int    // 寄送驗證信的SMTP埠號
+
+	// 就緒檢查中每一項檢查的逾時
+	healthCheckTimeoutDuration time.Duration
+)
+
+// initializeHealthCheckSettings - 從設定檔載入SMTP主機與就緒檢查逾時
+func initializeHealthCheckSettings() {
+	mailHost = configurations.GetConfigValueOrPanic(`mail`, `host`)
+	mailPort = configurations.GetConfigPositiveIntValueOrPanic(`mail`, `port`)
+	healthCheckTimeoutDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`health`, `check-timeout`)) * time.Millisecond
+}
+
+// newCountHealthCheck - 建立數量檢查結果(數量大於零才通過)
+/**
+ * @param string name 檢查項目名稱
+ * @param int count 數量
+ * @return HealthCheck 檢查結果
+ */
+func newCountHealthCheck(name string, count int) HealthCheck {
+	return HealthCheck{Name: name, IsOK: 0 < count, Message: fmt.Sprintf(`%d 筆`, count)}
+}
+
+// checkMailBackend - 檢查SMTP主機是否可連線
+/**
+ * @return HealthCheck 檢查結果
+ */
+func checkMailBackend() HealthCheck {
+
+	address := net.JoinHostPort(mailHost, strconv.Itoa(mailPort)) // SMTP主機位址
+
+	connection, dialError := net.DialTimeout(`tcp`, address, healthCheckTimeoutDuration) // 連線SMTP主機
+
+	if nil != dialError { // 若連線錯誤
+		return HealthCheck{Name: `mail`, IsOK: false, Message: dialError.Error()}
+	}
+
+	connection.Close() // 只確認可連線，直接中斷
+
+	return HealthCheck{Name: `mail`, IsOK: true, Message: address}
+}
+
+// GetReadinessChecks - 取得就緒檢查結果(設定檔、帳號、裝置、場域、網路中心、SMTP主機)
+/**
+ * @return []HealthCheck returnHealthChecks 各項檢查結果
+ * @return bool returnIsReady 是否全部通過
+ */
+func GetReadinessChecks() (returnHealthChecks []HealthCheck, returnIsReady bool) {
+
+	configHealthCheck := HealthCheck{Name: `config`, IsOK: configurations.IsConfigLoaded(), Message: `已載入`}
+
+	if !configHealthCheck.IsOK {
+		configHealthCheck.Message = `未載入`
+	}
+
+	hubHealthCheck := HealthCheck{Name: `hub`, IsOK: isNetworkHubAlive(healthCheckTimeoutDuration), Message: `運作中`}
+
+	if !hubHealthCheck.IsOK {
+		hubHealthCheck.Message = fmt.Sprintf(`%v 內未回應`, healthCheckTimeoutDuration)
+	}
+
+	returnHealthChecks = []HealthCheck{
+		configHealthCheck,
+		newCountHealthCheck(`accounts`, accountStorePointer.getAccountCount()),
+		newCountHealthCheck(`devices`, len(getAllDevicePointerList())),
+		newCountHealthCheck(`areas`, getAreaCount()),
+		hubHealthCheck,
+		checkMailBackend(),
+	}
+
+	returnIsReady = true
+
+	for _, healthCheck := range returnHealthChecks {
+		returnIsReady = returnIsReady && healthCheck.IsOK
+	}
+
+	return // 回傳
+}
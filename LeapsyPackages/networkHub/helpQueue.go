@@ -0,0 +1,583 @@
+package networkHub
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/juliangruber/go-intersect"
+)
+
+// HelpRequest - 求助排隊中的請求
+type HelpRequest struct {
+	DeviceID      string    `json:"deviceID"`      // 求助者裝置ID
+	DeviceBrand   string    `json:"deviceBrand"`   // 求助者裝置品牌
+	RoomID        int       `json:"roomID"`        // 求助者房號
+	Area          []int     `json:"area"`          // 求助者場域(依場域排隊)
+	Priority      int       `json:"priority"`      // 優先順序(數字越大越優先)
+	RequestedTime time.Time `json:"requestedTime"` // 求助時間
+
+	EscalationLevel int                  `json:"escalationLevel"` // 已升級次數(0為尚未升級)
+	EscalatedArea   []int                `json:"escalatedArea"`   // 升級後加入的場域(自動指派時一併考慮)
+	EscalationSteps []HelpEscalationStep `json:"escalationSteps"` // 升級紀錄
+
+	sequence      int64  // 排隊序號(同優先順序依序號先來先服務)
+	transactionID string // 求助指令的transactionID(逾時回應時帶回)
+}
+
+// HelpEscalationStep - 求助升級紀錄
+type HelpEscalationStep struct {
+	Level         int       `json:"level"`         // 第幾次升級
+	EscalatedTime time.Time `json:"escalatedTime"` // 升級時間
+	Area          []int     `json:"area"`          // 通知的場域
+	UserIDs       []string  `json:"userIDs"`       // 通知的值班專家帳號
+}
+
+// HelpQueuePosition - 廣播-求助排隊位置
+type HelpQueuePosition struct {
+	Command     int `json:"command"`
+	CommandType int `json:"commandType"`
+	RoomID      int `json:"roomID"`      // 求助者房號
+	Position    int `json:"position"`    // 排隊位置(1為下一位)
+	QueueLength int `json:"queueLength"` // 同場域排隊總數
+}
+
+// HelpAssignment - 廣播-自動指派求助給專家
+type HelpAssignment struct {
+	Command     int     `json:"command"`
+	CommandType int     `json:"commandType"`
+	RoomID      int     `json:"roomID"` // 求助者房號
+	Device      *Device `json:"device"` // 求助者裝置
+}
+
+// isBefore - 判斷是否排在另一個求助之前(優先順序高者先，同優先順序先來先服務)
+/**
+ * @param *HelpRequest otherHelpRequestPointer 另一個求助
+ * @return bool 是否排在之前
+ */
+func (helpRequestPointer *HelpRequest) isBefore(otherHelpRequestPointer *HelpRequest) bool {
+
+	if helpRequestPointer.Priority != otherHelpRequestPointer.Priority {
+		return helpRequestPointer.Priority > otherHelpRequestPointer.Priority
+	}
+
+	return helpRequestPointer.sequence < otherHelpRequestPointer.sequence
+}
+
+// HelpQueue - 求助佇列(依場域排隊，同一求助只能被認領一次)
+type HelpQueue struct {
+	readWriteLock *sync.RWMutex // 讀寫鎖
+
+	requestPointerMap map[string]*HelpRequest // 裝置關鍵字對應求助
+	lastSequence      int64                   // 最後配發的排隊序號
+}
+
+// NewHelpQueue - 建立求助佇列
+/**
+ * @return *HelpQueue 求助佇列指標
+ */
+func NewHelpQueue() *HelpQueue {
+	return &HelpQueue{
+		readWriteLock:     new(sync.RWMutex),
+		requestPointerMap: make(map[string]*HelpRequest),
+	}
+}
+
+var (
+	helpQueuePointer = NewHelpQueue() // 求助佇列
+
+	// 是否自動指派求助給同場域閒置最久的專家(設定檔 1開啟 2關閉)
+	isHelpAutoAssignEnabled bool
+
+	errHelpAlreadyClaimed = errors.New(`此求助已被其他專家回應或已取消`)
+	errHelpNotInArea      = errors.New(`此求助不在自己的場域`)
+	errHelpGiverInRoom    = errors.New(`回應者已在房間中`)
+)
+
+// enqueue - 加入求助佇列(已在佇列中則更新資料並重新排隊)
+/**
+ * @param HelpRequest helpRequest 求助
+ */
+func (helpQueuePointer *HelpQueue) enqueue(helpRequest HelpRequest) {
+
+	helpQueuePointer.readWriteLock.Lock()         // 寫鎖
+	defer helpQueuePointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	helpQueuePointer.lastSequence++
+
+	helpRequest.sequence = helpQueuePointer.lastSequence
+	helpRequest.Area = append([]int{}, helpRequest.Area...)
+
+	if helpRequest.RequestedTime.IsZero() {
+		helpRequest.RequestedTime = time.Now()
+	}
+
+	helpQueuePointer.requestPointerMap[getDeviceKey(helpRequest.DeviceID, helpRequest.DeviceBrand)] = &helpRequest
+}
+
+// remove - 移除求助(取消求助、離線時)
+/**
+ * @param string deviceKey 求助者裝置關鍵字
+ * @return bool 是否有移除
+ */
+func (helpQueuePointer *HelpQueue) remove(deviceKey string) bool {
+
+	helpQueuePointer.readWriteLock.Lock()         // 寫鎖
+	defer helpQueuePointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	_, ok := helpQueuePointer.requestPointerMap[deviceKey]
+	delete(helpQueuePointer.requestPointerMap, deviceKey)
+
+	return ok
+}
+
+// claim - 認領求助(檢查與移除在同一把鎖內完成，同一求助只有一位專家能認領成功)
+/**
+ * @param string deviceKey 求助者裝置關鍵字
+ * @return HelpRequest returnHelpRequest 被認領的求助
+ * @return bool returnIsClaimed 是否認領成功
+ */
+func (helpQueuePointer *HelpQueue) claim(deviceKey string) (returnHelpRequest HelpRequest, returnIsClaimed bool) {
+
+	helpQueuePointer.readWriteLock.Lock()         // 寫鎖
+	defer helpQueuePointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	helpRequestPointer, ok := helpQueuePointer.requestPointerMap[deviceKey]
+
+	if !ok { // 已被認領或已取消
+		return // 回傳
+	}
+
+	delete(helpQueuePointer.requestPointerMap, deviceKey)
+
+	returnHelpRequest = *helpRequestPointer
+	returnIsClaimed = true
+
+	return // 回傳
+}
+
+// get - 取得排隊中的求助(不移出佇列，用於認領前的檢核)
+/**
+ * @param string deviceKey 求助者裝置關鍵字
+ * @return HelpRequest returnHelpRequest 求助
+ * @return bool returnOK 是否在佇列中
+ */
+func (helpQueuePointer *HelpQueue) get(deviceKey string) (returnHelpRequest HelpRequest, returnOK bool) {
+
+	helpQueuePointer.readWriteLock.RLock()         // 讀鎖
+	defer helpQueuePointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	helpRequestPointer, ok := helpQueuePointer.requestPointerMap[deviceKey]
+
+	if !ok {
+		return // 回傳
+	}
+
+	returnHelpRequest = *helpRequestPointer
+	returnHelpRequest.Area = append([]int{}, helpRequestPointer.Area...)
+	returnHelpRequest.EscalatedArea = append([]int{}, helpRequestPointer.EscalatedArea...)
+	returnHelpRequest.EscalationSteps = append([]HelpEscalationStep{}, helpRequestPointer.EscalationSteps...)
+	returnOK = true
+
+	return // 回傳
+}
+
+// requeue - 將認領後指派失敗的求助放回佇列(保留原排隊序號與求助時間；已重新求助則不放回)
+/**
+ * @param HelpRequest helpRequest 被認領的求助
+ * @return bool 是否有放回
+ */
+func (helpQueuePointer *HelpQueue) requeue(helpRequest HelpRequest) bool {
+
+	helpQueuePointer.readWriteLock.Lock()         // 寫鎖
+	defer helpQueuePointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	deviceKey := getDeviceKey(helpRequest.DeviceID, helpRequest.DeviceBrand)
+
+	if _, ok := helpQueuePointer.requestPointerMap[deviceKey]; ok { // 已重新求助，以新的求助為準
+		return false
+	}
+
+	helpQueuePointer.requestPointerMap[deviceKey] = &helpRequest
+
+	return true
+}
+
+// getPosition - 取得求助的排隊位置(只與有相同場域的求助比較)
+/**
+ * @param string deviceKey 求助者裝置關鍵字
+ * @return int returnPosition 排隊位置(1為下一位)
+ * @return int returnQueueLength 有相同場域的排隊總數
+ * @return bool returnOK 是否在佇列中
+ */
+func (helpQueuePointer *HelpQueue) getPosition(deviceKey string) (returnPosition int, returnQueueLength int, returnOK bool) {
+
+	helpQueuePointer.readWriteLock.RLock()         // 讀鎖
+	defer helpQueuePointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	helpRequestPointer, ok := helpQueuePointer.requestPointerMap[deviceKey]
+
+	if !ok {
+		return // 回傳
+	}
+
+	returnOK = true
+	returnPosition = 1
+
+	for _, otherHelpRequestPointer := range helpQueuePointer.requestPointerMap {
+
+		if 0 == len(intersect.Hash(helpRequestPointer.Area, otherHelpRequestPointer.Area)) { // 不同場域不互相影響
+			continue
+		}
+
+		returnQueueLength++
+
+		if otherHelpRequestPointer != helpRequestPointer && otherHelpRequestPointer.isBefore(helpRequestPointer) {
+			returnPosition++
+		}
+
+	}
+
+	return // 回傳
+}
+
+// getHelpRequests - 取得所有求助(依排隊順序)
+/**
+ * @return []HelpRequest returnHelpRequests 所有求助
+ */
+func (helpQueuePointer *HelpQueue) getHelpRequests() (returnHelpRequests []HelpRequest) {
+
+	helpQueuePointer.readWriteLock.RLock() // 讀鎖
+
+	helpRequestPointers := []*HelpRequest{}
+
+	for _, helpRequestPointer := range helpQueuePointer.requestPointerMap {
+		helpRequestPointers = append(helpRequestPointers, helpRequestPointer)
+	}
+
+	sort.Slice(helpRequestPointers, func(i, j int) bool {
+		return helpRequestPointers[i].isBefore(helpRequestPointers[j])
+	})
+
+	for _, helpRequestPointer := range helpRequestPointers {
+		helpRequest := *helpRequestPointer
+		helpRequest.Area = append([]int{}, helpRequestPointer.Area...)
+		helpRequest.EscalatedArea = append([]int{}, helpRequestPointer.EscalatedArea...)
+		helpRequest.EscalationSteps = append([]HelpEscalationStep{}, helpRequestPointer.EscalationSteps...)
+		returnHelpRequests = append(returnHelpRequests, helpRequest)
+	}
+
+	helpQueuePointer.readWriteLock.RUnlock() // 解開讀鎖
+
+	return // 回傳
+}
+
+// escalate - 記錄求助升級(求助仍在佇列中且尚未升級到此次數才會記錄)
+/**
+ * @param string deviceKey 求助者裝置關鍵字
+ * @param HelpEscalationStep step 升級紀錄
+ * @return bool 是否有記錄
+ */
+func (helpQueuePointer *HelpQueue) escalate(deviceKey string, step HelpEscalationStep) bool {
+
+	helpQueuePointer.readWriteLock.Lock()         // 寫鎖
+	defer helpQueuePointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	helpRequestPointer, ok := helpQueuePointer.requestPointerMap[deviceKey]
+
+	if !ok || helpRequestPointer.EscalationLevel >= step.Level { // 已被認領、已取消或已升級過
+		return false
+	}
+
+	helpRequestPointer.EscalationLevel = step.Level
+	helpRequestPointer.EscalatedArea = append(helpRequestPointer.EscalatedArea, step.Area...)
+	helpRequestPointer.EscalationSteps = append(helpRequestPointer.EscalationSteps, step)
+
+	return true
+}
+
+// enqueueHelpRequest - 將求助者裝置加入求助佇列
+/**
+ * @param *Info infoPointer 求助者登入資訊
+ * @param int priority 優先順序
+ * @param string transactionID 求助指令的transactionID
+ */
+func enqueueHelpRequest(infoPointer *Info, priority int, transactionID string) {
+
+	if nil == infoPointer || nil == infoPointer.DevicePointer {
+		return // 回傳
+	}
+
+	devicePointer := infoPointer.DevicePointer
+
+	helpQueuePointer.enqueue(HelpRequest{
+		DeviceID:    devicePointer.DeviceID,
+		DeviceBrand: devicePointer.DeviceBrand,
+		RoomID:      devicePointer.RoomID,
+		Area:        getSessionIndexKeys(infoPointer).area, // 眼鏡端依裝置場域，平板端依帳號場域
+		Priority:    priority,
+
+		transactionID: transactionID,
+	})
+
+}
+
+// removeHelpRequest - 將裝置移出求助佇列，並通知其他排隊者新的位置
+/**
+ * @param *Device devicePointer 裝置指標
+ */
+func removeHelpRequest(devicePointer *Device) {
+
+	if nil == devicePointer {
+		return // 回傳
+	}
+
+	if helpQueuePointer.remove(getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)) {
+		pushHelpQueuePositions()
+	}
+
+}
+
+// pushHelpQueuePositions - 推播目前排隊位置給所有排隊中的求助者
+func pushHelpQueuePositions() {
+
+	for _, helpRequest := range helpQueuePointer.getHelpRequests() {
+
+		deviceKey := getDeviceKey(helpRequest.DeviceID, helpRequest.DeviceBrand)
+
+		position, queueLength, ok := helpQueuePointer.getPosition(deviceKey)
+
+		if !ok { // 已被認領或已取消
+			continue
+		}
+
+		clientPointer, _, ok := sessionRegistryPointer.getClientPointerByDeviceKey(deviceKey)
+
+		if !ok { // 求助者已不在線上
+			continue
+		}
+
+		if jsonBytes, err := json.Marshal(HelpQueuePosition{
+			Command:     CommandNumberOfHelpQueuePosition,
+			CommandType: CommandTypeNumberOfBroadcast,
+			RoomID:      helpRequest.RoomID,
+			Position:    position,
+			QueueLength: queueLength,
+		}); nil == err {
+			clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+		}
+
+	}
+
+}
+
+// getLongestIdleExpertClientPointer - 取得某場域閒置最久的線上專家連線
+/**
+ * @param []int area 場域代號
+ * @param string whatKindCommandString 是哪個指令呼叫此函數 (for log)
+ * @param Command command 客戶端的指令 (for log)
+ * @param *client clientPointer 連線指標 (for log)
+ * @return *client returnClientPointer 專家連線(找不到為nil)
+ * @return *Info returnInfoPointer 專家登入資訊
+ */
+func getLongestIdleExpertClientPointer(area []int, whatKindCommandString string, command Command, clientPointer *client) (returnClientPointer *client, returnInfoPointer *Info) {
+
+	for expertClientPointer, expertInfoPointer := range getOnlineIdleExpertInfoMapInArea(area, whatKindCommandString, command, clientPointer) {
+
+		if nil == returnInfoPointer || expertInfoPointer.DevicePointer.idleSinceTime.Before(returnInfoPointer.DevicePointer.idleSinceTime) {
+			returnClientPointer = expertClientPointer
+			returnInfoPointer = expertInfoPointer
+		}
+
+	}
+
+	return // 回傳
+}
+
+// processAutoAssignHelpRequests - 依排隊順序，將求助自動指派給同場域閒置最久的專家
+/**
+ * @param string whatKindCommandString 是哪個指令呼叫此函數 (for log)
+ * @param Command command 客戶端的指令 (for log)
+ * @param *client clientPointer 連線指標 (for log)
+ */
+func processAutoAssignHelpRequests(whatKindCommandString string, command Command, clientPointer *client) {
+
+	if !isHelpAutoAssignEnabled { // 未開啟自動指派
+		return // 回傳
+	}
+
+	isAssigned := false // 是否有指派
+
+	for _, helpRequest := range helpQueuePointer.getHelpRequests() {
+
+		area := append(helpRequest.Area, helpRequest.EscalatedArea...) // 升級後也可指派給升級場域的專家
+
+		giverClientPointer, giverInfoPointer := getLongestIdleExpertClientPointer(area, whatKindCommandString, command, clientPointer)
+
+		if nil == giverClientPointer { // 此場域沒有閒置專家
+			continue
+		}
+
+		askerDevicePointer := getDevice(helpRequest.DeviceID, helpRequest.DeviceBrand)
+
+		if nil == askerDevicePointer { // 裝置已不在裝置清單中，留在佇列等待逾時
+			continue
+		}
+
+		if nil != claimHelpRequestForGiver(giverClientPointer, giverInfoPointer, askerDevicePointer) { // 已被其他專家認領、求助者已不在求助中或專家已不閒置
+			continue
+		}
+
+		isAssigned = true
+
+		// 通知專家
+		if jsonBytes, err := json.Marshal(HelpAssignment{
+			Command:     CommandNumberOfHelpAssignment,
+			CommandType: CommandTypeNumberOfBroadcast,
+			RoomID:      askerDevicePointer.RoomID,
+			Device:      askerDevicePointer,
+		}); nil == err {
+			giverClientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+		}
+
+		// logger(狀態轉換掛勾已進行區域廣播)
+		details := `-自動指派求助,(求助者)裝置ID=` + askerDevicePointer.DeviceID + `,(求助者)裝置Brand=` + askerDevicePointer.DeviceBrand +
+			`,(回應者)裝置ID=` + giverInfoPointer.DevicePointer.DeviceID + `,(回應者)裝置Brand=` + giverInfoPointer.DevicePointer.DeviceBrand +
+			`,房號=` + strconv.Itoa(askerDevicePointer.RoomID)
+		loggerFields := getLoggerFields(giverClientPointer) //取當下的記錄欄位
+		processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+	}
+
+	if isAssigned { // 佇列已改變
+		pushHelpQueuePositions()
+	}
+
+}
+
+// checkHelpGiver - 檢查回應者能否回應此求助(回應者不可已在房間中，且需管理求助者的場域或升級場域，或為升級時通知的值班專家)
+/**
+ * @param *Info giverInfoPointer 回應者登入資訊
+ * @param HelpRequest helpRequest 求助
+ * @return error returnError 不可回應時的錯誤
+ */
+func checkHelpGiver(giverInfoPointer *Info, helpRequest HelpRequest) (returnError error) {
+
+	if 0 != giverInfoPointer.DevicePointer.RoomID { // 已在房間中
+		returnError = errHelpGiverInRoom
+		return // 回傳
+	}
+
+	if nil != giverInfoPointer.AccountPointer {
+		for _, step := range helpRequest.EscalationSteps {
+			if containsString(step.UserIDs, giverInfoPointer.AccountPointer.UserID) { // 升級時通知的值班專家不限場域
+				return // 回傳
+			}
+		}
+	}
+
+	area := append(append([]int{}, helpRequest.Area...), helpRequest.EscalatedArea...) // 升級後也可由升級場域的專家回應
+
+	if 0 == len(intersect.Hash(getAreaWithDescendants(getSessionIndexKeys(giverInfoPointer).area), area)) { // 場域沒有交集
+		returnError = errHelpNotInArea
+	}
+
+	return // 回傳
+}
+
+// claimHelpRequestForGiver - 檢核回應者後認領求助並進入通話(回應求助與自動指派共用)，認領後指派失敗則以原排隊序號放回佇列
+/**
+ * @param *client giverClientPointer 回應者連線指標
+ * @param *Info giverInfoPointer 回應者登入資訊
+ * @param *Device askerDevicePointer 求助者裝置
+ * @return error returnError 不可回應、已被認領或狀態不允許時的錯誤(兩者狀態皆不改變)
+ */
+func claimHelpRequestForGiver(giverClientPointer *client, giverInfoPointer *Info, askerDevicePointer *Device) (returnError error) {
+
+	deviceKey := getDeviceKey(askerDevicePointer.DeviceID, askerDevicePointer.DeviceBrand)
+
+	helpRequest, ok := helpQueuePointer.get(deviceKey)
+
+	if !ok { // 已被認領或已取消
+		returnError = errHelpAlreadyClaimed
+		return // 回傳
+	}
+
+	if returnError = checkHelpGiver(giverInfoPointer, helpRequest); nil != returnError {
+		return // 回傳
+	}
+
+	// 認領求助:同一求助只有一位專家能認領成功
+	if helpRequest, ok = helpQueuePointer.claim(deviceKey); !ok {
+		returnError = errHelpAlreadyClaimed
+		return // 回傳
+	}
+
+	if returnError = assignHelpRequestToGiver(giverClientPointer, giverInfoPointer.DevicePointer, askerDevicePointer); nil != returnError {
+		requeueHelpRequestIfAsking(helpRequest, askerDevicePointer) // 求助者仍在求助中則放回佇列，避免無人能再指派
+	}
+
+	return // 回傳
+}
+
+// requeueHelpRequestIfAsking - 求助者仍在求助中時，將被認領的求助放回佇列
+/**
+ * 在設備狀態讀鎖內檢查並放回，取消求助或求助逾時的狀態轉換會在放回之後才移出佇列
+ * @param HelpRequest helpRequest 被認領的求助
+ * @param *Device askerDevicePointer 求助者裝置
+ */
+func requeueHelpRequestIfAsking(helpRequest HelpRequest, askerDevicePointer *Device) {
+
+	deviceStatusReadWriteLock.RLock() // 讀鎖
+
+	isRequeued := DeviceStatusAskingForHelp == askerDevicePointer.DeviceStatus && helpQueuePointer.requeue(helpRequest)
+
+	deviceStatusReadWriteLock.RUnlock() // 解開讀鎖
+
+	if isRequeued {
+		pushHelpQueuePositions() // 佇列已改變，通知排隊者
+	}
+
+}
+
+// assignHelpRequestToGiver - 設定求助者與回應者進入通話(回應求助與自動指派共用)
+/**
+ * @param *client giverClientPointer 回應者連線指標
+ * @param *Device giverDevicePointer 回應者裝置
+ * @param *Device askerDevicePointer 求助者裝置
+ * @return error returnError 狀態不允許時的錯誤(兩者狀態皆不改變)
+ */
+func assignHelpRequestToGiver(giverClientPointer *client, giverDevicePointer *Device, askerDevicePointer *Device) (returnError error) {
+
+	// 設定:回應者設備狀態(需為閒置)+房間
+	if returnError = changeDeviceStatus(giverClientPointer, giverDevicePointer, deviceStatusEventAnswerHelp, func() {
+		giverDevicePointer.CameraStatus = 1                   // 預設開啟相機
+		giverDevicePointer.MicStatus = 1                      // 預設開啟麥克風
+		giverDevicePointer.RoomID = askerDevicePointer.RoomID // 求助者roomID
+	}, ``); nil != returnError {
+		return // 回傳
+	}
+
+	// 設定:求助者設備狀態(需為求助中)，狀態轉換掛勾會以回應者記錄回應求助的稽核紀錄
+	if returnError = changeDeviceStatus(giverClientPointer, askerDevicePointer, deviceStatusEventHelpAnswered, func() {
+		askerDevicePointer.CameraStatus = 1 // 預設開啟相機
+		askerDevicePointer.MicStatus = 1    // 預設開啟麥克風
+	}, ``); nil != returnError {
+
+		// 回應者回到閒置並離開房間
+		changeDeviceStatus(giverClientPointer, giverDevicePointer, deviceStatusEventLeaveCall, func() {
+			giverDevicePointer.CameraStatus = 0 // 關閉
+			giverDevicePointer.MicStatus = 0    // 關閉
+			giverDevicePointer.RoomID = 0       // 沒有房間
+		}, ``)
+
+		return // 回傳
+	}
+
+	sessionRegistryPointer.reindexClient(giverClientPointer) // 房號已改變，更新索引
+
+	return // 回傳
+}
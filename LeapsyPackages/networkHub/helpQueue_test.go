@@ -0,0 +1,393 @@
+package networkHub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// testHelpRequest - 測試用的求助(依裝置ID排隊)
+type testHelpRequest struct {
+	deviceID string // 求助者裝置ID(裝置品牌固定為 b)
+	priority int    // 優先順序
+	area     []int  // 求助者場域
+}
+
+// newTestHelpQueue - 依序將求助加入新的求助佇列
+/**
+ * @param []testHelpRequest testHelpRequests 依加入順序排列的求助
+ * @return *HelpQueue 求助佇列指標
+ */
+func newTestHelpQueue(testHelpRequests []testHelpRequest) *HelpQueue {
+
+	testHelpQueuePointer := NewHelpQueue()
+
+	for _, request := range testHelpRequests {
+		testHelpQueuePointer.enqueue(HelpRequest{DeviceID: request.deviceID, DeviceBrand: `b`, Priority: request.priority, Area: request.area})
+	}
+
+	return testHelpQueuePointer
+}
+
+// getHelpRequestDeviceIDs - 取得佇列中所有求助的裝置ID(依排隊順序)
+/**
+ * @param *HelpQueue testHelpQueuePointer 求助佇列指標
+ * @return []string returnDeviceIDs 裝置ID
+ */
+func getHelpRequestDeviceIDs(testHelpQueuePointer *HelpQueue) (returnDeviceIDs []string) {
+
+	for _, helpRequest := range testHelpQueuePointer.getHelpRequests() {
+		returnDeviceIDs = append(returnDeviceIDs, helpRequest.DeviceID)
+	}
+
+	return // 回傳
+}
+
+// TestHelpQueueOrder - 優先順序高者先，同優先順序先來先服務
+func TestHelpQueueOrder(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		requests  []testHelpRequest
+		reAsked   []string // 之後重新求助的裝置ID(重新排隊)
+		wantOrder []string
+	}{
+		{
+			name:      `同優先順序先來先服務`,
+			requests:  []testHelpRequest{{`a`, 0, []int{1}}, {`b`, 0, []int{1}}, {`c`, 0, []int{1}}},
+			wantOrder: []string{`a`, `b`, `c`},
+		},
+		{
+			name:      `優先順序高者先`,
+			requests:  []testHelpRequest{{`a`, 0, []int{1}}, {`b`, 2, []int{1}}, {`c`, 1, []int{1}}},
+			wantOrder: []string{`b`, `c`, `a`},
+		},
+		{
+			name:      `同優先順序中仍先來先服務`,
+			requests:  []testHelpRequest{{`a`, 1, []int{1}}, {`b`, 0, []int{1}}, {`c`, 1, []int{1}}, {`d`, 0, []int{1}}},
+			wantOrder: []string{`a`, `c`, `b`, `d`},
+		},
+		{
+			name:      `重新求助排到同優先順序的最後`,
+			requests:  []testHelpRequest{{`a`, 0, []int{1}}, {`b`, 0, []int{1}}, {`c`, 0, []int{1}}},
+			reAsked:   []string{`a`},
+			wantOrder: []string{`b`, `c`, `a`},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			testHelpQueuePointer := newTestHelpQueue(testCase.requests)
+
+			for _, deviceID := range testCase.reAsked {
+				testHelpQueuePointer.enqueue(HelpRequest{DeviceID: deviceID, DeviceBrand: `b`, Area: []int{1}})
+			}
+
+			if order := getHelpRequestDeviceIDs(testHelpQueuePointer); fmt.Sprint(order) != fmt.Sprint(testCase.wantOrder) {
+				t.Errorf(`排隊順序 = %v，預期 %v`, order, testCase.wantOrder)
+			}
+
+		})
+	}
+
+}
+
+// TestHelpQueuePosition - 排隊位置只與相同場域的求助比較
+func TestHelpQueuePosition(t *testing.T) {
+
+	requests := []testHelpRequest{
+		{`a`, 0, []int{1}},
+		{`b`, 0, []int{2}},
+		{`c`, 1, []int{1}},
+		{`d`, 0, []int{1, 2}},
+		{`e`, 0, []int{3}},
+	}
+
+	testCases := []struct {
+		name            string
+		deviceID        string
+		wantOK          bool
+		wantPosition    int
+		wantQueueLength int
+	}{
+		{`高優先順序排第一`, `c`, true, 1, 3},
+		{`同場域較早求助`, `a`, true, 2, 3},
+		{`屬於兩個場域`, `d`, true, 4, 4},
+		{`其他場域不影響`, `b`, true, 1, 2},
+		{`場域內只有自己`, `e`, true, 1, 1},
+		{`不在佇列中`, `z`, false, 0, 0},
+	}
+
+	testHelpQueuePointer := newTestHelpQueue(requests)
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			position, queueLength, ok := testHelpQueuePointer.getPosition(getDeviceKey(testCase.deviceID, `b`))
+
+			if ok != testCase.wantOK || position != testCase.wantPosition || queueLength != testCase.wantQueueLength {
+				t.Errorf(`getPosition() = (%d, %d, %v)，預期 (%d, %d, %v)`, position, queueLength, ok, testCase.wantPosition, testCase.wantQueueLength, testCase.wantOK)
+			}
+
+		})
+	}
+
+}
+
+// TestHelpQueueClaimConcurrently - 多位專家同時認領同一求助，只有一位成功
+func TestHelpQueueClaimConcurrently(t *testing.T) {
+
+	testCases := []struct {
+		name         string
+		claimerCount int
+		isRemoved    bool // 認領前是否已取消求助
+		wantClaimed  int
+	}{
+		{`一位專家認領`, 1, false, 1},
+		{`兩位專家同時認領`, 2, false, 1},
+		{`多位專家同時認領`, 64, false, 1},
+		{`取消後認領`, 8, true, 0},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			testHelpQueuePointer := newTestHelpQueue([]testHelpRequest{{`a`, 0, []int{1}}, {`b`, 0, []int{1}}})
+
+			deviceKey := getDeviceKey(`a`, `b`)
+
+			if testCase.isRemoved {
+				testHelpQueuePointer.remove(deviceKey)
+			}
+
+			var waitGroup sync.WaitGroup
+			var claimedCountLock sync.Mutex
+			claimedCount := 0
+
+			for index := 0; index < testCase.claimerCount; index++ {
+
+				waitGroup.Add(1)
+
+				go func() {
+
+					defer waitGroup.Done()
+
+					if helpRequest, isClaimed := testHelpQueuePointer.claim(deviceKey); isClaimed {
+
+						claimedCountLock.Lock()
+						claimedCount++
+						claimedCountLock.Unlock()
+
+						if `a` != helpRequest.DeviceID {
+							t.Errorf(`認領到 %s，預期 a`, helpRequest.DeviceID)
+						}
+
+					}
+
+				}()
+
+			}
+
+			waitGroup.Wait()
+
+			if claimedCount != testCase.wantClaimed {
+				t.Errorf(`認領成功 %d 次，預期 %d 次`, claimedCount, testCase.wantClaimed)
+			}
+
+			if order := getHelpRequestDeviceIDs(testHelpQueuePointer); fmt.Sprint(order) != fmt.Sprint([]string{`b`}) {
+				t.Errorf(`認領後佇列 = %v，預期只剩 b`, order)
+			}
+
+		})
+	}
+
+}
+
+// TestHelpQueueRequeue - 指派失敗的求助放回原本的排隊位置，已重新求助則以新的求助為準
+func TestHelpQueueRequeue(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		isReAsked     bool // 放回前是否已重新求助
+		wantRequeued  bool
+		wantOrder     []string
+		wantPriority  int
+		wantPosition  int
+		claimDeviceID string
+	}{
+		{`放回原本的排隊位置`, false, true, []string{`a`, `b`, `c`}, 1, 1, `a`},
+		{`放回中間的排隊位置`, false, true, []string{`a`, `b`, `c`}, 0, 2, `b`},
+		{`已重新求助則不放回`, true, false, []string{`b`, `c`, `a`}, 0, 3, `a`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			testHelpQueuePointer := newTestHelpQueue([]testHelpRequest{{`a`, 1, []int{1}}, {`b`, 0, []int{1}}, {`c`, 0, []int{1}}})
+
+			deviceKey := getDeviceKey(testCase.claimDeviceID, `b`)
+
+			helpRequest, isClaimed := testHelpQueuePointer.claim(deviceKey)
+
+			if !isClaimed {
+				t.Fatalf(`認領 %s 失敗`, testCase.claimDeviceID)
+			}
+
+			if testCase.isReAsked { // 重新求助(優先順序0)
+				testHelpQueuePointer.enqueue(HelpRequest{DeviceID: testCase.claimDeviceID, DeviceBrand: `b`, Area: []int{1}})
+			}
+
+			if isRequeued := testHelpQueuePointer.requeue(helpRequest); isRequeued != testCase.wantRequeued {
+				t.Errorf(`requeue() = %v，預期 %v`, isRequeued, testCase.wantRequeued)
+			}
+
+			if order := getHelpRequestDeviceIDs(testHelpQueuePointer); fmt.Sprint(order) != fmt.Sprint(testCase.wantOrder) {
+				t.Errorf(`排隊順序 = %v，預期 %v`, order, testCase.wantOrder)
+			}
+
+			queuedHelpRequest, _ := testHelpQueuePointer.get(deviceKey)
+
+			if queuedHelpRequest.Priority != testCase.wantPriority {
+				t.Errorf(`優先順序 = %d，預期 %d`, queuedHelpRequest.Priority, testCase.wantPriority)
+			}
+
+			if position, _, _ := testHelpQueuePointer.getPosition(deviceKey); position != testCase.wantPosition {
+				t.Errorf(`排隊位置 = %d，預期 %d`, position, testCase.wantPosition)
+			}
+
+		})
+	}
+
+}
+
+// TestHelpQueueEnqueueConcurrently - 同時求助時排隊序號不重複
+func TestHelpQueueEnqueueConcurrently(t *testing.T) {
+
+	testCases := []struct {
+		name       string
+		askerCount int
+	}{
+		{`少量同時求助`, 4},
+		{`大量同時求助`, 256},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			testHelpQueuePointer := NewHelpQueue()
+
+			var waitGroup sync.WaitGroup
+
+			for index := 0; index < testCase.askerCount; index++ {
+
+				waitGroup.Add(1)
+
+				go func(deviceID string) {
+					defer waitGroup.Done()
+					testHelpQueuePointer.enqueue(HelpRequest{DeviceID: deviceID, DeviceBrand: `b`, Area: []int{1}})
+				}(strconv.Itoa(index))
+
+			}
+
+			waitGroup.Wait()
+
+			positionMap := make(map[int]bool) // 已出現的排隊位置
+
+			for index := 0; index < testCase.askerCount; index++ {
+
+				position, queueLength, ok := testHelpQueuePointer.getPosition(getDeviceKey(strconv.Itoa(index), `b`))
+
+				if !ok || queueLength != testCase.askerCount || positionMap[position] {
+					t.Fatalf(`裝置 %d 排隊位置 = (%d, %d, %v)，位置應不重複且總數為 %d`, index, position, queueLength, ok, testCase.askerCount)
+				}
+
+				positionMap[position] = true
+			}
+
+		})
+	}
+
+}
+
+// TestHandleAskForHelpCommandRoomCheck - 求助房間需是自己建立的或同場域，不可在其他場域的房間求助
+func TestHandleAskForHelpCommandRoomCheck(t *testing.T) {
+
+	defer func(originalSessionRegistryPointer *SessionRegistry, originalRoomManagerPointer *RoomManager, originalHelpQueuePointer *HelpQueue, originalHooks []deviceStatusHookFunc, originalRoomCapacity int) {
+		sessionRegistryPointer = originalSessionRegistryPointer
+		roomManagerPointer = originalRoomManagerPointer
+		helpQueuePointer = originalHelpQueuePointer
+		deviceStatusHooks = originalHooks
+		roomCapacity = originalRoomCapacity
+	}(sessionRegistryPointer, roomManagerPointer, helpQueuePointer, deviceStatusHooks, roomCapacity)
+
+	deviceStatusHooks = []deviceStatusHookFunc{} // 不廣播、不記錄稽核
+	roomCapacity = 2
+
+	testCases := []struct {
+		name             string
+		roomArea         []int    // 房間所屬場域
+		participants     []string // 房間原本的參與者
+		isCreator        bool     // 求助者是否為房間建立者
+		wantResultCode   int
+		wantDeviceStatus DeviceStatusCode
+	}{
+		{`同場域的房間`, []int{1}, []string{}, false, ResultCodeSuccess, DeviceStatusAskingForHelp},
+		{`其他場域的房間`, []int{2}, []string{`other|b`}, false, ResultCodeRoomNotInArea, DeviceStatusIdle},
+		{`自己建立的房間(建立後切換場域)`, []int{2}, []string{}, true, ResultCodeSuccess, DeviceStatusAskingForHelp},
+		{`同場域但人數已滿`, []int{1}, []string{`e1|b`, `e2|b`}, false, ResultCodeRoomFull, DeviceStatusIdle},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			var roomID int
+			roomManagerPointer, roomID = newTestRoom(t, testCase.roomArea, testCase.participants, nil)
+			sessionRegistryPointer = NewSessionRegistry()
+			helpQueuePointer = NewHelpQueue()
+
+			devicePointer := &Device{DeviceID: `asker`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceStatus: DeviceStatusIdle, OnlineStatus: OnlineStatusOnline}
+
+			if testCase.isCreator {
+				roomManagerPointer.roomPointerMap[roomID].CreatorDeviceID = `asker`
+				roomManagerPointer.roomPointerMap[roomID].CreatorDeviceBrand = `b`
+			}
+
+			clientPointer := &client{outputChannel: make(chan websocketData, 8)}
+			sessionRegistryPointer.setInfoPointer(clientPointer, &Info{AccountPointer: &Account{UserID: `frontline@leapsy.com`, Role: RoleFrontline, Area: []int{}}, DevicePointer: devicePointer})
+
+			handleAskForHelpCommand(clientPointer, Command{Command: CommandNumberOfAskForHelp, RoomID: roomID, TransactionID: `t1`}, `求助`)
+
+			response := struct {
+				ResultCode int `json:"resultCode"`
+			}{}
+
+			if unmarshalError := json.Unmarshal((<-clientPointer.outputChannel).dataBytes, &response); nil != unmarshalError {
+				t.Fatalf(`回應格式錯誤: %v`, unmarshalError)
+			}
+
+			if testCase.wantResultCode != response.ResultCode {
+				t.Errorf(`結果代碼 = %d，預期 %d`, response.ResultCode, testCase.wantResultCode)
+			}
+
+			if testCase.wantDeviceStatus != devicePointer.DeviceStatus {
+				t.Errorf(`設備狀態 = %s，預期 %s`, devicePointer.DeviceStatus, testCase.wantDeviceStatus)
+			}
+
+			room, _ := roomManagerPointer.getOpenRoom(roomID)
+			isInRoom := containsString(room.Participants, getDeviceKey(`asker`, `b`))
+
+			if isInRoom != (ResultCodeSuccess == testCase.wantResultCode) {
+				t.Errorf(`求助者是否在房間內 = %v，參與者 %v`, isInRoom, room.Participants)
+			}
+
+			if ResultCodeSuccess != testCase.wantResultCode && (0 != devicePointer.RoomID || 0 != len(sessionRegistryPointer.getClientPointersByRoomID(roomID))) {
+				t.Errorf(`求助失敗後房號 %d，房號索引 %d 個連線，預期皆無`, devicePointer.RoomID, len(sessionRegistryPointer.getClientPointersByRoomID(roomID)))
+			}
+
+		})
+	}
+
+}
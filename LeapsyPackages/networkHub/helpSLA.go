@@ -0,0 +1,283 @@
+package networkHub
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"../configurations"
+	"github.com/gobwas/ws"
+)
+
+// HelpEscalation - 廣播-求助升級通知值班專家
+type HelpEscalation struct {
+	Command     int     `json:"command"`
+	CommandType int     `json:"commandType"`
+	RoomID      int     `json:"roomID"` // 求助者房號
+	Level       int     `json:"level"`  // 第幾次升級
+	Device      *Device `json:"device"` // 求助者裝置
+}
+
+// helpSLA - 求助時限設定
+type helpSLA struct {
+	escalateAfterDuration time.Duration // 無人回應多久後升級
+	timeoutAfterDuration  time.Duration // 無人回應多久後逾時
+	neighbourArea         []int         // 升級時一併通知的鄰近場域
+	onCallUserIDs         []string      // 第二次升級時通知的值班專家帳號
+}
+
+const (
+	helpSLAConfigSectionName = `help-sla` // 求助時限設定區塊名(場域個別設定為 help-sla-場域代號)
+)
+
+var (
+	// 檢查求助時限的間隔
+	helpSLACheckIntervalDuration time.Duration
+)
+
+// initializeHelpSettings - 從設定檔載入求助自動指派與檢查求助時限的間隔(各場域時限於檢查時才讀取)
+func initializeHelpSettings() {
+	isHelpAutoAssignEnabled = 1 == configurations.GetConfigPositiveIntValueOrPanic(`help`, `auto-assign`)
+	helpSLACheckIntervalDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(helpSLAConfigSectionName, `check-interval`)) * time.Second
+}
+
+// getHelpSLASeconds - 取得某場域的求助時限秒數(場域未設定或設定錯誤時使用預設值)
+/**
+ * @param int areaNumber 場域代號
+ * @param string key 關鍵字
+ * @return int 秒數
+ */
+func getHelpSLASeconds(areaNumber int, key string) int {
+
+	value, err := strconv.Atoi(configurations.GetConfigValueOrDefault(helpSLAConfigSectionName+`-`+strconv.Itoa(areaNumber), key, ``))
+
+	if nil != err || value <= 0 { // 場域未設定或設定錯誤
+		return configurations.GetConfigPositiveIntValueOrPanic(helpSLAConfigSectionName, key)
+	}
+
+	return value
+}
+
+// getHelpSLAList - 取得某場域的求助時限清單設定(以逗號分隔，場域未設定時使用預設值)
+/**
+ * @param int areaNumber 場域代號
+ * @param string key 關鍵字
+ * @return []string returnValues 清單
+ */
+func getHelpSLAList(areaNumber int, key string) (returnValues []string) {
+
+	value := configurations.GetConfigValueOrDefault(
+		helpSLAConfigSectionName+`-`+strconv.Itoa(areaNumber),
+		key,
+		configurations.GetConfigValueOrPanic(helpSLAConfigSectionName, key),
+	)
+
+	for _, item := range strings.Split(value, `,`) {
+
+		if item = strings.TrimSpace(item); `` != item {
+			returnValues = append(returnValues, item)
+		}
+
+	}
+
+	return // 回傳
+}
+
+// getHelpSLA - 取得求助時限設定(求助屬於多個場域時，取最短時限並合併清單)
+/**
+ * @param []int area 求助者場域
+ * @return helpSLA returnHelpSLA 求助時限設定
+ */
+func getHelpSLA(area []int) (returnHelpSLA helpSLA) {
+
+	if 0 == len(area) { // 沒有場域則使用預設值
+		area = []int{0}
+	}
+
+	includedAreaMap := make(map[int]bool)      // 已包含的鄰近場域
+	includedUserIDMap := make(map[string]bool) // 已包含的值班專家
+
+	for _, areaNumber := range area {
+		includedAreaMap[areaNumber] = true // 本身場域不需再通知
+	}
+
+	for _, areaNumber := range area {
+
+		escalateAfterDuration := time.Duration(getHelpSLASeconds(areaNumber, `escalate-after`)) * time.Second
+		timeoutAfterDuration := time.Duration(getHelpSLASeconds(areaNumber, `timeout-after`)) * time.Second
+
+		if 0 == returnHelpSLA.escalateAfterDuration || escalateAfterDuration < returnHelpSLA.escalateAfterDuration {
+			returnHelpSLA.escalateAfterDuration = escalateAfterDuration
+		}
+
+		if 0 == returnHelpSLA.timeoutAfterDuration || timeoutAfterDuration < returnHelpSLA.timeoutAfterDuration {
+			returnHelpSLA.timeoutAfterDuration = timeoutAfterDuration
+		}
+
+		for _, neighbourAreaString := range getHelpSLAList(areaNumber, `neighbour-areas`) {
+
+			if neighbourAreaNumber, err := strconv.Atoi(neighbourAreaString); nil == err && !includedAreaMap[neighbourAreaNumber] {
+				includedAreaMap[neighbourAreaNumber] = true
+				returnHelpSLA.neighbourArea = append(returnHelpSLA.neighbourArea, neighbourAreaNumber)
+			}
+
+		}
+
+		for _, userID := range getHelpSLAList(areaNumber, `on-call-experts`) {
+
+			if !includedUserIDMap[userID] {
+				includedUserIDMap[userID] = true
+				returnHelpSLA.onCallUserIDs = append(returnHelpSLA.onCallUserIDs, userID)
+			}
+
+		}
+
+	}
+
+	return // 回傳
+}
+
+// CheckHelpRequestSLA - 定時檢查求助時限，逾時未回應則升級或結束求助
+func CheckHelpRequestSLA() {
+	for {
+		<-time.After(helpSLACheckIntervalDuration) // 等待下次檢查
+		checkHelpRequestSLA()                      // 檢查求助時限
+	}
+}
+
+// checkHelpRequestSLA - 檢查所有排隊中求助的時限
+func checkHelpRequestSLA() {
+
+	for _, helpRequest := range helpQueuePointer.getHelpRequests() {
+
+		sla := getHelpSLA(helpRequest.Area)
+		waitedDuration := time.Since(helpRequest.RequestedTime)
+
+		if waitedDuration >= sla.timeoutAfterDuration { // 逾時
+			processHelpRequestTimeout(helpRequest)
+		} else if helpRequest.EscalationLevel < 1 && waitedDuration >= sla.escalateAfterDuration { // 第一次升級:通知上層與鄰近場域
+			area := append(getParentAreaIDs(helpRequest.Area), sla.neighbourArea...)
+			processHelpRequestEscalation(helpRequest, HelpEscalationStep{Level: 1, EscalatedTime: time.Now(), Area: area})
+		} else if helpRequest.EscalationLevel < 2 && waitedDuration >= 2*sla.escalateAfterDuration { // 第二次升級:通知值班專家
+			processHelpRequestEscalation(helpRequest, HelpEscalationStep{Level: 2, EscalatedTime: time.Now(), UserIDs: sla.onCallUserIDs})
+		}
+
+	}
+
+}
+
+// processHelpRequestEscalation - 升級求助:記錄升級，並通知升級場域與值班專家
+/**
+ * @param HelpRequest helpRequest 求助
+ * @param HelpEscalationStep step 升級紀錄
+ */
+func processHelpRequestEscalation(helpRequest HelpRequest, step HelpEscalationStep) {
+
+	whatKindCommandString := `求助升級`
+	command := Command{Command: CommandNumberOfAskForHelp, RoomID: helpRequest.RoomID, TransactionID: helpRequest.transactionID}
+
+	deviceKey := getDeviceKey(helpRequest.DeviceID, helpRequest.DeviceBrand)
+
+	askerDevicePointer := getDevice(helpRequest.DeviceID, helpRequest.DeviceBrand)
+
+	if nil == askerDevicePointer || DeviceStatusAskingForHelp != askerDevicePointer.DeviceStatus { // 求助者已不在求助中
+		return // 回傳
+	}
+
+	if !helpQueuePointer.escalate(deviceKey, step) { // 已被認領、已取消或已升級過
+		return // 回傳
+	}
+
+	askerClientPointer, _, _ := sessionRegistryPointer.getClientPointerByDeviceKey(deviceKey)
+
+	details := `-求助無人回應,升級第` + strconv.Itoa(step.Level) + `次` +
+		`,(求助者)裝置ID=` + helpRequest.DeviceID + `,(求助者)裝置Brand=` + helpRequest.DeviceBrand +
+		`,房號=` + strconv.Itoa(helpRequest.RoomID)
+
+	// 通知升級場域
+	if 0 < len(step.Area) {
+
+		processBroadcastingDeviceChangeStatusInSomeArea(whatKindCommandString, command, askerClientPointer, getArrayPointer(askerDevicePointer), step.Area, details)
+
+		areaStrings := []string{}
+		for _, areaNumber := range step.Area {
+			areaStrings = append(areaStrings, strconv.Itoa(areaNumber))
+		}
+
+		details += `-通知場域=` + strings.Join(areaStrings, `,`)
+	}
+
+	// 通知值班專家
+	if 0 < len(step.UserIDs) {
+
+		if jsonBytes, err := json.Marshal(HelpEscalation{
+			Command:     CommandNumberOfHelpEscalation,
+			CommandType: CommandTypeNumberOfBroadcast,
+			RoomID:      helpRequest.RoomID,
+			Level:       step.Level,
+			Device:      askerDevicePointer,
+		}); nil == err {
+
+			for _, userID := range step.UserIDs {
+				for _, onCallClientPointer := range sessionRegistryPointer.getClientPointersByUserID(userID) {
+					onCallClientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+				}
+			}
+
+		}
+
+		details += `-通知值班專家=` + strings.Join(step.UserIDs, `,`)
+	}
+
+	// logger
+	loggerFields := getLoggerFields(askerClientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+	processAutoAssignHelpRequests(whatKindCommandString, command, askerClientPointer) // 升級場域可能有閒置專家
+}
+
+// processHelpRequestTimeout - 求助逾時:移出佇列，求助者回到閒置並收到逾時回應
+/**
+ * @param HelpRequest helpRequest 求助
+ */
+func processHelpRequestTimeout(helpRequest HelpRequest) {
+
+	whatKindCommandString := `求助逾時`
+	command := Command{Command: CommandNumberOfAskForHelp, RoomID: helpRequest.RoomID, TransactionID: helpRequest.transactionID}
+
+	deviceKey := getDeviceKey(helpRequest.DeviceID, helpRequest.DeviceBrand)
+
+	if _, isClaimed := helpQueuePointer.claim(deviceKey); !isClaimed { // 已被專家認領或已取消
+		return // 回傳
+	}
+
+	askerClientPointer, _, _ := sessionRegistryPointer.getClientPointerByDeviceKey(deviceKey)
+
+	askerDevicePointer := getDevice(helpRequest.DeviceID, helpRequest.DeviceBrand)
+
+	// 設備狀態:閒置(狀態轉換掛勾會廣播，並以求助時的房號記錄求助逾時的稽核紀錄)
+	if nil != askerDevicePointer && nil == changeDeviceStatus(askerClientPointer, askerDevicePointer, deviceStatusEventHelpTimeout, func() {
+		askerDevicePointer.Pic = ""   // Pic還原預設
+		askerDevicePointer.RoomID = 0 // RoomID還原預設
+	}, whatKindCommandString+`,已升級次數=`+strconv.Itoa(helpRequest.EscalationLevel)) {
+		sessionRegistryPointer.reindexDevicePointer(askerDevicePointer) // 房號已改變，更新索引
+	}
+
+	details := `-求助逾時,無專家回應` +
+		`,(求助者)裝置ID=` + helpRequest.DeviceID + `,(求助者)裝置Brand=` + helpRequest.DeviceBrand +
+		`,房號=` + strconv.Itoa(helpRequest.RoomID) + `,已升級次數=` + strconv.Itoa(helpRequest.EscalationLevel)
+
+	if nil != askerClientPointer {
+
+		// Response:失敗(帶回原求助指令的transactionID)
+		processResponseFail(askerClientPointer, whatKindCommandString, command, `求助逾時，無專家回應`, ResultCodeHelpTimeout)
+
+		// logger
+		loggerFields := getLoggerFields(askerClientPointer) //取當下的記錄欄位
+		processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+
+	}
+
+	pushHelpQueuePositions() // 佇列已改變，通知其他排隊者
+}
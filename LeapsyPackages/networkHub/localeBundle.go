@@ -0,0 +1,191 @@
+package networkHub
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 語系
+const (
+	LocaleZhTW = `zh-TW` // 繁體中文
+	LocaleEn   = `en`    // 英文
+	LocaleJa   = `ja`    // 日文
+
+	defaultLocale = LocaleZhTW // 預設語系(未指定或不支援的語系皆使用此語系)
+)
+
+const (
+	messageKeyOfVerificationCodeMailSubject = `MAIL_VERIFICATION_CODE_SUBJECT` // 驗證信主旨
+)
+
+var (
+	// 各語系訊息(結果訊息以結果代碼符號名稱為鍵，未翻譯的結果訊息使用結果代碼目錄中的預設語系範本)
+	messageBundleMap = map[string]map[string]string{
+		LocaleZhTW: {
+			messageKeyOfVerificationCodeMailSubject: `Leapsy專家系統-驗證通知信`,
+		},
+		LocaleEn: {
+			messageKeyOfVerificationCodeMailSubject: `Leapsy Expert System - Verification Code`,
+
+			`SUCCESS`:                           `Success`,
+			`FAIL`:                              `Command failed`,
+			`DEVICE_STATUS_NOT_ALLOWED`:         `The device's current status does not allow this operation`,
+			`VERIFICATION_CODE_EXPIRED`:         `The verification code has expired or been used. Please request a new verification e-mail`,
+			`VERIFICATION_CODE_LOCKED`:          `Too many incorrect verification codes. Please request a new verification e-mail later`,
+			`VERIFICATION_CODE_RESEND_TOO_SOON`: `Verification e-mails are being sent too often. Please try again later`,
+			`LOGIN_BACKOFF`:                     `Login failed. Please try again later`,
+			`LOGIN_LOCKED`:                      `Too many failed logins. Login is temporarily locked; try again later or contact an administrator`,
+			`RESUME_TOKEN_INVALID`:              `The resume token is invalid or has expired. Please log in again`,
+			`PERMISSION_DENIED`:                 `Your account role is not permitted to use this command or area`,
+			`NOT_LOGGED_IN`:                     `This connection has not logged in`,
+			`FIELDS_MISSING`:                    `Required fields are missing: %s`,
+			`UNKNOWN_COMMAND`:                   `Unknown command: %d`,
+			`DEVICE_TYPE_NOT_ALLOWED`:           `This device type cannot use this command`,
+			`INTERNAL_ERROR`:                    `The server failed to execute the command`,
+			`INFO_NOT_FOUND`:                    `Login information for this connection was not found`,
+			`DEVICE_NOT_FOUND`:                  `Device not found`,
+			`ACCOUNT_NOT_FOUND`:                 `Account not found`,
+			`CREDENTIAL_INCORRECT`:              `Unknown account, or incorrect password or verification code`,
+			`PASSWORD_POLICY_VIOLATED`:          `The new password does not meet the password policy`,
+			`PASSWORD_UNCHANGED`:                `The new password must differ from the old password`,
+			`TOKEN_INVALID`:                     `The token is invalid or has expired`,
+			`ALREADY_LOGGED_IN`:                 `This connection is already logged in`,
+			`DUPLICATE_LOGIN`:                   `Disconnected because the same device logged in elsewhere`,
+			`CONNECTION_TIMEOUT`:                `The connection timed out and has been logged out`,
+			`AREA_NOT_FOUND`:                    `The area was not found or is not active`,
+			`ALREADY_IN_AREA`:                   `The device is already in this area`,
+			`MAIL_SEND_FAILED`:                  `Failed to send the verification e-mail`,
+			`ROOM_ID_UNAVAILABLE`:               `No room ID is available`,
+			`ROOM_NOT_OPEN`:                     `The room does not exist or has been closed`,
+			`ROOM_FULL`:                         `The room is full`,
+			`ROOM_NOT_IN_AREA`:                  `The room is not in your area and you were not invited`,
+			`ROOM_INVITER_NOT_IN`:               `The inviter is not in this room`,
+			`ROOM_ALREADY_JOINED`:               `The invitee is already in this room`,
+			`ROOM_ALREADY_INVITED`:              `The invitee has already been invited`,
+			`ALREADY_IN_ROOM`:                   `Already in room %d. Please leave the room first`,
+			`NOT_IN_ROOM`:                       `Not in any room`,
+			`TARGET_NOT_AVAILABLE`:              `The other device is offline or not in the same room`,
+			`TARGET_NOT_IDLE`:                   `The other device is not idle`,
+			`HELP_ALREADY_CLAIMED`:              `This help request has already been answered by another expert or was cancelled`,
+			`HELP_TIMEOUT`:                      `The help request timed out without an expert answering`,
+			`SIGNAL_TO_SELF`:                    `Cannot relay to yourself`,
+			`HELP_NOT_IN_AREA`:                  `This help request is not in your area`,
+			`HELP_GIVER_IN_ROOM`:                `You are already in a room. Please leave the room before answering a help request`,
+		},
+		LocaleJa: {
+			messageKeyOfVerificationCodeMailSubject: `Leapsyエキスパートシステム - 認証コードのお知らせ`,
+
+			`SUCCESS`:                           `成功しました`,
+			`FAIL`:                              `コマンドの実行に失敗しました`,
+			`DEVICE_STATUS_NOT_ALLOWED`:         `デバイスの現在の状態ではこの操作はできません`,
+			`VERIFICATION_CODE_EXPIRED`:         `認証コードの有効期限が切れているか、使用済みです。認証メールを再取得してください`,
+			`VERIFICATION_CODE_LOCKED`:          `認証コードの誤りが多すぎます。しばらくしてから認証メールを再取得してください`,
+			`VERIFICATION_CODE_RESEND_TOO_SOON`: `認証メールの送信が頻繁すぎます。しばらくしてから再試行してください`,
+			`LOGIN_BACKOFF`:                     `ログインに失敗しました。しばらくしてから再試行してください`,
+			`LOGIN_LOCKED`:                      `ログインの失敗が多すぎるため一時的にロックされました。しばらくしてから再試行するか管理者に連絡してください`,
+			`RESUME_TOKEN_INVALID`:              `再接続トークンが無効か期限切れです。再度ログインしてください`,
+			`PERMISSION_DENIED`:                 `アカウントのロールにこのコマンドまたはエリアの権限がありません`,
+			`NOT_LOGGED_IN`:                     `この接続はまだログインしていません`,
+			`FIELDS_MISSING`:                    `必須フィールドが不足しています: %s`,
+			`UNKNOWN_COMMAND`:                   `不明なコマンドです: %d`,
+			`DEVICE_TYPE_NOT_ALLOWED`:           `このデバイス種別ではこのコマンドを使用できません`,
+			`INTERNAL_ERROR`:                    `サーバーでコマンドの実行中にエラーが発生しました`,
+			`INFO_NOT_FOUND`:                    `接続のログイン情報が見つかりません`,
+			`DEVICE_NOT_FOUND`:                  `デバイスが見つかりません`,
+			`ACCOUNT_NOT_FOUND`:                 `アカウントが見つかりません`,
+			`CREDENTIAL_INCORRECT`:              `アカウントが存在しないか、パスワードまたは認証コードが正しくありません`,
+			`PASSWORD_POLICY_VIOLATED`:          `新しいパスワードがパスワードルールを満たしていません`,
+			`PASSWORD_UNCHANGED`:                `新しいパスワードは古いパスワードと異なる必要があります`,
+			`TOKEN_INVALID`:                     `トークンが無効か期限切れです`,
+			`ALREADY_LOGGED_IN`:                 `この接続はすでにログインしています`,
+			`DUPLICATE_LOGIN`:                   `同じデバイスが別の場所でログインしたため切断されました`,
+			`CONNECTION_TIMEOUT`:                `接続がタイムアウトしたためログアウトしました`,
+			`AREA_NOT_FOUND`:                    `エリアが見つからないか、有効ではありません`,
+			`ALREADY_IN_AREA`:                   `デバイスはすでにこのエリアにいます`,
+			`MAIL_SEND_FAILED`:                  `認証メールの送信に失敗しました`,
+			`ROOM_ID_UNAVAILABLE`:               `ルーム番号を割り当てられません`,
+			`ROOM_NOT_OPEN`:                     `ルームが存在しないか、すでに閉じられています`,
+			`ROOM_FULL`:                         `ルームの人数が上限に達しています`,
+			`ROOM_NOT_IN_AREA`:                  `ルームが自分のエリアに属しておらず、招待もされていません`,
+			`ROOM_INVITER_NOT_IN`:               `招待者がこのルームにいません`,
+			`ROOM_ALREADY_JOINED`:               `招待された人はすでにこのルームにいます`,
+			`ROOM_ALREADY_INVITED`:              `招待された人はすでに招待されています`,
+			`ALREADY_IN_ROOM`:                   `すでにルーム%dにいます。先にルームを退出してください`,
+			`NOT_IN_ROOM`:                       `どのルームにも入っていません`,
+			`TARGET_NOT_AVAILABLE`:              `相手のデバイスがオフラインか、同じルームにいません`,
+			`TARGET_NOT_IDLE`:                   `相手のデバイスは待機中ではありません`,
+			`HELP_ALREADY_CLAIMED`:              `このヘルプ要請はすでに他のエキスパートが対応したか、取り消されました`,
+			`HELP_TIMEOUT`:                      `ヘルプ要請がタイムアウトしました。対応できるエキスパートがいません`,
+			`SIGNAL_TO_SELF`:                    `自分自身には転送できません`,
+			`HELP_NOT_IN_AREA`:                  `このヘルプ要請は自分のエリアではありません`,
+			`HELP_GIVER_IN_ROOM`:                `すでにルームにいます。ルームを退出してからヘルプ要請に対応してください`,
+		},
+	}
+)
+
+// normalizeLocale - 將客戶端指定的語系轉成支援的語系(如 en-US 轉成 en，不支援則為預設語系)
+/**
+ * @param string locale 客戶端指定的語系
+ * @return string 支援的語系
+ */
+func normalizeLocale(locale string) string {
+
+	lowerLocale := strings.ToLower(strings.Replace(strings.TrimSpace(locale), `_`, `-`, -1))
+
+	switch {
+
+	case `en` == lowerLocale || strings.HasPrefix(lowerLocale, `en-`):
+		return LocaleEn
+
+	case `ja` == lowerLocale || strings.HasPrefix(lowerLocale, `ja-`):
+		return LocaleJa
+
+	default:
+		return defaultLocale
+
+	}
+
+}
+
+// getLocalizedText - 取得某語系的訊息(該語系沒有翻譯則用預設語系，都沒有則用傳入的預設訊息)
+/**
+ * @param string locale 語系
+ * @param string key 訊息鍵
+ * @param string defaultText 預設訊息
+ * @return string 訊息
+ */
+func getLocalizedText(locale string, key string, defaultText string) string {
+
+	if text, ok := messageBundleMap[locale][key]; ok {
+		return text
+	}
+
+	if text, ok := messageBundleMap[defaultLocale][key]; ok {
+		return text
+	}
+
+	return defaultText
+}
+
+// getLocalizedFilePath - 取得某語系的樣板檔路徑(如 template.html 轉成 template.en.html，檔案不存在則用原檔)
+/**
+ * @param string locale 語系
+ * @param string filePath 預設語系的樣板檔路徑
+ * @return string 樣板檔路徑
+ */
+func getLocalizedFilePath(locale string, filePath string) string {
+
+	if defaultLocale == locale {
+		return filePath
+	}
+
+	extension := filepath.Ext(filePath)
+	localizedFilePath := strings.TrimSuffix(filePath, extension) + `.` + locale + extension
+
+	if _, statError := os.Stat(localizedFilePath); nil == statError {
+		return localizedFilePath
+	}
+
+	return filePath
+}
@@ -0,0 +1,239 @@
+package networkHub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 記錄欄位名稱
+const (
+	loggerFieldOfCommand       = `command`       // 指令代碼
+	loggerFieldOfTransactionID = `transactionID` // 指令流水號
+	loggerFieldOfUserID        = `userID`        // 使用者登入帳號
+	loggerFieldOfDeviceKey     = `deviceKey`     // 裝置鍵(裝置ID|裝置品牌)
+	loggerFieldOfRoomID        = `roomID`        // 房號
+	loggerFieldOfArea          = `area`          // 場域代號
+	loggerFieldOfOutcome       = `outcome`       // 結果(依記錄層級:success、failure、error)
+	loggerFieldOfRemoteHost    = `remoteHost`    // 來源位址(尚未登入的連線)
+	loggerFieldOfPayload       = `payload`       // 遮蔽後的指令內容(只在除錯層級記錄)
+	loggerFieldOfTemplateFile  = `templateFile`  // 樣板檔路徑
+	loggerFieldOfPicFile       = `picFile`       // 頭像檔名
+)
+
+const (
+	redactedPayloadValueConstString = `[REDACTED]` // 指令內容中敏感欄位的替代值
+)
+
+var sensitivePayloadKeywords = []string{`password`, `token`, `secret`, `verificationcode`, `encryption`} // 指令內容中敏感欄位名稱關鍵字(不分大小寫)
+
+// payloadHeader - 指令內容的表頭(只取記錄需要的指令代碼與流水號)
+type payloadHeader struct {
+	Command       int    `json:"command"`
+	TransactionID string `json:"transactionID"`
+}
+
+// getPayloadLoggerFields - 取得websocket資料的記錄欄位(只有指令代碼與流水號，不含任何內容)
+/**
+ * @param []byte dataBytes websocket資料
+ * @return logrus.Fields returnLoggerFields 記錄欄位
+ */
+func getPayloadLoggerFields(dataBytes []byte) (returnLoggerFields logrus.Fields) {
+
+	returnLoggerFields = logrus.Fields{}
+
+	var header payloadHeader
+
+	if nil != json.Unmarshal(dataBytes, &header) { // 若非指令(JSON)
+		return // 回傳
+	}
+
+	returnLoggerFields[loggerFieldOfCommand] = header.Command
+	returnLoggerFields[loggerFieldOfTransactionID] = header.TransactionID
+
+	return // 回傳
+}
+
+// getRedactedPayloadString - 取得遮蔽敏感欄位後的websocket資料字串(供除錯層級傾印)
+/**
+ * @param []byte dataBytes websocket資料
+ * @return string 遮蔽後的字串(非JSON只回傳長度)
+ */
+func getRedactedPayloadString(dataBytes []byte) string {
+
+	var payload interface{}
+
+	if nil != json.Unmarshal(dataBytes, &payload) { // 若非JSON，不傾印內容
+		return fmt.Sprintf(`<%d bytes>`, len(dataBytes))
+	}
+
+	redactedBytes, marshalError := json.Marshal(getRedactedPayloadValue(``, payload))
+
+	if nil != marshalError {
+		return fmt.Sprintf(`<%d bytes>`, len(dataBytes))
+	}
+
+	return string(redactedBytes)
+}
+
+// getRedactedPayloadValue - 遞迴遮蔽JSON值中的敏感欄位
+/**
+ * @param string key 欄位名稱(陣列元素沿用上層名稱)
+ * @param interface{} value JSON值
+ * @return interface{} 遮蔽後的值
+ */
+func getRedactedPayloadValue(key string, value interface{}) interface{} {
+
+	switch typedValue := value.(type) {
+
+	case map[string]interface{}:
+
+		redactedMap := make(map[string]interface{}, len(typedValue))
+
+		for childKey, childValue := range typedValue {
+			redactedMap[childKey] = getRedactedPayloadValue(childKey, childValue)
+		}
+
+		return redactedMap
+
+	case []interface{}:
+
+		redactedSlice := make([]interface{}, len(typedValue))
+
+		for index, childValue := range typedValue {
+			redactedSlice[index] = getRedactedPayloadValue(key, childValue)
+		}
+
+		return redactedSlice
+
+	case string:
+
+		if isSensitivePayloadKey(key) || isTokenLikeString(typedValue) { // 敏感欄位，或其他欄位夾帶權杖(如QR登入把JWT放在userID)
+			return redactedPayloadValueConstString
+		}
+
+	}
+
+	return value
+}
+
+// isSensitivePayloadKey - 是否為敏感欄位名稱
+/**
+ * @param string key 欄位名稱
+ * @return bool 是否為敏感欄位
+ */
+func isSensitivePayloadKey(key string) bool {
+
+	lowerKey := strings.ToLower(key)
+
+	for _, keyword := range sensitivePayloadKeywords {
+
+		if strings.Contains(lowerKey, keyword) {
+			return true
+		}
+
+	}
+
+	return false
+}
+
+// isTokenLikeString - 是否看起來像JWT(三段以點分隔且不含@，避免誤判電子郵件帳號)
+/**
+ * @param string value 字串
+ * @return bool 是否像JWT
+ */
+func isTokenLikeString(value string) bool {
+	return 2 == strings.Count(value, `.`) && !strings.Contains(value, `@`) && !strings.ContainsAny(value, ` /`)
+}
+
+// getLoggerFields - 取得連線的記錄欄位(取當下的帳號、裝置、房號與場域，避免平行處理值改變了)
+/**
+ * @param *client clientPointer 連線指標
+ * @return logrus.Fields returnLoggerFields 記錄欄位
+ */
+func getLoggerFields(clientPointer *client) (returnLoggerFields logrus.Fields) {
+
+	returnLoggerFields = logrus.Fields{}
+
+	if nil == clientPointer || nil == sessionRegistryPointer { // 若指標為空
+		return // 回傳
+	}
+
+	infoPointer, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer)
+
+	if !ok || nil == infoPointer { // 若連線尚未登入
+		return // 回傳
+	}
+
+	if nil != infoPointer.AccountPointer {
+		returnLoggerFields[loggerFieldOfUserID] = infoPointer.AccountPointer.UserID
+	}
+
+	if devicePointer := infoPointer.DevicePointer; nil != devicePointer {
+		returnLoggerFields[loggerFieldOfDeviceKey] = getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)
+		returnLoggerFields[loggerFieldOfRoomID] = devicePointer.RoomID
+		returnLoggerFields[loggerFieldOfArea] = append([]int{}, devicePointer.Area...)
+	}
+
+	return // 回傳
+}
+
+// processLogger - 以記錄欄位加上指令代碼、流水號與結果記錄訊息
+/**
+ * @param logrus.Level level 記錄層級
+ * @param string whatKindCommandString 哪個指令發出的
+ * @param string details 詳細訊息
+ * @param Command command 客戶端的指令
+ * @param logrus.Fields loggerFields 連線的記錄欄位
+ */
+func processLogger(level logrus.Level, whatKindCommandString string, details string, command Command, loggerFields logrus.Fields) {
+
+	outcome := `success` // 結果
+
+	switch level {
+
+	case logrus.WarnLevel:
+		outcome = `failure`
+
+	case logrus.ErrorLevel:
+		outcome = `error`
+
+	}
+
+	entryPointer := logger.WithFields(loggerFields).WithFields(logrus.Fields{
+		loggerFieldOfCommand:       command.Command,
+		loggerFieldOfTransactionID: command.TransactionID,
+		loggerFieldOfOutcome:       outcome,
+	})
+
+	go entryPointer.Logf(level, `指令<%s>:%s`, whatKindCommandString, details) // 記錄
+}
+
+// 處理<一般logger>
+/**
+* @param whatKindCommandString string 哪個指令發出的
+* @param details string 詳細訊息
+* @param command Command 客戶端的指令
+* @param loggerFields logrus.Fields 連線的記錄欄位(由getLoggerFields取得)
+**/
+func processLoggerInfof(whatKindCommandString string, details string, command Command, loggerFields logrus.Fields) {
+	processLogger(logrus.InfoLevel, whatKindCommandString, details, command, loggerFields)
+}
+
+// 處理<警告logger>
+/**
+* @param 參數同處理<一般logger>
+**/
+func processLoggerWarnf(whatKindCommandString string, details string, command Command, loggerFields logrus.Fields) {
+	processLogger(logrus.WarnLevel, whatKindCommandString, details, command, loggerFields)
+}
+
+// 處理<錯誤logger>
+/**
+* @param 參數同處理<一般logger>
+**/
+func processLoggerErrorf(whatKindCommandString string, details string, command Command, loggerFields logrus.Fields) {
+	processLogger(logrus.ErrorLevel, whatKindCommandString, details, command, loggerFields)
+}
@@ -0,0 +1,267 @@
+package networkHub
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"../configurations"
+	"../logings"
+)
+
+// loginFailureRecord - 登入失敗紀錄(帳號或來源位址各一份)
+type loginFailureRecord struct {
+	failedCount      int       // 連續失敗次數
+	lastFailedTime   time.Time // 最後失敗時間
+	blockedUntilTime time.Time // 退避或鎖定期限(期限前不接受登入)
+}
+
+const (
+	loginThrottleConfigSectionName = `login-throttle` // 登入失敗限制設定區塊名
+)
+
+var (
+	loginThrottleReadWriteLock = new(sync.RWMutex) // 登入失敗紀錄讀寫鎖
+
+	loginFailureRecordMapByUserID        = make(map[string]*loginFailureRecord) // 帳號的登入失敗紀錄
+	loginFailureRecordMapByRemoteAddress = make(map[string]*loginFailureRecord) // 來源位址的登入失敗紀錄
+
+	// 登入失敗限制設定
+	loginBackoffBaseDuration     time.Duration // 第一次失敗後的退避時間(之後每次加倍)
+	loginBackoffMaxDuration      time.Duration // 退避時間上限
+	loginAccountLockoutThreshold int           // 帳號連續失敗幾次後鎖定
+	loginAddressLockoutThreshold int           // 來源位址連續失敗幾次後鎖定
+	loginLockoutDuration         time.Duration // 鎖定時間
+	loginFailureResetDuration    time.Duration // 多久沒有失敗後清除紀錄
+
+	errLoginBackoff = errors.New(`登入失敗，請稍後再試`)
+	errLoginLocked  = errors.New(`登入失敗次數過多，已暫時鎖定，請稍後再試或聯絡管理者`)
+)
+
+// initializeLoginThrottleSettings - 從設定檔載入登入失敗限制
+func initializeLoginThrottleSettings() {
+	loginBackoffBaseDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(loginThrottleConfigSectionName, `backoff-base`)) * time.Second
+	loginBackoffMaxDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(loginThrottleConfigSectionName, `backoff-max`)) * time.Second
+	loginAccountLockoutThreshold = configurations.GetConfigPositiveIntValueOrPanic(loginThrottleConfigSectionName, `account-lockout-threshold`)
+	loginAddressLockoutThreshold = configurations.GetConfigPositiveIntValueOrPanic(loginThrottleConfigSectionName, `address-lockout-threshold`)
+	loginLockoutDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(loginThrottleConfigSectionName, `lockout`)) * time.Second
+	loginFailureResetDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(loginThrottleConfigSectionName, `reset-after`)) * time.Second
+}
+
+// getClientRemoteHost - 取得連線的來源位址(不含埠號)
+/**
+ * @param *client clientPointer 連線指標
+ * @return string returnRemoteHost 來源位址
+ */
+func getClientRemoteHost(clientPointer *client) (returnRemoteHost string) {
+
+	connectionPointer := clientPointer.getConnectionPointer()
+
+	if nil == connectionPointer {
+		return // 回傳
+	}
+
+	returnRemoteHost = (*connectionPointer).RemoteAddr().String()
+
+	if host, _, err := net.SplitHostPort(returnRemoteHost); nil == err {
+		returnRemoteHost = host
+	}
+
+	return // 回傳
+}
+
+// getLoginBlockError - 取得登入失敗紀錄目前的封鎖錯誤
+/**
+ * @param *loginFailureRecord recordPointer 登入失敗紀錄指標
+ * @param int lockoutThreshold 鎖定門檻
+ * @param time.Time now 現在時間
+ * @return error 封鎖中的錯誤(未封鎖則為nil)
+ */
+func getLoginBlockError(recordPointer *loginFailureRecord, lockoutThreshold int, now time.Time) error {
+
+	if nil == recordPointer || !now.Before(recordPointer.blockedUntilTime) {
+		return nil
+	}
+
+	if recordPointer.failedCount >= lockoutThreshold {
+		return errLoginLocked
+	}
+
+	return errLoginBackoff
+}
+
+// checkLoginAllowed - 檢查帳號與來源位址是否可以嘗試登入(退避中或鎖定中則回傳錯誤)
+/**
+ * @param string userID 帳號(未知時為空)
+ * @param string remoteHost 來源位址
+ * @return error 錯誤
+ */
+func checkLoginAllowed(userID string, remoteHost string) error {
+
+	now := time.Now()
+
+	loginThrottleReadWriteLock.RLock()         // 讀鎖
+	defer loginThrottleReadWriteLock.RUnlock() // 記得解開讀鎖
+
+	if blockError := getLoginBlockError(loginFailureRecordMapByRemoteAddress[remoteHost], loginAddressLockoutThreshold, now); nil != blockError {
+		return blockError
+	}
+
+	return getLoginBlockError(loginFailureRecordMapByUserID[userID], loginAccountLockoutThreshold, now)
+}
+
+// addLoginFailure - 累加一筆登入失敗紀錄並計算退避或鎖定期限
+/**
+ * @param map[string]*loginFailureRecord recordMap 登入失敗紀錄
+ * @param string key 帳號或來源位址
+ * @param int lockoutThreshold 鎖定門檻
+ * @param time.Time now 現在時間
+ * @return bool isLocked 此次失敗是否造成鎖定
+ */
+func addLoginFailure(recordMap map[string]*loginFailureRecord, key string, lockoutThreshold int, now time.Time) (isLocked bool) {
+
+	recordPointer, ok := recordMap[key]
+
+	if !ok || now.Sub(recordPointer.lastFailedTime) >= loginFailureResetDuration ||
+		(recordPointer.failedCount >= lockoutThreshold && !now.Before(recordPointer.blockedUntilTime)) { // 沒有紀錄、紀錄已過期或鎖定已結束
+		recordPointer = &loginFailureRecord{}
+		recordMap[key] = recordPointer
+	}
+
+	recordPointer.failedCount++
+	recordPointer.lastFailedTime = now
+
+	if recordPointer.failedCount >= lockoutThreshold { // 鎖定
+		recordPointer.blockedUntilTime = now.Add(loginLockoutDuration)
+		isLocked = true
+		return // 回傳
+	}
+
+	backoffDuration := loginBackoffBaseDuration // 退避時間:每次失敗加倍，直到上限
+
+	for count := 1; count < recordPointer.failedCount && backoffDuration < loginBackoffMaxDuration; count++ {
+		backoffDuration *= 2
+	}
+
+	if backoffDuration > loginBackoffMaxDuration {
+		backoffDuration = loginBackoffMaxDuration
+	}
+
+	recordPointer.blockedUntilTime = now.Add(backoffDuration)
+
+	return // 回傳
+}
+
+// recordLoginFailure - 記錄登入失敗(帳號與來源位址分別累計)，造成鎖定時記錄警告
+/**
+ * @param string userID 帳號(未知時為空)
+ * @param string remoteHost 來源位址
+ */
+func recordLoginFailure(userID string, remoteHost string) {
+
+	now := time.Now()
+
+	loginThrottleReadWriteLock.Lock() // 寫鎖
+
+	isAddressLocked := addLoginFailure(loginFailureRecordMapByRemoteAddress, remoteHost, loginAddressLockoutThreshold, now)
+	isAccountLocked := `` != userID && addLoginFailure(loginFailureRecordMapByUserID, userID, loginAccountLockoutThreshold, now)
+
+	loginThrottleReadWriteLock.Unlock() // 解開寫鎖
+
+	if isAddressLocked {
+		logLoginLockout(`來源位址`, remoteHost)
+	}
+
+	if isAccountLocked {
+		logLoginLockout(`帳號`, userID)
+	}
+
+}
+
+// logLoginLockout - 記錄登入鎖定警告
+/**
+ * @param string kind 鎖定對象種類
+ * @param string key 帳號或來源位址
+ */
+func logLoginLockout(kind string, key string) {
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`%s %s 登入失敗次數過多，鎖定 %v `},
+		[]interface{}{kind, key, loginLockoutDuration},
+		nil,
+	)
+
+	logger.Warnf(formatString, args...) // 記錄警告
+}
+
+// recordLoginSuccess - 登入成功後清除帳號的失敗紀錄(來源位址的紀錄保留，避免用其他帳號登入來重設)
+/**
+ * @param string userID 帳號
+ */
+func recordLoginSuccess(userID string) {
+	loginThrottleReadWriteLock.Lock()             // 寫鎖
+	delete(loginFailureRecordMapByUserID, userID) // 清除紀錄
+	loginThrottleReadWriteLock.Unlock()           // 解開寫鎖
+}
+
+// UnlockLogin - 管理者解除帳號或來源位址的登入鎖定
+/**
+ * @param string userID 帳號(空則不處理)
+ * @param string remoteHost 來源位址(空則不處理)
+ * @return bool returnIsUnlocked 是否有紀錄被清除
+ */
+func UnlockLogin(userID string, remoteHost string) (returnIsUnlocked bool) {
+
+	loginThrottleReadWriteLock.Lock() // 寫鎖
+
+	if _, ok := loginFailureRecordMapByUserID[userID]; ok && `` != userID {
+		delete(loginFailureRecordMapByUserID, userID)
+		returnIsUnlocked = true
+	}
+
+	if _, ok := loginFailureRecordMapByRemoteAddress[remoteHost]; ok && `` != remoteHost {
+		delete(loginFailureRecordMapByRemoteAddress, remoteHost)
+		returnIsUnlocked = true
+	}
+
+	loginThrottleReadWriteLock.Unlock() // 解開寫鎖
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`管理者解除登入鎖定 帳號=%s 來源位址=%s 是否有紀錄=%t `},
+		[]interface{}{userID, remoteHost, returnIsUnlocked},
+		nil,
+	)
+
+	logger.Warnf(formatString, args...) // 記錄警告
+
+	return // 回傳
+}
+
+// CleanUpLoginFailureRecords - 定時清除過期的登入失敗紀錄
+func CleanUpLoginFailureRecords() {
+	for {
+		<-time.After(loginFailureResetDuration) // 等待下次清理
+		cleanUpLoginFailureRecords()            // 清理紀錄
+	}
+}
+
+// cleanUpLoginFailureRecords - 清除已過期且不在封鎖中的登入失敗紀錄
+func cleanUpLoginFailureRecords() {
+
+	now := time.Now()
+
+	loginThrottleReadWriteLock.Lock()         // 寫鎖
+	defer loginThrottleReadWriteLock.Unlock() // 記得解開寫鎖
+
+	for _, recordMap := range []map[string]*loginFailureRecord{loginFailureRecordMapByUserID, loginFailureRecordMapByRemoteAddress} {
+		for key, recordPointer := range recordMap {
+			if now.Sub(recordPointer.lastFailedTime) >= loginFailureResetDuration && !now.Before(recordPointer.blockedUntilTime) {
+				delete(recordMap, key)
+			}
+		}
+	}
+
+}
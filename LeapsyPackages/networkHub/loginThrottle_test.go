@@ -0,0 +1,150 @@
+package networkHub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setTestLoginThrottleSettings - 設定測試用的登入失敗限制並清空失敗紀錄，回傳還原函式
+/**
+ * @return func() 還原原本設定與失敗紀錄的函式
+ */
+func setTestLoginThrottleSettings() func() {
+
+	originalBaseDuration, originalMaxDuration := loginBackoffBaseDuration, loginBackoffMaxDuration
+	originalAccountThreshold, originalAddressThreshold := loginAccountLockoutThreshold, loginAddressLockoutThreshold
+	originalLockoutDuration, originalResetDuration := loginLockoutDuration, loginFailureResetDuration
+	originalMapByUserID, originalMapByRemoteAddress := loginFailureRecordMapByUserID, loginFailureRecordMapByRemoteAddress
+
+	loginBackoffBaseDuration = time.Second
+	loginBackoffMaxDuration = 8 * time.Second
+	loginAccountLockoutThreshold = 6
+	loginAddressLockoutThreshold = 10
+	loginLockoutDuration = time.Minute
+	loginFailureResetDuration = time.Hour
+	loginFailureRecordMapByUserID = make(map[string]*loginFailureRecord)
+	loginFailureRecordMapByRemoteAddress = make(map[string]*loginFailureRecord)
+
+	return func() {
+		loginBackoffBaseDuration, loginBackoffMaxDuration = originalBaseDuration, originalMaxDuration
+		loginAccountLockoutThreshold, loginAddressLockoutThreshold = originalAccountThreshold, originalAddressThreshold
+		loginLockoutDuration, loginFailureResetDuration = originalLockoutDuration, originalResetDuration
+		loginFailureRecordMapByUserID, loginFailureRecordMapByRemoteAddress = originalMapByUserID, originalMapByRemoteAddress
+	}
+}
+
+// TestAddLoginFailure - 退避時間每次失敗加倍直到上限，達門檻則鎖定，鎖定結束或紀錄過期後重新計算
+func TestAddLoginFailure(t *testing.T) {
+
+	defer setTestLoginThrottleSettings()()
+
+	now := time.Now()
+
+	testCases := []struct {
+		name             string
+		failedTimes      []time.Duration // 每次失敗距離now的時間(依序)
+		wantFailedCount  int
+		wantBlockedAfter time.Duration // 最後一次失敗後的封鎖時間
+		wantLocked       bool
+	}{
+		{`第一次失敗`, []time.Duration{0}, 1, time.Second, false},
+		{`第二次失敗加倍`, []time.Duration{0, 0}, 2, 2 * time.Second, false},
+		{`第三次失敗再加倍`, []time.Duration{0, 0, 0}, 3, 4 * time.Second, false},
+		{`第四次失敗達上限`, []time.Duration{0, 0, 0, 0}, 4, 8 * time.Second, false},
+		{`第五次失敗不超過上限`, []time.Duration{0, 0, 0, 0, 0}, 5, 8 * time.Second, false},
+		{`達門檻則鎖定`, []time.Duration{0, 0, 0, 0, 0, 0}, 6, time.Minute, true},
+		{`鎖定結束後重新計算`, []time.Duration{0, 0, 0, 0, 0, 0, 2 * time.Minute}, 1, time.Second, false},
+		{`紀錄過期後重新計算`, []time.Duration{0, 0, 0, 2 * time.Hour}, 1, time.Second, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			recordMap := make(map[string]*loginFailureRecord)
+
+			var isLocked bool
+			var failedTime time.Time
+
+			for _, failedAfter := range testCase.failedTimes {
+				failedTime = now.Add(failedAfter)
+				isLocked = addLoginFailure(recordMap, `user`, loginAccountLockoutThreshold, failedTime)
+			}
+
+			recordPointer := recordMap[`user`]
+
+			if recordPointer.failedCount != testCase.wantFailedCount {
+				t.Errorf(`失敗次數 = %d，預期 %d`, recordPointer.failedCount, testCase.wantFailedCount)
+			}
+
+			if blockedAfter := recordPointer.blockedUntilTime.Sub(failedTime); blockedAfter != testCase.wantBlockedAfter {
+				t.Errorf(`封鎖時間 = %v，預期 %v`, blockedAfter, testCase.wantBlockedAfter)
+			}
+
+			if isLocked != testCase.wantLocked {
+				t.Errorf(`addLoginFailure() = %v，預期 %v`, isLocked, testCase.wantLocked)
+			}
+
+		})
+	}
+
+}
+
+// TestCheckLoginAllowed - 退避中或鎖定中拒絕登入，登入成功只清除帳號紀錄，管理者可解除鎖定
+func TestCheckLoginAllowed(t *testing.T) {
+
+	defer setTestLoginThrottleSettings()()
+
+	testCases := []struct {
+		name             string
+		failures         int    // 帳號 user 自位址 host 連續登入失敗次數
+		isLoginSucceeded bool   // 失敗後是否登入成功
+		unlockUserID     string // 管理者解除鎖定的帳號
+		unlockHost       string // 管理者解除鎖定的來源位址
+		wantUnlocked     bool
+		userID           string // 檢查的帳號
+		remoteHost       string // 檢查的來源位址
+		wantError        error
+	}{
+		{`沒有失敗紀錄`, 0, false, ``, ``, false, `user`, `host`, nil},
+		{`失敗後退避中`, 1, false, ``, ``, false, `user`, `host`, errLoginBackoff},
+		{`帳號退避中換位址也拒絕`, 1, false, ``, ``, false, `user`, `other`, errLoginBackoff},
+		{`位址退避中換帳號也拒絕`, 1, false, ``, ``, false, `other`, `host`, errLoginBackoff},
+		{`帳號達門檻鎖定`, 6, false, ``, ``, false, `user`, `other`, errLoginLocked},
+		{`帳號鎖定不影響其他帳號與位址`, 6, false, ``, ``, false, `other`, `other`, nil},
+		{`登入成功清除帳號紀錄`, 1, true, ``, ``, false, `user`, `other`, nil},
+		{`登入成功保留位址紀錄`, 1, true, ``, ``, false, `other`, `host`, errLoginBackoff},
+		{`解除帳號鎖定`, 6, false, `user`, ``, true, `user`, `other`, nil},
+		{`解除帳號鎖定不解除位址`, 6, false, `user`, ``, true, `user`, `host`, errLoginBackoff},
+		{`解除帳號與位址鎖定`, 6, false, `user`, `host`, true, `user`, `host`, nil},
+		{`解除沒有紀錄的帳號`, 6, false, `other`, ``, false, `user`, `other`, errLoginLocked},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			loginFailureRecordMapByUserID = make(map[string]*loginFailureRecord)
+			loginFailureRecordMapByRemoteAddress = make(map[string]*loginFailureRecord)
+
+			for count := 0; count < testCase.failures; count++ {
+				recordLoginFailure(`user`, `host`)
+			}
+
+			if testCase.isLoginSucceeded {
+				recordLoginSuccess(`user`)
+			}
+
+			if `` != testCase.unlockUserID || `` != testCase.unlockHost {
+				if isUnlocked := UnlockLogin(testCase.unlockUserID, testCase.unlockHost); isUnlocked != testCase.wantUnlocked {
+					t.Errorf(`UnlockLogin() = %v，預期 %v`, isUnlocked, testCase.wantUnlocked)
+				}
+			}
+
+			if checkError := checkLoginAllowed(testCase.userID, testCase.remoteHost); !errors.Is(checkError, testCase.wantError) {
+				t.Errorf(`checkLoginAllowed() 錯誤 = %v，預期 %v`, checkError, testCase.wantError)
+			}
+
+		})
+	}
+
+}
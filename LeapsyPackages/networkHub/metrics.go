@@ -0,0 +1,310 @@
+package networkHub
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsHistogram - 直方圖(各上限的累計筆數、總筆數與總和)
+type metricsHistogram struct {
+	bucketCounts []uint64 // 小於等於各上限的筆數(不含+Inf)
+	count        uint64   // 總筆數
+	sum          float64  // 總和
+}
+
+const (
+	metricsNamePrefix = `leapsy_` // 指標名稱前綴
+)
+
+var (
+	metricsReadWriteLock = new(sync.RWMutex) // 指標讀寫鎖
+
+	commandDurationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}    // 指令執行時間直方圖上限(秒)
+	broadcastFanOutBuckets = []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000} // 廣播對象數直方圖上限
+
+	commandDurationHistogramMap = make(map[int]*metricsHistogram)    // 指令代碼對應執行時間直方圖
+	broadcastFanOutHistogramMap = make(map[string]*metricsHistogram) // 廣播種類對應廣播對象數直方圖
+
+	hubConnectCount    uint64 // 網路中心連接次數
+	hubDisconnectCount uint64 // 網路中心中斷連接次數
+	mailSuccessCount   uint64 // 寄信成功次數
+	mailFailureCount   uint64 // 寄信失敗次數
+	auditDroppedCount  uint64 // 稽核紀錄通道已滿而捨棄的稽核紀錄筆數
+)
+
+// 廣播種類
+const (
+	broadcastKindOfHub  = `hub`  // 網路中心廣播(所有連線)
+	broadcastKindOfArea = `area` // 場域廣播
+	broadcastKindOfRoom = `room` // 房間廣播
+)
+
+// observe - 記錄一筆數值到直方圖
+/**
+ * @param []float64 buckets 直方圖上限
+ * @param float64 value 數值
+ */
+func (histogramPointer *metricsHistogram) observe(buckets []float64, value float64) {
+
+	if nil == histogramPointer.bucketCounts {
+		histogramPointer.bucketCounts = make([]uint64, len(buckets))
+	}
+
+	for index, upperBound := range buckets {
+		if value <= upperBound {
+			histogramPointer.bucketCounts[index]++
+		}
+	}
+
+	histogramPointer.count++
+	histogramPointer.sum += value
+}
+
+// recordCommandMetrics - 記錄指令執行次數與執行時間
+/**
+ * @param int commandNumber 指令代碼
+ * @param time.Duration duration 執行時間
+ */
+func recordCommandMetrics(commandNumber int, duration time.Duration) {
+
+	metricsReadWriteLock.Lock() // 寫鎖
+
+	histogramPointer, ok := commandDurationHistogramMap[commandNumber]
+
+	if !ok {
+		histogramPointer = &metricsHistogram{}
+		commandDurationHistogramMap[commandNumber] = histogramPointer
+	}
+
+	histogramPointer.observe(commandDurationBuckets, duration.Seconds())
+
+	metricsReadWriteLock.Unlock() // 解開寫鎖
+}
+
+// recordBroadcastFanOutMetrics - 記錄某次廣播的對象數
+/**
+ * @param string broadcastKind 廣播種類
+ * @param int fanOut 廣播對象數
+ */
+func recordBroadcastFanOutMetrics(broadcastKind string, fanOut int) {
+
+	metricsReadWriteLock.Lock() // 寫鎖
+
+	histogramPointer, ok := broadcastFanOutHistogramMap[broadcastKind]
+
+	if !ok {
+		histogramPointer = &metricsHistogram{}
+		broadcastFanOutHistogramMap[broadcastKind] = histogramPointer
+	}
+
+	histogramPointer.observe(broadcastFanOutBuckets, float64(fanOut))
+
+	metricsReadWriteLock.Unlock() // 解開寫鎖
+}
+
+// recordHubConnectionMetrics - 記錄網路中心連接或中斷連接
+/**
+ * @param bool isConnected 是否為連接(否則為中斷連接)
+ */
+func recordHubConnectionMetrics(isConnected bool) {
+
+	metricsReadWriteLock.Lock() // 寫鎖
+
+	if isConnected {
+		hubConnectCount++
+	} else {
+		hubDisconnectCount++
+	}
+
+	metricsReadWriteLock.Unlock() // 解開寫鎖
+}
+
+// recordMailMetrics - 記錄寄信成功或失敗
+/**
+ * @param bool isSuccess 是否寄信成功
+ */
+func recordMailMetrics(isSuccess bool) {
+
+	metricsReadWriteLock.Lock() // 寫鎖
+
+	if isSuccess {
+		mailSuccessCount++
+	} else {
+		mailFailureCount++
+	}
+
+	metricsReadWriteLock.Unlock() // 解開寫鎖
+}
+
+// recordAuditDroppedMetrics - 記錄一筆因稽核紀錄通道已滿而捨棄的稽核紀錄
+func recordAuditDroppedMetrics() {
+	metricsReadWriteLock.Lock() // 寫鎖
+	auditDroppedCount++
+	metricsReadWriteLock.Unlock() // 解開寫鎖
+}
+
+// writeMetricsHeader - 寫入指標說明與類型
+/**
+ * @param *strings.Builder builderPointer 輸出
+ * @param string name 指標名稱(不含前綴)
+ * @param string metricsType 指標類型(counter、gauge、histogram)
+ * @param string help 指標說明
+ */
+func writeMetricsHeader(builderPointer *strings.Builder, name string, metricsType string, help string) {
+	fmt.Fprintf(builderPointer, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsNamePrefix, name, help, metricsNamePrefix, name, metricsType)
+}
+
+// writeMetricsHistogram - 寫入直方圖的各上限累計筆數、總和與總筆數
+/**
+ * @param *strings.Builder builderPointer 輸出
+ * @param string name 指標名稱(不含前綴)
+ * @param string labels 標籤(如 command="1"，可為空)
+ * @param []float64 buckets 直方圖上限
+ * @param metricsHistogram histogram 直方圖
+ */
+func writeMetricsHistogram(builderPointer *strings.Builder, name string, labels string, buckets []float64, histogram metricsHistogram) {
+
+	labelPrefix := ``
+
+	if `` != labels {
+		labelPrefix = labels + `,`
+	}
+
+	for index, upperBound := range buckets {
+
+		var bucketCount uint64
+
+		if index < len(histogram.bucketCounts) {
+			bucketCount = histogram.bucketCounts[index]
+		}
+
+		fmt.Fprintf(builderPointer, "%s%s_bucket{%sle=\"%s\"} %d\n", metricsNamePrefix, name, labelPrefix, strconv.FormatFloat(upperBound, 'g', -1, 64), bucketCount)
+	}
+
+	fmt.Fprintf(builderPointer, "%s%s_bucket{%sle=\"+Inf\"} %d\n", metricsNamePrefix, name, labelPrefix, histogram.count)
+	fmt.Fprintf(builderPointer, "%s%s_sum{%s} %s\n", metricsNamePrefix, name, labels, strconv.FormatFloat(histogram.sum, 'g', -1, 64))
+	fmt.Fprintf(builderPointer, "%s%s_count{%s} %d\n", metricsNamePrefix, name, labels, histogram.count)
+}
+
+// GetMetricsText - 取得Prometheus文字格式的指標
+/**
+ * @return string 指標文字
+ */
+func GetMetricsText() string {
+
+	var builder strings.Builder
+
+	// 網路中心連線與輸出通道深度
+	hubClientPointers := networkHubPointer.getClientPointers()
+
+	outputChannelDepthSum, outputChannelDepthMax := 0, 0
+
+	for _, clientPointer := range hubClientPointers {
+
+		depth := len(clientPointer.outputChannel)
+		outputChannelDepthSum += depth
+
+		if depth > outputChannelDepthMax {
+			outputChannelDepthMax = depth
+		}
+
+	}
+
+	writeMetricsHeader(&builder, `hub_connections`, `gauge`, `目前連接到網路中心的連線數(含未登入)`)
+	fmt.Fprintf(&builder, "%shub_connections %d\n", metricsNamePrefix, len(hubClientPointers))
+
+	writeMetricsHeader(&builder, `output_channel_depth_sum`, `gauge`, `所有連線輸出通道中等待送出的資料筆數`)
+	fmt.Fprintf(&builder, "%soutput_channel_depth_sum %d\n", metricsNamePrefix, outputChannelDepthSum)
+
+	writeMetricsHeader(&builder, `output_channel_depth_max`, `gauge`, `單一連線輸出通道中等待送出的最大資料筆數`)
+	fmt.Fprintf(&builder, "%soutput_channel_depth_max %d\n", metricsNamePrefix, outputChannelDepthMax)
+
+	writeMetricsHeader(&builder, `output_channel_capacity`, `gauge`, `每條連線輸出通道的容量`)
+	fmt.Fprintf(&builder, "%soutput_channel_capacity %d\n", metricsNamePrefix, channelSize)
+
+	// 登入中的連線(依裝置類型與場域)
+	onlineCountMap := make(map[string]int) // 標籤對應連線數
+
+	for _, infoPointer := range sessionRegistryPointer.getClientInfoMapCopy() {
+
+		if nil == infoPointer || nil == infoPointer.DevicePointer {
+			continue
+		}
+
+		deviceType := strconv.Itoa(infoPointer.DevicePointer.DeviceType)
+
+		for _, areaNumber := range getSessionIndexKeys(infoPointer).area {
+			onlineCountMap[`device_type="`+deviceType+`",area="`+strconv.Itoa(areaNumber)+`"`]++
+		}
+
+	}
+
+	writeMetricsHeader(&builder, `online_devices`, `gauge`, `登入中的裝置數(依裝置類型與場域，屬於多個場域的裝置各算一次)`)
+
+	onlineLabels := make([]string, 0, len(onlineCountMap))
+	for labels := range onlineCountMap {
+		onlineLabels = append(onlineLabels, labels)
+	}
+	sort.Strings(onlineLabels)
+
+	for _, labels := range onlineLabels {
+		fmt.Fprintf(&builder, "%sonline_devices{%s} %d\n", metricsNamePrefix, labels, onlineCountMap[labels])
+	}
+
+	// 求助佇列與房間
+	writeMetricsHeader(&builder, `help_requests_waiting`, `gauge`, `等待專家回應的求助數`)
+	fmt.Fprintf(&builder, "%shelp_requests_waiting %d\n", metricsNamePrefix, len(helpQueuePointer.getHelpRequests()))
+
+	writeMetricsHeader(&builder, `rooms_active`, `gauge`, `開啟中的房間數`)
+	fmt.Fprintf(&builder, "%srooms_active %d\n", metricsNamePrefix, roomManagerPointer.getOpenRoomCount())
+
+	// 累計的指標
+	metricsReadWriteLock.RLock() // 讀鎖
+
+	writeMetricsHeader(&builder, `hub_connects_total`, `counter`, `網路中心連接次數`)
+	fmt.Fprintf(&builder, "%shub_connects_total %d\n", metricsNamePrefix, hubConnectCount)
+
+	writeMetricsHeader(&builder, `hub_disconnects_total`, `counter`, `網路中心中斷連接次數`)
+	fmt.Fprintf(&builder, "%shub_disconnects_total %d\n", metricsNamePrefix, hubDisconnectCount)
+
+	writeMetricsHeader(&builder, `mails_sent_total`, `counter`, `驗證信寄送次數(依結果)`)
+	fmt.Fprintf(&builder, "%smails_sent_total{result=\"success\"} %d\n", metricsNamePrefix, mailSuccessCount)
+	fmt.Fprintf(&builder, "%smails_sent_total{result=\"failure\"} %d\n", metricsNamePrefix, mailFailureCount)
+
+	writeMetricsHeader(&builder, `audit_records_dropped_total`, `counter`, `稽核紀錄通道已滿而捨棄的稽核紀錄筆數`)
+	fmt.Fprintf(&builder, "%saudit_records_dropped_total %d\n", metricsNamePrefix, auditDroppedCount)
+
+	commandNumbers := make([]int, 0, len(commandDurationHistogramMap))
+	for commandNumber := range commandDurationHistogramMap {
+		commandNumbers = append(commandNumbers, commandNumber)
+	}
+	sort.Ints(commandNumbers)
+
+	writeMetricsHeader(&builder, `commands_total`, `counter`, `指令執行次數(依指令代碼)`)
+
+	for _, commandNumber := range commandNumbers {
+		fmt.Fprintf(&builder, "%scommands_total{command=\"%d\"} %d\n", metricsNamePrefix, commandNumber, commandDurationHistogramMap[commandNumber].count)
+	}
+
+	writeMetricsHeader(&builder, `command_duration_seconds`, `histogram`, `指令執行時間(依指令代碼)`)
+
+	for _, commandNumber := range commandNumbers {
+		writeMetricsHistogram(&builder, `command_duration_seconds`, `command="`+strconv.Itoa(commandNumber)+`"`, commandDurationBuckets, *commandDurationHistogramMap[commandNumber])
+	}
+
+	writeMetricsHeader(&builder, `broadcast_fanout`, `histogram`, `每次廣播的對象數(依廣播種類)`)
+
+	for _, broadcastKind := range []string{broadcastKindOfHub, broadcastKindOfArea, broadcastKindOfRoom} {
+		if histogramPointer, ok := broadcastFanOutHistogramMap[broadcastKind]; ok {
+			writeMetricsHistogram(&builder, `broadcast_fanout`, `kind="`+broadcastKind+`"`, broadcastFanOutBuckets, *histogramPointer)
+		}
+	}
+
+	metricsReadWriteLock.RUnlock() // 解開讀鎖
+
+	return builder.String()
+}
@@ -3,7 +3,9 @@ package networkHub
 import (
 	"errors"
 	"sync"
+	"time"
 
+	"../configurations"
 	"../logings"
 	"../network"
 	"github.com/gobwas/ws"
@@ -19,6 +21,8 @@ type networkHub struct {
 	connectChannel chan *client // 客戶端連接通道
 
 	disconnectChannel chan *client // 客戶中斷連接通道
+
+	probeChannel chan chan bool // 探測通道(就緒檢查用，網路中心收到後回覆表示仍在運作)
 }
 
 // initialize - 初始化
@@ -60,6 +64,24 @@ func (networkHubPointer *networkHub) getClients() (returnClients map[*client]boo
 	return // 回傳
 }
 
+// getClientPointers - 取得所有客戶端指標(複本)
+/**
+ * @return []*client returnClientPointers 客戶端指標
+ */
+func (networkHubPointer *networkHub) getClientPointers() (returnClientPointers []*client) {
+
+	networkHubPointer.initialize()                // 初始化
+	networkHubPointer.clientsMutexPointer.RLock() // 鎖讀
+
+	for clientPointer := range networkHubPointer.clients {
+		returnClientPointers = append(returnClientPointers, clientPointer)
+	}
+
+	networkHubPointer.clientsMutexPointer.RUnlock() // 解鎖讀
+
+	return // 回傳
+}
+
 // getClientsValueAndOKOfKey - 取得客戶端值與ok
 /**
  * @return  bool returnValue  值
@@ -98,11 +120,32 @@ func (networkHubPointer *networkHub) deleteClientsKey(key *client) {
 }
 
 var (
-	networkHubPointer = newNetworkHub() // 創建網路中心
+	networkHubPointer *networkHub // 網路中心(初始化時創建)
 )
 
-// init - 初始函式
-func init() {
+// Initialize - 依設定檔初始化網路中心(載入設定、建立各儲存庫並啟動網路中心)，需在主程式依設定檔初始化記錄器後呼叫
+func Initialize() {
+
+	channelSize = configurations.GetConfigPositiveIntValueOrPanic(`local`, `channel-size`)       // 取得預設通道大小
+	timeout = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`local`, `timeout`)) // 連線逾時時間
+	expertdemoMode = configurations.GetConfigPositiveIntValueOrPanic(`local`, `expertdemoMode`)  // demo 模式是否開啟
+
+	resumeSessionGraceDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`session`, `resume-grace`)) * time.Second // 逾時斷線後保留時間
+
+	rolePermissionMap = loadRolePermissionsOrPanic() // 載入角色權限
+
+	initializeAccountCredentialSettings() // 密碼與驗證碼規則
+	initializeLoginThrottleSettings()     // 登入失敗限制
+	initializeHealthCheckSettings()       // 就緒檢查設定
+	initializeHelpSettings()              // 求助設定
+	initializeAccountStore()              // 帳號儲存庫
+	initializeDeviceRepository()          // 裝置儲存庫
+	initializeAreaRepository()            // 場域儲存庫
+	initializeRoomManager()               // 房間管理器
+	initializeAuditTrail()                // 稽核紀錄儲存庫
+
+	networkHubPointer = newNetworkHub() // 創建網路中心
+
 	go startNetworkHub() // 啟動網路中心
 }
 
@@ -115,6 +158,7 @@ func newNetworkHub() *networkHub {
 		clients:           make(map[*client]bool),
 		connectChannel:    make(chan *client, channelSize),
 		disconnectChannel: make(chan *client, channelSize),
+		probeChannel:      make(chan chan bool),
 	}
 
 }
@@ -135,14 +179,8 @@ func startNetworkHub() {
 			wsOpCode := websocketData.wsOpCode
 			dataBytes := websocketData.dataBytes
 
-			formatSlice := `廣播通道接收`
-			formatSlices := []string{formatSlice + `的資料為 %v `} // 紀錄器格式
-			defaultArgs := []interface{}{dataBytes}            // 紀錄器預設參數
-
-			if ws.OpText == wsOpCode {
-				formatSlices = []string{formatSlice + `的字串為 %s `} // 紀錄器格式
-				defaultArgs = []interface{}{string(dataBytes)}    // 紀錄器預設參數
-			}
+			formatSlices := []string{`廣播通道接收的資料`} // 紀錄器格式
+			defaultArgs := []interface{}{}        // 紀錄器預設參數
 
 			var okError error // 接收錯誤
 
@@ -162,9 +200,13 @@ func startNetworkHub() {
 				return                               // 回傳
 			}
 
-			go logger.Infof(formatString, args...) // 紀錄資訊
+			go logWebsocketData(wsOpCode, dataBytes, formatString, args) // 紀錄(只有指令代碼與流水號，內容需除錯層級且遮蔽敏感欄位)
+
+			hubClientPointers := networkHubPointer.getClientPointers() // 所有客戶端
+
+			recordBroadcastFanOutMetrics(broadcastKindOfHub, len(hubClientPointers)) // 記錄廣播對象數
 
-			for client := range networkHubPointer.getClients() { // 針對每一個客戶端
+			for _, client := range hubClientPointers { // 針對每一個客戶端
 
 				select {
 
@@ -207,6 +249,8 @@ func startNetworkHub() {
 
 				networkHubPointer.setClientsValueOfKey(clientPointer, true) // 儲存客戶端
 
+				recordHubConnectionMetrics(true) // 記錄連接次數
+
 			}
 
 		case clientPointer, ok := <-networkHubPointer.disconnectChannel: // 若客戶中斷連接通道收到客戶端
@@ -256,18 +300,58 @@ func startNetworkHub() {
 
 					connection.Close() // 中斷客戶端連線
 
+					recordHubConnectionMetrics(false) // 記錄中斷連接次數
+
 					go logger.Infof(formatString, args...) // 紀錄資訊
 
 				}
 
 			}
 
+		case replyChannel := <-networkHubPointer.probeChannel: // 若探測通道收到回覆通道
+
+			replyChannel <- true // 回覆仍在運作
+
 		} // end select
 
 	} // end for
 
 }
 
+// isNetworkHubAlive - 判斷網路中心是否仍在處理通道(逾時未回覆則視為停止)
+/**
+ * @param time.Duration timeout 逾時
+ * @return bool 是否仍在運作
+ */
+func isNetworkHubAlive(timeout time.Duration) bool {
+
+	if nil == networkHubPointer { // 若指標為空
+		return false
+	}
+
+	replyChannel := make(chan bool, 1) // 回覆通道(有緩衝，逾時後網路中心回覆也不會阻塞)
+
+	select {
+
+	case networkHubPointer.probeChannel <- replyChannel: // 傳回覆通道給探測通道
+
+	case <-time.After(timeout):
+		return false
+
+	}
+
+	select {
+
+	case isAlive := <-replyChannel: // 收到回覆
+		return isAlive
+
+	case <-time.After(timeout):
+		return false
+
+	}
+
+}
+
 // broadcastHubWebsocketData - 廣播websocket資料
 /**
  * @param  websocketData websocketData  websocket資料
@@ -0,0 +1,196 @@
+package networkHub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ResultCatalogueEntry - 結果代碼目錄項目(代碼與名稱固定不變，客戶端應以代碼或名稱判斷錯誤)
+type ResultCatalogueEntry struct {
+	Code            int    `json:"code"`    // 結果代碼
+	Name            string `json:"name"`    // 符號名稱
+	MessageTemplate string `json:"message"` // 訊息範本(可含fmt格式參數)
+}
+
+var (
+	// 結果代碼目錄
+	resultCatalogueMap = map[int]ResultCatalogueEntry{
+		ResultCodeSuccess:                       {ResultCodeSuccess, `SUCCESS`, `成功`},
+		ResultCodeFail:                          {ResultCodeFail, `FAIL`, `執行指令失敗`},
+		ResultCodeDeviceStatusNotAllowed:        {ResultCodeDeviceStatusNotAllowed, `DEVICE_STATUS_NOT_ALLOWED`, `裝置目前狀態不允許此操作`},
+		ResultCodeVerificationCodeExpired:       {ResultCodeVerificationCodeExpired, `VERIFICATION_CODE_EXPIRED`, `驗證碼已過期或已使用，請重新取得驗證信`},
+		ResultCodeVerificationCodeLocked:        {ResultCodeVerificationCodeLocked, `VERIFICATION_CODE_LOCKED`, `驗證碼錯誤次數過多，請稍後再重新取得驗證信`},
+		ResultCodeVerificationCodeResendTooSoon: {ResultCodeVerificationCodeResendTooSoon, `VERIFICATION_CODE_RESEND_TOO_SOON`, `驗證信寄送過於頻繁，請稍後再試`},
+		ResultCodeLoginBackoff:                  {ResultCodeLoginBackoff, `LOGIN_BACKOFF`, `登入失敗，請稍後再試`},
+		ResultCodeLoginLocked:                   {ResultCodeLoginLocked, `LOGIN_LOCKED`, `登入失敗次數過多，已暫時鎖定，請稍後再試或聯絡管理者`},
+		ResultCodeResumeTokenInvalid:            {ResultCodeResumeTokenInvalid, `RESUME_TOKEN_INVALID`, `恢復連線權杖無效或已過期，請重新登入`},
+		ResultCodePermissionDenied:              {ResultCodePermissionDenied, `PERMISSION_DENIED`, `帳號角色沒有此指令或此場域的權限`},
+		ResultCodeNotLoggedIn:                   {ResultCodeNotLoggedIn, `NOT_LOGGED_IN`, `連線尚未登入`},
+		ResultCodeFieldsMissing:                 {ResultCodeFieldsMissing, `FIELDS_MISSING`, `欄位不齊全:%s`},
+		ResultCodeUnknownCommand:                {ResultCodeUnknownCommand, `UNKNOWN_COMMAND`, `無此指令:%d`},
+		ResultCodeDeviceTypeNotAllowed:          {ResultCodeDeviceTypeNotAllowed, `DEVICE_TYPE_NOT_ALLOWED`, `此裝置類型不可使用此指令`},
+		ResultCodeInternalError:                 {ResultCodeInternalError, `INTERNAL_ERROR`, `伺服器執行指令發生錯誤`},
+		ResultCodeInfoNotFound:                  {ResultCodeInfoNotFound, `INFO_NOT_FOUND`, `找不到連線登入資訊`},
+		ResultCodeDeviceNotFound:                {ResultCodeDeviceNotFound, `DEVICE_NOT_FOUND`, `找不到裝置`},
+		ResultCodeAccountNotFound:               {ResultCodeAccountNotFound, `ACCOUNT_NOT_FOUND`, `找不到帳號`},
+		ResultCodeCredentialIncorrect:           {ResultCodeCredentialIncorrect, `CREDENTIAL_INCORRECT`, `無此帳號或密碼、驗證碼錯誤`},
+		ResultCodePasswordPolicyViolated:        {ResultCodePasswordPolicyViolated, `PASSWORD_POLICY_VIOLATED`, `新密碼不符合密碼規則`},
+		ResultCodePasswordUnchanged:             {ResultCodePasswordUnchanged, `PASSWORD_UNCHANGED`, `新密碼不可與舊密碼相同`},
+		ResultCodeTokenInvalid:                  {ResultCodeTokenInvalid, `TOKEN_INVALID`, `令牌無效或已過期`},
+		ResultCodeAlreadyLoggedIn:               {ResultCodeAlreadyLoggedIn, `ALREADY_LOGGED_IN`, `此連線已登入`},
+		ResultCodeDuplicateLogin:                {ResultCodeDuplicateLogin, `DUPLICATE_LOGIN`, `已斷線，有其他相同裝置登入伺服器`},
+		ResultCodeConnectionTimeout:             {ResultCodeConnectionTimeout, `CONNECTION_TIMEOUT`, `連線逾時，已登出`},
+		ResultCodeAreaNotFound:                  {ResultCodeAreaNotFound, `AREA_NOT_FOUND`, `找不到場域或場域未啟用`},
+		ResultCodeAlreadyInArea:                 {ResultCodeAlreadyInArea, `ALREADY_IN_AREA`, `裝置已在此場域`},
+		ResultCodeMailSendFailed:                {ResultCodeMailSendFailed, `MAIL_SEND_FAILED`, `驗證信寄送失敗`},
+		ResultCodeRoomIDUnavailable:             {ResultCodeRoomIDUnavailable, `ROOM_ID_UNAVAILABLE`, `無法配發房號`},
+		ResultCodeRoomNotOpen:                   {ResultCodeRoomNotOpen, `ROOM_NOT_OPEN`, `房間不存在或已關閉`},
+		ResultCodeRoomFull:                      {ResultCodeRoomFull, `ROOM_FULL`, `房間人數已達上限`},
+		ResultCodeRoomNotInArea:                 {ResultCodeRoomNotInArea, `ROOM_NOT_IN_AREA`, `房間不屬於自己的場域，且未受邀請`},
+		ResultCodeRoomInviterNotIn:              {ResultCodeRoomInviterNotIn, `ROOM_INVITER_NOT_IN`, `邀請者不在此房間內`},
+		ResultCodeRoomAlreadyJoined:             {ResultCodeRoomAlreadyJoined, `ROOM_ALREADY_JOINED`, `受邀者已在此房間內`},
+		ResultCodeRoomAlreadyInvited:            {ResultCodeRoomAlreadyInvited, `ROOM_ALREADY_INVITED`, `受邀者已被邀請過`},
+		ResultCodeAlreadyInRoom:                 {ResultCodeAlreadyInRoom, `ALREADY_IN_ROOM`, `已在房號%d中，請先離開房間`},
+		ResultCodeNotInRoom:                     {ResultCodeNotInRoom, `NOT_IN_ROOM`, `不在任何房間中`},
+		ResultCodeTargetNotAvailable:            {ResultCodeTargetNotAvailable, `TARGET_NOT_AVAILABLE`, `對方裝置不在線上或不在同一房間`},
+		ResultCodeTargetNotIdle:                 {ResultCodeTargetNotIdle, `TARGET_NOT_IDLE`, `對方裝置非閒置狀態`},
+		ResultCodeHelpAlreadyClaimed:            {ResultCodeHelpAlreadyClaimed, `HELP_ALREADY_CLAIMED`, `此求助已被其他專家回應或已取消`},
+		ResultCodeHelpTimeout:                   {ResultCodeHelpTimeout, `HELP_TIMEOUT`, `求助逾時，無專家回應`},
+		ResultCodeSignalToSelf:                  {ResultCodeSignalToSelf, `SIGNAL_TO_SELF`, `不可轉送給自己`},
+		ResultCodeHelpNotInArea:                 {ResultCodeHelpNotInArea, `HELP_NOT_IN_AREA`, `此求助不在自己的場域`},
+		ResultCodeHelpGiverInRoom:               {ResultCodeHelpGiverInRoom, `HELP_GIVER_IN_ROOM`, `已在房間中，請先離開房間再回應求助`},
+	}
+
+	// 錯誤對應的結果代碼(未列出者為失敗)
+	errorResultCodeMap = map[error]int{
+		errPasswordIncorrect:             ResultCodeCredentialIncorrect,
+		errVerificationCodeIncorrect:     ResultCodeCredentialIncorrect,
+		errVerificationCodeExpired:       ResultCodeVerificationCodeExpired,
+		errVerificationCodeLocked:        ResultCodeVerificationCodeLocked,
+		errVerificationCodeResendTooSoon: ResultCodeVerificationCodeResendTooSoon,
+		errPasswordPolicyViolated:        ResultCodePasswordPolicyViolated,
+		errPasswordUnchanged:             ResultCodePasswordUnchanged,
+		errLoginBackoff:                  ResultCodeLoginBackoff,
+		errLoginLocked:                   ResultCodeLoginLocked,
+		errResumeTokenInvalid:            ResultCodeResumeTokenInvalid,
+		errResumeTokenDeviceIncorrect:    ResultCodeResumeTokenInvalid,
+		errPermissionDenied:              ResultCodePermissionDenied,
+		errPermissionAreaDenied:          ResultCodePermissionDenied,
+		errRoomNotOpen:                   ResultCodeRoomNotOpen,
+		errRoomFull:                      ResultCodeRoomFull,
+		errRoomNotInArea:                 ResultCodeRoomNotInArea,
+		errRoomInviterNotIn:              ResultCodeRoomInviterNotIn,
+		errRoomAlreadyJoined:             ResultCodeRoomAlreadyJoined,
+		errRoomAlreadyInvited:            ResultCodeRoomAlreadyInvited,
+		errDeviceStatusNotAllowed:        ResultCodeDeviceStatusNotAllowed,
+		errHelpAlreadyClaimed:            ResultCodeHelpAlreadyClaimed,
+		errHelpNotInArea:                 ResultCodeHelpNotInArea,
+		errHelpGiverInRoom:               ResultCodeHelpGiverInRoom,
+		errLoginDeviceNotFound:           ResultCodeDeviceNotFound,
+		errLoginDisconnectFailed:         ResultCodeInternalError,
+	}
+)
+
+// getResultName - 取得結果代碼的符號名稱(未登記的代碼視為失敗)
+/**
+ * @param int resultCode 結果代碼
+ * @return string 符號名稱
+ */
+func getResultName(resultCode int) string {
+
+	if entry, ok := resultCatalogueMap[resultCode]; ok {
+		return entry.Name
+	}
+
+	return resultCatalogueMap[ResultCodeFail].Name
+}
+
+// getResultMessageTemplate - 取得結果代碼在某語系的訊息範本(未登記的代碼視為失敗，沒有翻譯則用預設語系)
+/**
+ * @param string locale 語系
+ * @param int resultCode 結果代碼
+ * @return string 訊息範本
+ */
+func getResultMessageTemplate(locale string, resultCode int) string {
+
+	entry, ok := resultCatalogueMap[resultCode]
+
+	if !ok {
+		entry = resultCatalogueMap[ResultCodeFail]
+	}
+
+	return getLocalizedText(locale, entry.Name, entry.MessageTemplate)
+}
+
+// getResultMessage - 依結果代碼在某語系的訊息範本產生訊息
+/**
+ * @param string locale 語系
+ * @param int resultCode 結果代碼
+ * @param ...interface{} messageArguments 訊息範本參數
+ * @return string 訊息
+ */
+func getResultMessage(locale string, resultCode int, messageArguments ...interface{}) string {
+
+	messageTemplate := getResultMessageTemplate(locale, resultCode)
+
+	if 0 == len(messageArguments) {
+		return messageTemplate
+	}
+
+	return fmt.Sprintf(messageTemplate, messageArguments...)
+}
+
+// getErrorResultCode - 取得錯誤對應的結果代碼(包裝過的錯誤也會比對，未列出者為失敗)
+/**
+ * @param error err 錯誤
+ * @return int 結果代碼
+ */
+func getErrorResultCode(err error) int {
+
+	if resultCode, ok := errorResultCodeMap[err]; ok {
+		return resultCode
+	}
+
+	for mappedError, resultCode := range errorResultCodeMap {
+		if errors.Is(err, mappedError) {
+			return resultCode
+		}
+	}
+
+	return ResultCodeFail
+}
+
+// getResponseJsonBytes - 產生指令回應json(結果名稱與訊息由結果代碼產生)
+/**
+ * @param string locale 語系
+ * @param int commandNumber 指令代碼
+ * @param int resultCode 結果代碼
+ * @param string transactionID 交易ID
+ * @param ...interface{} messageArguments 訊息範本參數
+ * @return []byte 回應json
+ */
+func getResponseJsonBytes(locale string, commandNumber int, resultCode int, transactionID string, messageArguments ...interface{}) []byte {
+	return []byte(fmt.Sprintf(baseResponseJsonString, commandNumber, CommandTypeNumberOfAPIResponse, resultCode, getResultName(resultCode), getResultMessage(locale, resultCode, messageArguments...), transactionID))
+}
+
+// GetResultCatalogue - 取得某語系的結果代碼目錄(依代碼排序，不支援的語系用預設語系)
+/**
+ * @param string locale 語系
+ * @return []ResultCatalogueEntry returnResultCatalogue 結果代碼目錄
+ */
+func GetResultCatalogue(locale string) (returnResultCatalogue []ResultCatalogueEntry) {
+
+	locale = normalizeLocale(locale)
+
+	for _, entry := range resultCatalogueMap {
+		entry.MessageTemplate = getResultMessageTemplate(locale, entry.Code)
+		returnResultCatalogue = append(returnResultCatalogue, entry)
+	}
+
+	sort.Slice(returnResultCatalogue, func(i, j int) bool {
+		return returnResultCatalogue[i].Code < returnResultCatalogue[j].Code
+	})
+
+	return // 回傳
+}
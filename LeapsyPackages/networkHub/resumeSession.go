@@ -0,0 +1,391 @@
+package networkHub
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+// resumeSession - 恢復連線的保留資訊(每個登入連線一份)
+type resumeSession struct {
+	clientPointer *client // 目前使用此權杖的連線
+	deviceKey     string  // 登入時的裝置關鍵字(恢復時需為同一裝置)
+
+	isHeld                bool                 // 是否已斷線並保留中
+	expiryTimer           *time.Timer          // 保留期限計時器
+	stopDrainingChannel   chan bool            // 停止接收舊連線輸出資料的通道
+	heldOutputDataChannel chan []websocketData // 保留期間收到的輸出資料(恢復後補送)
+}
+
+// ResumeSessionResponse - Response-恢復連線
+type ResumeSessionResponse struct {
+	Command       int     `json:"command"`
+	CommandType   int     `json:"commandType"`
+	ResultCode    int     `json:"resultCode"`
+	ResultName    string  `json:"resultName"`
+	Results       string  `json:"results"`
+	TransactionID string  `json:"transactionID"`
+	ResumeToken   string  `json:"resumeToken"` // 新的恢復連線權杖(舊權杖已失效)
+	Account       Account `json:"account"`     // 帳號
+	Device        Device  `json:"device"`      // 裝置(含房號與狀態)
+}
+
+const (
+	resumeTokenByteLength = 32 // 恢復連線權杖長度(位元組)
+)
+
+var (
+	resumeSessionReadWriteLock = new(sync.RWMutex) // 恢復連線保留資訊讀寫鎖
+
+	resumeSessionMapByTokenHash   = make(map[string]*resumeSession) // 權杖雜湊對應保留資訊
+	resumeTokenHashMapByClient    = make(map[*client]string)        // 連線對應權杖雜湊
+	resumeSessionGraceDuration    time.Duration                     // 逾時斷線後保留時間(初始化網路中心時由設定檔取得)
+	errResumeTokenInvalid         = errors.New(`恢復連線權杖無效或已過期，請重新登入`)
+	errResumeTokenDeviceIncorrect = errors.New(`恢復連線權杖與裝置不符，請重新登入`)
+)
+
+// 恢復連線指令
+func init() {
+
+	// 恢復連線
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfResumeSession,
+		name:                 `恢復連線`,
+		requiredFields:       []string{`resumeToken`, `deviceID`, `deviceBrand`},
+		isLoginRequired:      false,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleResumeSessionCommand,
+	})
+
+}
+
+// getResumeTokenHash - 取得恢復連線權杖的雜湊(權杖本身不保存)
+/**
+ * @param string resumeToken 恢復連線權杖
+ * @return string 權杖雜湊
+ */
+func getResumeTokenHash(resumeToken string) string {
+	hashByteArray := sha256.Sum256([]byte(resumeToken))
+	return hex.EncodeToString(hashByteArray[:])
+}
+
+// deleteResumeSessionOfTokenHash - 刪除權杖的保留資訊並停止保留(需已上寫鎖)
+/**
+ * @param string tokenHash 權杖雜湊
+ * @return []websocketData returnHeldOutputData 保留期間收到的輸出資料
+ */
+func deleteResumeSessionOfTokenHash(tokenHash string) (returnHeldOutputData []websocketData) {
+
+	resumeSessionPointer, ok := resumeSessionMapByTokenHash[tokenHash]
+
+	if !ok {
+		return // 回傳
+	}
+
+	delete(resumeSessionMapByTokenHash, tokenHash)
+	delete(resumeTokenHashMapByClient, resumeSessionPointer.clientPointer)
+
+	if resumeSessionPointer.isHeld {
+		resumeSessionPointer.expiryTimer.Stop()
+		close(resumeSessionPointer.stopDrainingChannel)
+		returnHeldOutputData = <-resumeSessionPointer.heldOutputDataChannel
+	}
+
+	return // 回傳
+}
+
+// issueResumeToken - 登入成功後發給連線恢復連線權杖(同一連線只保留最新的權杖)
+/**
+ * @param *client clientPointer 連線指標
+ * @return string returnResumeToken 恢復連線權杖(產生失敗則為空)
+ */
+func issueResumeToken(clientPointer *client) (returnResumeToken string) {
+
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer)
+
+	if nil == infoPointer || nil == infoPointer.DevicePointer {
+		return // 回傳
+	}
+
+	tokenByteArray := make([]byte, resumeTokenByteLength)
+
+	if _, err := rand.Read(tokenByteArray); nil != err {
+		return // 回傳
+	}
+
+	returnResumeToken = hex.EncodeToString(tokenByteArray)
+	tokenHash := getResumeTokenHash(returnResumeToken)
+
+	resumeSessionReadWriteLock.Lock() // 寫鎖
+
+	deleteResumeSessionOfTokenHash(resumeTokenHashMapByClient[clientPointer]) // 舊權杖失效
+
+	resumeSessionMapByTokenHash[tokenHash] = &resumeSession{
+		clientPointer: clientPointer,
+		deviceKey:     getDeviceKey(infoPointer.DevicePointer.DeviceID, infoPointer.DevicePointer.DeviceBrand),
+	}
+	resumeTokenHashMapByClient[clientPointer] = tokenHash
+
+	resumeSessionReadWriteLock.Unlock() // 解開寫鎖
+
+	return // 回傳
+}
+
+// revokeResumeToken - 使連線的恢復連線權杖失效(登出、被重複登入踢除或離線時)
+/**
+ * @param *client clientPointer 連線指標
+ */
+func revokeResumeToken(clientPointer *client) {
+	resumeSessionReadWriteLock.Lock()                                         // 寫鎖
+	deleteResumeSessionOfTokenHash(resumeTokenHashMapByClient[clientPointer]) // 權杖失效
+	resumeSessionReadWriteLock.Unlock()                                       // 解開寫鎖
+}
+
+// holdResumeSession - 連線逾時時，若有恢復連線權杖則保留登入資訊、房間與狀態，等待裝置恢復連線
+/**
+ * @param *client clientPointer 連線指標
+ * @return bool returnIsHeld 是否開始保留(沒有權杖或未登入則否)
+ */
+func holdResumeSession(clientPointer *client) (returnIsHeld bool) {
+
+	resumeSessionReadWriteLock.Lock()         // 寫鎖
+	defer resumeSessionReadWriteLock.Unlock() // 記得解開寫鎖
+
+	tokenHash, ok := resumeTokenHashMapByClient[clientPointer]
+
+	if !ok {
+		return // 回傳
+	}
+
+	resumeSessionPointer := resumeSessionMapByTokenHash[tokenHash]
+
+	if resumeSessionPointer.isHeld {
+		return // 回傳
+	}
+
+	if _, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); !ok {
+		return // 回傳
+	}
+
+	resumeSessionPointer.isHeld = true
+	resumeSessionPointer.stopDrainingChannel = make(chan bool)
+	resumeSessionPointer.heldOutputDataChannel = make(chan []websocketData, 1)
+
+	// 舊連線已不再寫出，改由此處接收輸出資料，避免廣播時通道塞滿
+	go keepDrainingOutputChannel(clientPointer.outputChannel, resumeSessionPointer.stopDrainingChannel, resumeSessionPointer.heldOutputDataChannel)
+
+	resumeSessionPointer.expiryTimer = time.AfterFunc(resumeSessionGraceDuration, func() {
+		expireResumeSession(tokenHash, resumeSessionPointer)
+	})
+
+	returnIsHeld = true
+
+	return // 回傳
+}
+
+// keepDrainingOutputChannel - 保留期間接收舊連線的輸出資料(只留最新的 channelSize 筆)，停止時交回
+/**
+ * @param chan websocketData outputChannel 舊連線輸出通道
+ * @param chan bool stopChannel 停止通道
+ * @param chan []websocketData heldOutputDataChannel 交回輸出資料的通道
+ */
+func keepDrainingOutputChannel(outputChannel chan websocketData, stopChannel chan bool, heldOutputDataChannel chan []websocketData) {
+
+	heldOutputData := []websocketData{}
+
+	for {
+
+		select {
+
+		case outputData := <-outputChannel:
+
+			if len(heldOutputData) >= channelSize {
+				heldOutputData = heldOutputData[1:]
+			}
+
+			heldOutputData = append(heldOutputData, outputData)
+
+		case <-stopChannel:
+			heldOutputDataChannel <- heldOutputData
+			return // 回傳
+
+		}
+
+	}
+
+}
+
+// expireResumeSession - 保留期限已到而裝置未恢復連線，裝置改為離線並廣播
+/**
+ * @param string tokenHash 權杖雜湊
+ * @param *resumeSession resumeSessionPointer 保留資訊指標
+ */
+func expireResumeSession(tokenHash string, resumeSessionPointer *resumeSession) {
+
+	resumeSessionReadWriteLock.Lock() // 寫鎖
+
+	if resumeSessionMapByTokenHash[tokenHash] != resumeSessionPointer { // 已恢復連線或已失效
+		resumeSessionReadWriteLock.Unlock() // 解開寫鎖
+		return                              // 回傳
+	}
+
+	deleteResumeSessionOfTokenHash(tokenHash)
+
+	resumeSessionReadWriteLock.Unlock() // 解開寫鎖
+
+	processClientTimeoutOffline(resumeSessionPointer.clientPointer, `伺服器-恢復連線保留期限已到`, `-保留期限內未恢復連線`, AuditEventResumeExpired)
+}
+
+// resumeClientSession - 以恢復連線權杖將舊連線的登入資訊、房間與狀態移到新連線，並換發新權杖
+/**
+ * @param *client clientPointer 新連線指標
+ * @param string resumeToken 恢復連線權杖
+ * @param string deviceKey 新連線宣告的裝置關鍵字
+ * @return *Info returnInfoPointer 登入資訊
+ * @return string returnResumeToken 新的恢復連線權杖
+ * @return []websocketData returnHeldOutputData 保留期間收到的輸出資料
+ * @return *client returnOldClientPointer 舊連線指標
+ * @return error returnError 錯誤
+ */
+func resumeClientSession(clientPointer *client, resumeToken string, deviceKey string) (returnInfoPointer *Info, returnResumeToken string, returnHeldOutputData []websocketData, returnOldClientPointer *client, returnError error) {
+
+	tokenByteArray := make([]byte, resumeTokenByteLength)
+
+	if _, err := rand.Read(tokenByteArray); nil != err {
+		returnError = err
+		return // 回傳
+	}
+
+	newResumeToken := hex.EncodeToString(tokenByteArray)
+	tokenHash := getResumeTokenHash(resumeToken)
+
+	resumeSessionReadWriteLock.Lock()         // 寫鎖
+	defer resumeSessionReadWriteLock.Unlock() // 記得解開寫鎖
+
+	resumeSessionPointer, ok := resumeSessionMapByTokenHash[tokenHash]
+
+	if !ok || clientPointer == resumeSessionPointer.clientPointer {
+		returnError = errResumeTokenInvalid
+		return // 回傳
+	}
+
+	if deviceKey != resumeSessionPointer.deviceKey {
+		returnError = errResumeTokenDeviceIncorrect
+		return // 回傳
+	}
+
+	returnOldClientPointer = resumeSessionPointer.clientPointer
+	returnHeldOutputData = deleteResumeSessionOfTokenHash(tokenHash) // 舊權杖失效
+
+	if returnInfoPointer, ok = sessionRegistryPointer.moveClient(returnOldClientPointer, clientPointer); !ok {
+		returnError = errResumeTokenInvalid
+		return // 回傳
+	}
+
+	deleteResumeSessionOfTokenHash(resumeTokenHashMapByClient[clientPointer]) // 新連線原本的權杖失效
+
+	newTokenHash := getResumeTokenHash(newResumeToken)
+
+	resumeSessionMapByTokenHash[newTokenHash] = &resumeSession{
+		clientPointer: clientPointer,
+		deviceKey:     deviceKey,
+	}
+	resumeTokenHashMapByClient[clientPointer] = newTokenHash
+
+	returnResumeToken = newResumeToken
+
+	return // 回傳
+}
+
+// handleResumeSessionCommand - 處理<恢復連線>指令(不廣播離線與上線)
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令
+ * @param string whatKindCommandString 指令類型字串
+ */
+func handleResumeSessionCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	remoteHost := getClientRemoteHost(clientPointer) // 來源位址
+
+	// 來源位址退避中或鎖定中則不驗證權杖
+	if blockError := checkLoginAllowed(``, remoteHost); nil != blockError {
+		details += `-來源位址登入受限-` + blockError.Error()
+		recordAuditOfCommand(AuditEventLoginFailed, ``, command, `拒絕恢復連線,來源位址=`+remoteHost+`:`+blockError.Error())
+		processResponseError(clientPointer, whatKindCommandString, command, details, blockError)
+		return // 跳出
+	}
+
+	if _, ok := sessionRegistryPointer.getInfoPointerAndOK(clientPointer); ok {
+		details += `-此連線已登入`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeAlreadyLoggedIn)
+		return // 跳出
+	}
+
+	infoPointer, resumeToken, heldOutputData, oldClientPointer, resumeError := resumeClientSession(clientPointer, command.ResumeToken, getDeviceKey(command.DeviceID, command.DeviceBrand))
+
+	if nil != resumeError {
+		details += `-恢復連線失敗-` + resumeError.Error()
+		recordLoginFailure(``, remoteHost) // 記錄來源位址失敗(累計退避與鎖定)
+		recordAuditOfCommand(AuditEventLoginFailed, ``, command, `恢復連線失敗,來源位址=`+remoteHost+`:`+resumeError.Error())
+		processResponseError(clientPointer, whatKindCommandString, command, details, resumeError)
+		return // 跳出
+	}
+
+	details += `-恢復連線成功,保留期間輸出資料` + fmt.Sprint(len(heldOutputData)) + `筆`
+
+	disconnectHub(oldClientPointer) // 舊連線若仍未斷線則斷線(其逾時偵測已找不到登入資訊，不會再廣播離線)
+
+	if `` == command.Locale { // 未指定語系則沿用舊連線的語系
+		clientPointer.setLocale(oldClientPointer.getLocale())
+	}
+
+	response := ResumeSessionResponse{
+		Command:       command.Command,
+		CommandType:   CommandTypeNumberOfAPIResponse,
+		ResultCode:    ResultCodeSuccess,
+		ResultName:    getResultName(ResultCodeSuccess),
+		Results:       getResultMessage(clientPointer.getLocale(), ResultCodeSuccess),
+		TransactionID: command.TransactionID,
+		ResumeToken:   resumeToken,
+	}
+
+	if nil != infoPointer.AccountPointer {
+		response.Account = *(infoPointer.AccountPointer) //取帳號copy複本(密碼雜湊與驗證碼不回傳給client)
+	}
+
+	if nil != infoPointer.DevicePointer {
+		response.Device = *(infoPointer.DevicePointer)
+	}
+
+	if jsonBytes, err := json.Marshal(response); nil == err {
+		clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+	} else {
+		details += `-後端json轉換出錯`
+		loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+		processLoggerErrorf(whatKindCommandString, details, command, loggerFields)
+	}
+
+	// 補送保留期間的輸出資料(新連線通道已滿則捨棄)
+	for _, outputData := range heldOutputData {
+		select {
+		case clientPointer.outputChannel <- outputData:
+		default:
+		}
+	}
+
+	recordAuditOfClient(AuditEventLogin, clientPointer, ``, whatKindCommandString) // 稽核紀錄:登入(恢復連線)
+
+	// 一般logger
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+}
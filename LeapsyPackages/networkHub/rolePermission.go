@@ -0,0 +1,306 @@
+package networkHub
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"../configurations"
+	"../logings"
+	"github.com/juliangruber/go-intersect"
+)
+
+// 帳號角色
+const (
+	RoleAdmin      = `admin`      // 管理者
+	RoleSupervisor = `supervisor` // 主管(可結束所屬場域內任何通話)
+	RoleExpert     = `expert`     // 專家
+	RoleFrontline  = `frontline`  // 一線人員
+	RoleObserver   = `observer`   // 觀察者(只能靜音加入房間旁聽)
+)
+
+// rolePermission - 角色權限
+type rolePermission struct {
+	isAllCommands  bool         // 是否可使用所有指令
+	commandNumbers map[int]bool // 可使用的指令代碼
+	isAllAreas     bool         // 是否可管理所有場域(否則只能管理自己所屬的場域)
+	isMutedInRoom  bool         // 加入房間時是否強制關閉相機與麥克風
+}
+
+const (
+	roleConfigSectionName = `roles` // 角色權限設定區塊名
+)
+
+var (
+	rolePermissionMap map[string]*rolePermission // 角色對應權限(初始化網路中心時載入)
+
+	errPermissionDenied     = errors.New(`此帳號角色沒有使用此指令的權限`)
+	errPermissionAreaDenied = errors.New(`此帳號角色沒有管理此場域的權限`)
+)
+
+// splitConfigList - 將設定值依逗號切開(去除空白與空項目)
+/**
+ * @param string value 設定值
+ * @return []string returnList 切開後的項目
+ */
+func splitConfigList(value string) (returnList []string) {
+
+	for _, element := range strings.Split(value, `,`) {
+		if element = strings.TrimSpace(element); `` != element {
+			returnList = append(returnList, element)
+		}
+	}
+
+	return // 回傳
+}
+
+// parseRolePermissions - 解析角色權限設定區塊(每個角色都須設定可用指令，指令代碼須為數字或*，旗標只能列出已知角色)
+/**
+ * @param map[string]string roleConfigMap 角色權限設定區塊
+ * @return map[string]*rolePermission returnRolePermissionMap 角色對應權限
+ * @return error returnError 設定錯誤(只回傳第一個錯誤)
+ */
+func parseRolePermissions(roleConfigMap map[string]string) (returnRolePermissionMap map[string]*rolePermission, returnError error) {
+
+	returnRolePermissionMap = make(map[string]*rolePermission)
+
+	for _, role := range []string{RoleAdmin, RoleSupervisor, RoleExpert, RoleFrontline, RoleObserver} {
+
+		rolePermissionPointer := &rolePermission{commandNumbers: make(map[int]bool)}
+
+		commandsValue, ok := roleConfigMap[role+`-commands`]
+
+		if !ok && nil == returnError {
+			returnError = errors.New(`角色 ` + role + ` 未設定 ` + role + `-commands`)
+		}
+
+		for _, element := range splitConfigList(commandsValue) {
+
+			if `*` == element {
+				rolePermissionPointer.isAllCommands = true
+				continue
+			}
+
+			commandNumber, atoiError := strconv.Atoi(element)
+
+			if nil != atoiError {
+				if nil == returnError {
+					returnError = errors.New(`角色 ` + role + ` 的指令代碼 ` + element + ` 不是數字`)
+				}
+				continue
+			}
+
+			rolePermissionPointer.commandNumbers[commandNumber] = true
+		}
+
+		returnRolePermissionMap[role] = rolePermissionPointer
+	}
+
+	for _, role := range splitConfigList(roleConfigMap[`all-area-roles`]) {
+		if rolePermissionPointer, ok := returnRolePermissionMap[role]; ok {
+			rolePermissionPointer.isAllAreas = true
+		} else if nil == returnError {
+			returnError = errors.New(`all-area-roles 有不存在的角色 ` + role)
+		}
+	}
+
+	for _, role := range splitConfigList(roleConfigMap[`muted-roles`]) {
+		if rolePermissionPointer, ok := returnRolePermissionMap[role]; ok {
+			rolePermissionPointer.isMutedInRoom = true
+		} else if nil == returnError {
+			returnError = errors.New(`muted-roles 有不存在的角色 ` + role)
+		}
+	}
+
+	return // 回傳
+}
+
+// loadRolePermissionsOrPanic - 從設定檔載入各角色權限，設定錯誤則逐層結束程式
+/**
+ * @return map[string]*rolePermission returnRolePermissionMap 角色對應權限
+ */
+func loadRolePermissionsOrPanic() (returnRolePermissionMap map[string]*rolePermission) {
+	return loadRolePermissionsFromConfigOrPanic(configurations.GetConfigSection(roleConfigSectionName))
+}
+
+// loadRolePermissionsFromConfigOrPanic - 依角色權限設定區塊載入各角色權限，設定錯誤則逐層結束程式
+/**
+ * @param map[string]string roleConfigMap 角色權限設定區塊
+ * @return map[string]*rolePermission returnRolePermissionMap 角色對應權限
+ */
+func loadRolePermissionsFromConfigOrPanic(roleConfigMap map[string]string) (returnRolePermissionMap map[string]*rolePermission) {
+
+	returnRolePermissionMap, loadError := parseRolePermissions(roleConfigMap)
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`載入角色權限 %d 種 `},
+		[]interface{}{len(returnRolePermissionMap)},
+		loadError,
+	)
+
+	if nil != loadError {
+		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+	}
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+
+	return // 回傳
+}
+
+// getAccountRole - 取得帳號角色(未設定角色時依專家、一線人員旗標判斷)
+/**
+ * @param *Account accountPointer 帳號指標
+ * @return string 角色
+ */
+func getAccountRole(accountPointer *Account) string {
+
+	switch {
+
+	case nil == accountPointer:
+		return ``
+
+	case `` != accountPointer.Role:
+		return accountPointer.Role
+
+	case 1 == accountPointer.IsExpert:
+		return RoleExpert
+
+	case 1 == accountPointer.IsFrontline:
+		return RoleFrontline
+
+	default:
+		return RoleObserver
+
+	}
+
+}
+
+// getRolePermission - 取得連線帳號角色的權限(未登入或角色不存在則為nil，視同沒有任何權限)
+/**
+ * @param *Info infoPointer 連線登入資訊
+ * @return *rolePermission 角色權限指標
+ */
+func getRolePermission(infoPointer *Info) *rolePermission {
+
+	if nil == infoPointer {
+		return nil
+	}
+
+	return rolePermissionMap[getAccountRole(infoPointer.AccountPointer)]
+}
+
+// checkCommandPermission - 檢查連線帳號角色是否可使用某指令
+/**
+ * @param *Info infoPointer 連線登入資訊
+ * @param int commandNumber 指令代碼
+ * @return error 沒有權限的錯誤(有權限則為nil)
+ */
+func checkCommandPermission(infoPointer *Info, commandNumber int) error {
+
+	rolePermissionPointer := getRolePermission(infoPointer)
+
+	if nil == rolePermissionPointer || (!rolePermissionPointer.isAllCommands && !rolePermissionPointer.commandNumbers[commandNumber]) {
+		return errPermissionDenied
+	}
+
+	return nil
+}
+
+// checkAreaPermission - 檢查連線帳號角色是否可管理某場域(含自己所屬場域的下層場域)
+/**
+ * @param *Info infoPointer 連線登入資訊
+ * @param []int area 目標場域
+ * @return error 沒有權限的錯誤(有權限則為nil)
+ */
+func checkAreaPermission(infoPointer *Info, area []int) error {
+
+	rolePermissionPointer := getRolePermission(infoPointer)
+
+	if nil == rolePermissionPointer {
+		return errPermissionAreaDenied
+	}
+
+	if rolePermissionPointer.isAllAreas {
+		return nil
+	}
+
+	if 0 == len(intersect.Hash(getAreaWithDescendants(getSessionIndexKeys(infoPointer).area), area)) {
+		return errPermissionAreaDenied
+	}
+
+	return nil
+}
+
+// isMutedInRoom - 連線帳號角色加入房間時是否強制關閉相機與麥克風
+/**
+ * @param *Info infoPointer 連線登入資訊
+ * @return bool 是否強制靜音
+ */
+func isMutedInRoom(infoPointer *Info) bool {
+	rolePermissionPointer := getRolePermission(infoPointer)
+	return nil != rolePermissionPointer && rolePermissionPointer.isMutedInRoom
+}
+
+// isFrontlineInCall - 連線帳號在通話中是否為一線人員(依角色判斷)
+/**
+ * @param *Info infoPointer 連線登入資訊
+ * @return bool 是否為一線人員
+ */
+func isFrontlineInCall(infoPointer *Info) bool {
+	return RoleFrontline == getAccountRole(getAccountPointerOfInfo(infoPointer))
+}
+
+// isExpertInCall - 連線帳號在通話中是否為回應一線人員的專家(依角色判斷，主管與管理者也算，強制靜音的旁聽角色不算)
+/**
+ * @param *Info infoPointer 連線登入資訊
+ * @return bool 是否為回應的專家
+ */
+func isExpertInCall(infoPointer *Info) bool {
+
+	role := getAccountRole(getAccountPointerOfInfo(infoPointer))
+
+	return `` != role && RoleFrontline != role && !isMutedInRoom(infoPointer)
+}
+
+// permissionCommandMiddleware - 中介層:需登入的指令檢查帳號角色是否有使用此指令的權限
+func permissionCommandMiddleware(next CommandHandleFunc) CommandHandleFunc {
+	return func(commandContextPointer *CommandContext) {
+
+		if commandContextPointer.Handler.IsLoginRequired() {
+
+			infoPointer := commandContextPointer.GetInfoPointer()
+
+			if permissionError := checkCommandPermission(infoPointer, commandContextPointer.Command.Command); nil != permissionError {
+
+				details := `-權限不足-` + permissionError.Error() + `,角色=` + getAccountRole(getAccountPointerOfInfo(infoPointer))
+
+				// 失敗:Response
+				commandContextPointer.Respond(ResultCodePermissionDenied)
+
+				// 警告logger
+				loggerFields := getLoggerFields(commandContextPointer.clientPointer) //取當下的記錄欄位
+				processLoggerWarnf(commandContextPointer.WhatKindCommandString, details, commandContextPointer.Command, loggerFields)
+
+				return // 回傳
+			}
+
+		}
+
+		next(commandContextPointer)
+	}
+}
+
+// getAccountPointerOfInfo - 取得登入資訊中的帳號(登入資訊為空則為nil)
+/**
+ * @param *Info infoPointer 連線登入資訊
+ * @return *Account 帳號指標
+ */
+func getAccountPointerOfInfo(infoPointer *Info) *Account {
+
+	if nil == infoPointer {
+		return nil
+	}
+
+	return infoPointer.AccountPointer
+}
@@ -0,0 +1,234 @@
+package networkHub
+
+import (
+	"testing"
+)
+
+// TestGetOnlineIdleExpertInfoMapInArea - 依角色挑選同場域的閒置專家(主管與管理者也算，強制靜音的旁聽角色不算)，不看專家旗標
+func TestGetOnlineIdleExpertInfoMapInArea(t *testing.T) {
+
+	defer func(originalRolePermissionMap map[string]*rolePermission, originalSessionRegistryPointer *SessionRegistry) {
+		rolePermissionMap = originalRolePermissionMap
+		sessionRegistryPointer = originalSessionRegistryPointer
+	}(rolePermissionMap, sessionRegistryPointer)
+
+	rolePermissionMap = map[string]*rolePermission{
+		RoleAdmin:      {isAllCommands: true, isAllAreas: true},
+		RoleSupervisor: {isAllCommands: true},
+		RoleExpert:     {isAllCommands: true},
+		RoleFrontline:  {isAllCommands: true},
+		RoleObserver:   {isAllCommands: true, isMutedInRoom: true},
+	}
+
+	testCases := []struct {
+		name         string
+		role         string
+		isExpert     int // 專家旗標:1是,2否
+		isFrontline  int // 一線人員旗標:1是,2否
+		area         []int
+		deviceStatus DeviceStatusCode
+		wantSelected bool
+	}{
+		{`專家角色`, RoleExpert, 1, 2, []int{1}, DeviceStatusIdle, true},
+		{`專家角色未設定專家旗標`, RoleExpert, 2, 2, []int{1}, DeviceStatusIdle, true},
+		{`主管角色`, RoleSupervisor, 2, 2, []int{1}, DeviceStatusIdle, true},
+		{`管理者角色`, RoleAdmin, 2, 2, []int{1}, DeviceStatusIdle, true},
+		{`觀察者角色設定專家旗標`, RoleObserver, 1, 2, []int{1}, DeviceStatusIdle, false},
+		{`一線人員角色設定專家旗標`, RoleFrontline, 1, 2, []int{1}, DeviceStatusIdle, false},
+		{`未設定角色依專家旗標`, ``, 1, 2, []int{1}, DeviceStatusIdle, true},
+		{`未設定角色也不是專家`, ``, 2, 1, []int{1}, DeviceStatusIdle, false},
+		{`專家不在此場域`, RoleExpert, 1, 2, []int{2}, DeviceStatusIdle, false},
+		{`專家通話中`, RoleExpert, 1, 2, []int{1}, DeviceStatusInCall, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			sessionRegistryPointer = NewSessionRegistry()
+
+			expertClientPointer := &client{}
+
+			sessionRegistryPointer.setInfoPointer(expertClientPointer, &Info{
+				AccountPointer: &Account{UserID: `user`, Role: testCase.role, IsExpert: testCase.isExpert, IsFrontline: testCase.isFrontline, Area: testCase.area},
+				DevicePointer:  &Device{DeviceID: `d1`, DeviceBrand: `b`, DeviceType: 2, DeviceStatus: testCase.deviceStatus},
+			})
+
+			_, isSelected := getOnlineIdleExpertInfoMapInArea([]int{1}, `測試`, Command{}, expertClientPointer)[expertClientPointer]
+
+			if isSelected != testCase.wantSelected {
+				t.Errorf(`是否選為閒置專家 = %v，預期 %v`, isSelected, testCase.wantSelected)
+			}
+
+		})
+	}
+
+}
+
+// TestCheckCommandPermission - 依角色檢查可使用的指令(*可用所有指令，未登入或角色不存在則沒有權限)
+func TestCheckCommandPermission(t *testing.T) {
+
+	defer func(originalRolePermissionMap map[string]*rolePermission) {
+		rolePermissionMap = originalRolePermissionMap
+	}(rolePermissionMap)
+
+	rolePermissionMap = map[string]*rolePermission{
+		RoleAdmin:      {isAllCommands: true},
+		RoleSupervisor: {commandNumbers: map[int]bool{1: true, 2: true, 3: true}},
+		RoleExpert:     {commandNumbers: map[int]bool{1: true, 2: true}},
+		RoleFrontline:  {commandNumbers: map[int]bool{1: true}},
+		RoleObserver:   {commandNumbers: map[int]bool{}},
+	}
+
+	testCases := []struct {
+		name          string
+		infoPointer   *Info
+		commandNumber int
+		wantError     error
+	}{
+		{`管理者可用所有指令`, &Info{AccountPointer: &Account{Role: RoleAdmin}}, 99, nil},
+		{`主管可用已設定指令`, &Info{AccountPointer: &Account{Role: RoleSupervisor}}, 3, nil},
+		{`專家可用已設定指令`, &Info{AccountPointer: &Account{Role: RoleExpert}}, 2, nil},
+		{`專家不可用未設定指令`, &Info{AccountPointer: &Account{Role: RoleExpert}}, 3, errPermissionDenied},
+		{`一線人員可用已設定指令`, &Info{AccountPointer: &Account{Role: RoleFrontline}}, 1, nil},
+		{`一線人員不可用未設定指令`, &Info{AccountPointer: &Account{Role: RoleFrontline}}, 2, errPermissionDenied},
+		{`觀察者沒有任何指令`, &Info{AccountPointer: &Account{Role: RoleObserver}}, 1, errPermissionDenied},
+		{`未設定角色依專家旗標`, &Info{AccountPointer: &Account{IsExpert: 1}}, 2, nil},
+		{`未設定角色依一線人員旗標`, &Info{AccountPointer: &Account{IsExpert: 2, IsFrontline: 1}}, 2, errPermissionDenied},
+		{`不存在的角色`, &Info{AccountPointer: &Account{Role: `guest`}}, 1, errPermissionDenied},
+		{`沒有帳號`, &Info{}, 1, errPermissionDenied},
+		{`未登入`, nil, 1, errPermissionDenied},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if permissionError := checkCommandPermission(testCase.infoPointer, testCase.commandNumber); permissionError != testCase.wantError {
+				t.Errorf(`檢查指令權限錯誤 = %v，預期 %v`, permissionError, testCase.wantError)
+			}
+		})
+	}
+
+}
+
+// TestCheckAreaPermission - 依角色檢查可管理的場域(含所屬場域的下層場域，isAllAreas可管理所有場域)
+func TestCheckAreaPermission(t *testing.T) {
+
+	defer func(originalRolePermissionMap map[string]*rolePermission, originalAreaChildrenMap map[int][]int) {
+		rolePermissionMap = originalRolePermissionMap
+		areaChildrenMap = originalAreaChildrenMap
+	}(rolePermissionMap, areaChildrenMap)
+
+	rolePermissionMap = map[string]*rolePermission{
+		RoleAdmin:      {isAllCommands: true, isAllAreas: true},
+		RoleSupervisor: {isAllCommands: true},
+		RoleExpert:     {isAllCommands: true},
+	}
+
+	// 場域1下有場域2，場域2下有場域3；場域4獨立
+	areaChildrenMap = map[int][]int{1: {2}, 2: {3}}
+
+	// 平板端取帳號場域，眼鏡端取裝置場域
+	getInfoPointer := func(role string, deviceType int, accountArea []int, deviceArea []int) *Info {
+		return &Info{
+			AccountPointer: &Account{Role: role, Area: accountArea},
+			DevicePointer:  &Device{DeviceType: deviceType, Area: deviceArea},
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		infoPointer *Info
+		area        []int
+		wantError   error
+	}{
+		{`所屬場域`, getInfoPointer(RoleSupervisor, 2, []int{1}, nil), []int{1}, nil},
+		{`下層場域`, getInfoPointer(RoleSupervisor, 2, []int{1}, nil), []int{2}, nil},
+		{`下下層場域`, getInfoPointer(RoleSupervisor, 2, []int{1}, nil), []int{3}, nil},
+		{`部分場域重疊`, getInfoPointer(RoleSupervisor, 2, []int{1}, nil), []int{4, 3}, nil},
+		{`上層場域`, getInfoPointer(RoleSupervisor, 2, []int{2}, nil), []int{1}, errPermissionAreaDenied},
+		{`無關場域`, getInfoPointer(RoleExpert, 2, []int{1}, nil), []int{4}, errPermissionAreaDenied},
+		{`眼鏡端依裝置場域`, getInfoPointer(RoleExpert, 1, []int{4}, []int{2}), []int{3}, nil},
+		{`眼鏡端不看帳號場域`, getInfoPointer(RoleExpert, 1, []int{4}, []int{2}), []int{4}, errPermissionAreaDenied},
+		{`沒有所屬場域`, getInfoPointer(RoleExpert, 2, nil, nil), []int{1}, errPermissionAreaDenied},
+		{`可管理所有場域`, getInfoPointer(RoleAdmin, 2, nil, nil), []int{4}, nil},
+		{`不存在的角色`, getInfoPointer(`guest`, 2, []int{1}, nil), []int{1}, errPermissionAreaDenied},
+		{`未登入`, nil, []int{1}, errPermissionAreaDenied},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if permissionError := checkAreaPermission(testCase.infoPointer, testCase.area); permissionError != testCase.wantError {
+				t.Errorf(`檢查場域權限錯誤 = %v，預期 %v`, permissionError, testCase.wantError)
+			}
+		})
+	}
+
+}
+
+// TestLoadRolePermissionsFromConfigOrPanic - 載入角色權限設定(不存在的角色、非數字指令代碼、缺少角色設定都結束程式)
+func TestLoadRolePermissionsFromConfigOrPanic(t *testing.T) {
+
+	// 取得完整的角色權限設定區塊
+	getRoleConfigMap := func(changes map[string]string) map[string]string {
+
+		roleConfigMap := map[string]string{
+			RoleAdmin + `-commands`:      `*`,
+			RoleSupervisor + `-commands`: `1, 2, 3`,
+			RoleExpert + `-commands`:     `1,2`,
+			RoleFrontline + `-commands`:  `1`,
+			RoleObserver + `-commands`:   ``,
+			`all-area-roles`:             RoleAdmin,
+			`muted-roles`:                RoleObserver,
+		}
+
+		for key, value := range changes {
+			if `` == value {
+				delete(roleConfigMap, key)
+			} else {
+				roleConfigMap[key] = value
+			}
+		}
+
+		return roleConfigMap
+	}
+
+	testCases := []struct {
+		name      string
+		changes   map[string]string // 改動的設定(空字串為刪除)
+		wantPanic bool
+	}{
+		{`完整設定`, nil, false},
+		{`指令代碼不是數字`, map[string]string{RoleExpert + `-commands`: `1,two`}, true},
+		{`指令代碼混入其他符號`, map[string]string{RoleFrontline + `-commands`: `1;2`}, true},
+		{`缺少角色設定`, map[string]string{RoleFrontline + `-commands`: ``}, true},
+		{`all-area-roles有不存在的角色`, map[string]string{`all-area-roles`: RoleAdmin + `,root`}, true},
+		{`muted-roles有不存在的角色`, map[string]string{`muted-roles`: `guest`}, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			defer func() {
+				if isPanicked := nil != recover(); isPanicked != testCase.wantPanic {
+					t.Errorf(`是否結束程式 = %v，預期 %v`, isPanicked, testCase.wantPanic)
+				}
+			}()
+
+			loadedRolePermissionMap := loadRolePermissionsFromConfigOrPanic(getRoleConfigMap(testCase.changes))
+
+			// 完整設定時檢查載入內容
+			if adminPermissionPointer := loadedRolePermissionMap[RoleAdmin]; !adminPermissionPointer.isAllCommands || !adminPermissionPointer.isAllAreas {
+				t.Errorf(`管理者權限 = %+v，預期可用所有指令並管理所有場域`, *adminPermissionPointer)
+			}
+
+			if supervisorPermissionPointer := loadedRolePermissionMap[RoleSupervisor]; supervisorPermissionPointer.isAllCommands || 3 != len(supervisorPermissionPointer.commandNumbers) || !supervisorPermissionPointer.commandNumbers[2] {
+				t.Errorf(`主管權限 = %+v，預期可用指令1,2,3`, *supervisorPermissionPointer)
+			}
+
+			if observerPermissionPointer := loadedRolePermissionMap[RoleObserver]; 0 != len(observerPermissionPointer.commandNumbers) || !observerPermissionPointer.isMutedInRoom {
+				t.Errorf(`觀察者權限 = %+v，預期沒有指令且強制靜音`, *observerPermissionPointer)
+			}
+
+		})
+	}
+
+}
@@ -0,0 +1,671 @@
+package networkHub
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"../configurations"
+	"../databases"
+	"../logings"
+	"../paths"
+	"github.com/juliangruber/go-intersect"
+)
+
+const (
+
+	// 代碼-房間狀態
+	RoomStateWaiting = 1 // 等待中(尚未有兩人以上在房間)
+	RoomStateActive  = 2 // 通話中
+	RoomStateClosed  = 3 // 已關閉
+)
+
+// Room - 房間
+type Room struct {
+	RoomID             int       `json:"roomID"`             // 房號
+	CreatorUserID      string    `json:"creatorUserID"`      // 建立者帳號
+	CreatorDeviceID    string    `json:"creatorDeviceID"`    // 建立者裝置ID
+	CreatorDeviceBrand string    `json:"creatorDeviceBrand"` // 建立者裝置品牌
+	Participants       []string  `json:"participants"`       // 參與者裝置關鍵字(裝置ID|裝置品牌)
+	Invitations        []string  `json:"invitations"`        // 受邀請但尚未加入的裝置關鍵字
+	State              int       `json:"state"`              // 房間狀態:1等待中,2通話中,3已關閉
+	CreatedTime        time.Time `json:"createdTime"`        // 建立時間
+	Area               []int     `json:"area"`               // 房間所屬場域
+	callStartedTime    time.Time // 開始通話時間(第一次有兩位參與者時，稽核紀錄計算通話秒數用)
+}
+
+// RoomsInMyAreaResponse - Response-取得同場域房間清單
+type RoomsInMyAreaResponse struct {
+	Command       int    `json:"command"`
+	CommandType   int    `json:"commandType"`
+	ResultCode    int    `json:"resultCode"`
+	ResultName    string `json:"resultName"`
+	Results       string `json:"results"`
+	TransactionID string `json:"transactionID"`
+	Rooms         []Room `json:"rooms"`
+}
+
+// roomSequenceRepository - 房號序號儲存庫(讓房號在重新啟動後仍不重複)
+type roomSequenceRepository interface {
+
+	// loadLastRoomID - 載入最後配發的房號
+	loadLastRoomID() (int, error)
+
+	// saveLastRoomID - 儲存最後配發的房號
+	saveLastRoomID(lastRoomID int) error
+}
+
+// roomSequenceJSON - JSON房號序號檔內容
+type roomSequenceJSON struct {
+	LastRoomID int `json:"lastRoomID"` // 最後配發的房號
+}
+
+// roomSequenceJSONFileRepository - JSON房號序號檔儲存庫
+type roomSequenceJSONFileRepository struct {
+	fileName string // 房號序號檔名
+}
+
+// loadLastRoomID - 載入最後配發的房號
+/**
+ * @return int returnLastRoomID 最後配發的房號
+ * @return error returnError 錯誤
+ */
+func (roomSequenceJSONFileRepositoryPointer *roomSequenceJSONFileRepository) loadLastRoomID() (returnLastRoomID int, returnError error) {
+
+	fileBytes, returnError := ioutil.ReadFile(roomSequenceJSONFileRepositoryPointer.fileName) // 讀取房號序號檔
+
+	if os.IsNotExist(returnError) { // 若房號序號檔不存在，視為尚未配發過房號
+		returnError = nil
+		return // 回傳
+	}
+
+	if nil != returnError { // 若讀取房號序號檔錯誤
+		return // 回傳
+	}
+
+	var sequence roomSequenceJSON // 房號序號檔內容
+
+	if returnError = json.Unmarshal(fileBytes, &sequence); nil == returnError { // 解譯房號序號檔
+		returnLastRoomID = sequence.LastRoomID
+	}
+
+	return // 回傳
+}
+
+// saveLastRoomID - 儲存最後配發的房號
+/**
+ * @param int lastRoomID 最後配發的房號
+ * @return error returnError 錯誤
+ */
+func (roomSequenceJSONFileRepositoryPointer *roomSequenceJSONFileRepository) saveLastRoomID(lastRoomID int) (returnError error) {
+
+	fileBytes, returnError := json.Marshal(roomSequenceJSON{LastRoomID: lastRoomID})
+
+	if nil != returnError { // 若轉換錯誤
+		return // 回傳
+	}
+
+	fileName := roomSequenceJSONFileRepositoryPointer.fileName
+
+	paths.CreateIfPathNotExisted(filepath.Dir(fileName)) // 若房號序號檔所在路徑不存在，則建立路徑
+
+	temporaryFileName := fileName + `.tmp` // 先寫入暫存檔再改名，避免寫到一半中斷造成房號序號檔損毀
+
+	if returnError = ioutil.WriteFile(temporaryFileName, fileBytes, 0644); nil != returnError { // 若寫入暫存檔錯誤
+		return // 回傳
+	}
+
+	returnError = os.Rename(temporaryFileName, fileName) // 以暫存檔取代房號序號檔
+
+	return // 回傳
+}
+
+// roomSequenceSQLiteRepository - SQLite房號序號儲存庫
+type roomSequenceSQLiteRepository struct {
+	tableOncePointer *sync.Once // 只建立一次資料表
+}
+
+const (
+	// 建立房號序號資料表
+	roomSequenceSQLiteCreateTableString = `CREATE TABLE IF NOT EXISTS room_sequence (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		last_room_id INTEGER NOT NULL
+	)`
+
+	// 查詢最後配發的房號
+	roomSequenceSQLiteSelectString = `SELECT last_room_id FROM room_sequence WHERE id = 1`
+
+	// 儲存最後配發的房號
+	roomSequenceSQLiteUpsertString = `INSERT INTO room_sequence (id, last_room_id) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET last_room_id = excluded.last_room_id`
+)
+
+// getDatabase - 取得已建好房號序號資料表的資料庫
+/**
+ * @return *sql.DB 資料庫指標
+ */
+func (roomSequenceSQLiteRepositoryPointer *roomSequenceSQLiteRepository) getDatabase() *sql.DB {
+
+	databasePointer := databases.GetSQLiteDatabaseOrPanic() // 取得資料庫
+
+	roomSequenceSQLiteRepositoryPointer.tableOncePointer.Do(func() {
+
+		_, execError := databasePointer.Exec(roomSequenceSQLiteCreateTableString) // 建立房號序號資料表
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`建立房號序號資料表 `},
+			[]interface{}{},
+			execError,
+		)
+
+		if nil != execError { // 若建立房號序號資料表錯誤
+			logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+		}
+
+	})
+
+	return databasePointer // 回傳資料庫指標
+}
+
+// loadLastRoomID - 載入最後配發的房號
+/**
+ * @return int returnLastRoomID 最後配發的房號
+ * @return error returnError 錯誤
+ */
+func (roomSequenceSQLiteRepositoryPointer *roomSequenceSQLiteRepository) loadLastRoomID() (returnLastRoomID int, returnError error) {
+
+	returnError = roomSequenceSQLiteRepositoryPointer.getDatabase().QueryRow(roomSequenceSQLiteSelectString).Scan(&returnLastRoomID)
+
+	if sql.ErrNoRows == returnError { // 若尚未配發過房號
+		returnError = nil
+	}
+
+	return // 回傳
+}
+
+// saveLastRoomID - 儲存最後配發的房號
+/**
+ * @param int lastRoomID 最後配發的房號
+ * @return error returnError 錯誤
+ */
+func (roomSequenceSQLiteRepositoryPointer *roomSequenceSQLiteRepository) saveLastRoomID(lastRoomID int) (returnError error) {
+	_, returnError = roomSequenceSQLiteRepositoryPointer.getDatabase().Exec(roomSequenceSQLiteUpsertString, lastRoomID)
+	return // 回傳
+}
+
+// newRoomSequenceRepositoryByConfig - 依設定檔建立房號序號儲存庫
+/**
+ * @return roomSequenceRepository 房號序號儲存庫
+ */
+func newRoomSequenceRepositoryByConfig() roomSequenceRepository {
+
+	storeType := configurations.GetConfigValueOrPanic(`room`, `store`) // 房號序號儲存方式
+
+	switch storeType {
+
+	case `json`: // JSON房號序號檔
+		return &roomSequenceJSONFileRepository{fileName: configurations.GetConfigValueOrPanic(`room`, `json-file`)}
+
+	case `sqlite`: // SQLite資料庫
+		return &roomSequenceSQLiteRepository{tableOncePointer: new(sync.Once)}
+
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`建立房號序號儲存庫 %s `},
+		[]interface{}{storeType},
+		errors.New(`[ room ] store 應為 json 或 sqlite`),
+	)
+
+	logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+
+	return nil
+}
+
+// RoomManager - 房間管理器(參與者由連線登記表在房號索引改變時更新；持有房間管理器的鎖時不可再呼叫連線登記表)
+type RoomManager struct {
+	readWriteLock *sync.RWMutex // 讀寫鎖
+
+	roomPointerMap map[int]*Room // 房號對應房間(已關閉的房間會移除)
+
+	sequenceRepository roomSequenceRepository // 房號序號儲存庫
+	lastRoomID         int                    // 最後配發的房號
+	isLastRoomIDLoaded bool                   // 是否已從儲存庫載入最後配發的房號
+}
+
+// NewRoomManager - 建立房間管理器
+/**
+ * @param roomSequenceRepository sequenceRepository 房號序號儲存庫
+ * @return *RoomManager 房間管理器指標
+ */
+func NewRoomManager(sequenceRepository roomSequenceRepository) *RoomManager {
+	return &RoomManager{
+		readWriteLock:      new(sync.RWMutex),
+		roomPointerMap:     make(map[int]*Room),
+		sequenceRepository: sequenceRepository,
+	}
+}
+
+var (
+	roomManagerPointer *RoomManager // 房間管理器
+
+	// 沒有參與者的房間保留時間
+	roomEmptyTimeoutDuration time.Duration
+
+	// 清理房間間隔
+	roomCleanupIntervalDuration time.Duration
+
+	roomCapacity int // 房間人數上限(含一線人員)
+
+	errRoomNotOpen        = errors.New(`房間不存在或已關閉`)
+	errRoomFull           = errors.New(`房間人數已達上限`)
+	errRoomNotInArea      = errors.New(`房間不屬於自己的場域，且未受邀請`)
+	errRoomInviterNotIn   = errors.New(`邀請者不在此房間內`)
+	errRoomAlreadyJoined  = errors.New(`受邀者已在此房間內`)
+	errRoomAlreadyInvited = errors.New(`受邀者已被邀請過`)
+)
+
+// initializeRoomManager - 依設定檔建立房間管理器(含房號序號儲存庫)與房間設定
+func initializeRoomManager() {
+	roomManagerPointer = NewRoomManager(newRoomSequenceRepositoryByConfig())
+	roomEmptyTimeoutDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`room`, `empty-timeout`)) * time.Second
+	roomCleanupIntervalDuration = time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`room`, `cleanup-interval`)) * time.Second
+	roomCapacity = configurations.GetConfigPositiveIntValueOrPanic(`room`, `capacity`)
+}
+
+// createRoom - 建立新房間並配發不重複的房號
+/**
+ * @param *Info creatorInfoPointer 建立者登入資訊
+ * @return Room returnRoom 新房間(副本)
+ * @return error returnError 錯誤(房號序號無法載入或儲存)
+ */
+func (roomManagerPointer *RoomManager) createRoom(creatorInfoPointer *Info) (returnRoom Room, returnError error) {
+
+	roomManagerPointer.readWriteLock.Lock()         // 寫鎖
+	defer roomManagerPointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	if !roomManagerPointer.isLastRoomIDLoaded { // 第一次配發房號前，先從儲存庫載入最後配發的房號
+
+		lastRoomID, loadError := roomManagerPointer.sequenceRepository.loadLastRoomID()
+
+		if nil != loadError { // 若載入錯誤，不配發房號以免重複
+			returnError = loadError
+			return // 回傳
+		}
+
+		roomManagerPointer.lastRoomID = lastRoomID
+		roomManagerPointer.isLastRoomIDLoaded = true
+	}
+
+	newRoomID := roomManagerPointer.lastRoomID + 1
+
+	if returnError = roomManagerPointer.sequenceRepository.saveLastRoomID(newRoomID); nil != returnError { // 先儲存再配發，重新啟動後才不會重複
+		return // 回傳
+	}
+
+	roomManagerPointer.lastRoomID = newRoomID
+
+	roomPointer := &Room{
+		RoomID:       newRoomID,
+		Participants: []string{},
+		Invitations:  []string{},
+		State:        RoomStateWaiting,
+		CreatedTime:  time.Now(),
+	}
+
+	if nil != creatorInfoPointer {
+
+		if nil != creatorInfoPointer.AccountPointer {
+			roomPointer.CreatorUserID = creatorInfoPointer.AccountPointer.UserID
+		}
+
+		if nil != creatorInfoPointer.DevicePointer {
+			roomPointer.CreatorDeviceID = creatorInfoPointer.DevicePointer.DeviceID
+			roomPointer.CreatorDeviceBrand = creatorInfoPointer.DevicePointer.DeviceBrand
+		}
+
+		roomPointer.Area = getSessionIndexKeys(creatorInfoPointer).area // 與連線索引相同:眼鏡端依裝置場域，平板端依帳號場域
+	}
+
+	roomManagerPointer.roomPointerMap[newRoomID] = roomPointer
+
+	returnRoom = copyRoom(roomPointer)
+
+	return // 回傳
+}
+
+// copyRoom - 複製房間(避免在鎖外讀到被修改中的內容)
+/**
+ * @param *Room roomPointer 房間指標
+ * @return Room 房間副本
+ */
+func copyRoom(roomPointer *Room) Room {
+	room := *roomPointer
+	room.Participants = append([]string{}, roomPointer.Participants...)
+	room.Invitations = append([]string{}, roomPointer.Invitations...)
+	room.Area = append([]int{}, roomPointer.Area...)
+	return room
+}
+
+// getLastRoomID - 取得最後配發的房號(記錄用)
+/**
+ * @return int returnLastRoomID 最後配發的房號
+ */
+func (roomManagerPointer *RoomManager) getLastRoomID() (returnLastRoomID int) {
+	roomManagerPointer.readWriteLock.RLock()         // 讀鎖
+	returnLastRoomID = roomManagerPointer.lastRoomID // 最後配發的房號
+	roomManagerPointer.readWriteLock.RUnlock()       // 解開讀鎖
+	return                                           // 回傳
+}
+
+// isRoomOpen - 判斷房間是否存在且尚未關閉
+/**
+ * @param int roomID 房號
+ * @return bool 是否存在且尚未關閉
+ */
+func (roomManagerPointer *RoomManager) isRoomOpen(roomID int) bool {
+	roomManagerPointer.readWriteLock.RLock()         // 讀鎖
+	defer roomManagerPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+	roomPointer, ok := roomManagerPointer.roomPointerMap[roomID]
+	return ok && RoomStateClosed != roomPointer.State
+}
+
+// getOpenRoomCount - 取得開啟中的房間數
+/**
+ * @return int 房間數
+ */
+func (roomManagerPointer *RoomManager) getOpenRoomCount() int {
+
+	roomManagerPointer.readWriteLock.RLock()         // 讀鎖
+	defer roomManagerPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	return len(roomManagerPointer.roomPointerMap)
+}
+
+// getOpenRoom - 取得尚未關閉的房間副本
+/**
+ * @param int roomID 房號
+ * @return Room returnRoom 房間副本
+ * @return bool returnOK 房間是否存在且尚未關閉
+ */
+func (roomManagerPointer *RoomManager) getOpenRoom(roomID int) (returnRoom Room, returnOK bool) {
+
+	roomManagerPointer.readWriteLock.RLock()         // 讀鎖
+	defer roomManagerPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	if roomPointer, ok := roomManagerPointer.roomPointerMap[roomID]; ok && RoomStateClosed != roomPointer.State {
+		returnRoom = copyRoom(roomPointer)
+		returnOK = true
+	}
+
+	return // 回傳
+}
+
+// joinRoom - 裝置進入房間(由連線登記表在房號索引改變時呼叫)
+/**
+ * @param int roomID 房號
+ * @param string deviceKey 裝置關鍵字
+ */
+func (roomManagerPointer *RoomManager) joinRoom(roomID int, deviceKey string) {
+
+	roomManagerPointer.readWriteLock.Lock()         // 寫鎖
+	defer roomManagerPointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	roomPointer, ok := roomManagerPointer.roomPointerMap[roomID]
+
+	if !ok { // 若房間不存在(例如已關閉)
+		return // 回傳
+	}
+
+	if containsString(roomPointer.Participants, deviceKey) { // 已在房間內
+		return // 回傳
+	}
+
+	roomPointer.Participants = append(roomPointer.Participants, deviceKey)
+	roomPointer.Invitations = removeString(roomPointer.Invitations, deviceKey) // 已加入，不再是受邀請者
+	roomPointer.updateState()
+}
+
+// tryJoinRoom - 檢查權限與人數上限後保留房間座位(檢查與加入在同一把鎖內完成，避免同時加入超過上限)
+/**
+ * @param int roomID 房號
+ * @param string deviceKey 裝置關鍵字
+ * @param []int area 加入者所屬場域
+ * @return error returnError 錯誤(無法加入的原因)
+ */
+func (roomManagerPointer *RoomManager) tryJoinRoom(roomID int, deviceKey string, area []int) (returnError error) {
+
+	roomManagerPointer.readWriteLock.Lock()         // 寫鎖
+	defer roomManagerPointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	roomPointer, ok := roomManagerPointer.roomPointerMap[roomID]
+
+	if !ok || RoomStateClosed == roomPointer.State { // 若房間不存在或已關閉
+		returnError = errRoomNotOpen
+		return // 回傳
+	}
+
+	if containsString(roomPointer.Participants, deviceKey) { // 已在房間內
+		return // 回傳
+	}
+
+	if len(roomPointer.Participants) >= roomCapacity { // 若人數已滿
+		returnError = errRoomFull
+		return // 回傳
+	}
+
+	if !containsString(roomPointer.Invitations, deviceKey) && 0 == len(intersect.Hash(roomPointer.Area, area)) { // 若未受邀請且不同場域
+		returnError = errRoomNotInArea
+		return // 回傳
+	}
+
+	roomPointer.Participants = append(roomPointer.Participants, deviceKey)
+	roomPointer.Invitations = removeString(roomPointer.Invitations, deviceKey)
+	roomPointer.updateState()
+
+	return // 回傳
+}
+
+// inviteToRoom - 房間內的參與者邀請其他裝置加入
+/**
+ * @param int roomID 房號
+ * @param string inviterDeviceKey 邀請者裝置關鍵字
+ * @param string inviteeDeviceKey 受邀者裝置關鍵字
+ * @return error returnError 錯誤(無法邀請的原因)
+ */
+func (roomManagerPointer *RoomManager) inviteToRoom(roomID int, inviterDeviceKey string, inviteeDeviceKey string) (returnError error) {
+
+	roomManagerPointer.readWriteLock.Lock()         // 寫鎖
+	defer roomManagerPointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	roomPointer, ok := roomManagerPointer.roomPointerMap[roomID]
+
+	switch {
+
+	case !ok || RoomStateClosed == roomPointer.State: // 房間不存在或已關閉
+		returnError = errRoomNotOpen
+
+	case !containsString(roomPointer.Participants, inviterDeviceKey): // 邀請者不在房間內
+		returnError = errRoomInviterNotIn
+
+	case containsString(roomPointer.Participants, inviteeDeviceKey): // 受邀者已在房間內
+		returnError = errRoomAlreadyJoined
+
+	case containsString(roomPointer.Invitations, inviteeDeviceKey): // 已邀請過
+		returnError = errRoomAlreadyInvited
+
+	case len(roomPointer.Participants) >= roomCapacity: // 人數已滿
+		returnError = errRoomFull
+
+	default:
+		roomPointer.Invitations = append(roomPointer.Invitations, inviteeDeviceKey)
+
+	}
+
+	return // 回傳
+}
+
+// containsString - 判斷字串陣列是否包含某字串
+/**
+ * @param []string list 字串陣列
+ * @param string target 欲尋找的字串
+ * @return bool 是否包含
+ */
+func containsString(list []string, target string) bool {
+
+	for _, element := range list {
+		if element == target {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeString - 從字串陣列移除某字串(回傳新陣列)
+/**
+ * @param []string list 字串陣列
+ * @param string target 欲移除的字串
+ * @return []string returnList 移除後的字串陣列
+ */
+func removeString(list []string, target string) (returnList []string) {
+
+	returnList = []string{}
+
+	for _, element := range list {
+		if element != target {
+			returnList = append(returnList, element)
+		}
+	}
+
+	return // 回傳
+}
+
+// leaveRoom - 裝置離開房間，最後一位參與者離開時關閉房間(由連線登記表在房號索引改變時呼叫)
+/**
+ * @param int roomID 房號
+ * @param string deviceKey 裝置關鍵字
+ */
+func (roomManagerPointer *RoomManager) leaveRoom(roomID int, deviceKey string) {
+
+	roomManagerPointer.readWriteLock.Lock()         // 寫鎖
+	defer roomManagerPointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	roomPointer, ok := roomManagerPointer.roomPointerMap[roomID]
+
+	if !ok { // 若房間不存在
+		return // 回傳
+	}
+
+	roomPointer.Participants = removeString(roomPointer.Participants, deviceKey)
+
+	if 0 == len(roomPointer.Participants) { // 最後一位參與者離開
+		roomManagerPointer.closeRoom(roomPointer, `最後一位參與者離開`)
+		return // 回傳
+	}
+
+	roomPointer.updateState()
+}
+
+// updateState - 依參與者人數更新房間狀態(需已上寫鎖)
+func (roomPointer *Room) updateState() {
+
+	if len(roomPointer.Participants) >= 2 {
+
+		if roomPointer.callStartedTime.IsZero() {
+			roomPointer.callStartedTime = time.Now() // 第一次開始通話
+		}
+
+		roomPointer.State = RoomStateActive
+	} else {
+		roomPointer.State = RoomStateWaiting
+	}
+
+}
+
+// closeRoom - 關閉房間並移除(需已上寫鎖)
+/**
+ * @param *Room roomPointer 房間指標
+ * @param string reason 關閉原因(記錄用)
+ */
+func (roomManagerPointer *RoomManager) closeRoom(roomPointer *Room, reason string) {
+
+	roomPointer.State = RoomStateClosed
+	delete(roomManagerPointer.roomPointerMap, roomPointer.RoomID)
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`關閉房間 %d:%s `},
+		[]interface{}{roomPointer.RoomID, reason},
+		nil,
+	)
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+}
+
+// getRoomsByArea - 取得屬於某些場域的所有房間(副本，依房號排序)
+/**
+ * @param []int area 場域代號
+ * @return []Room returnRooms 房間
+ */
+func (roomManagerPointer *RoomManager) getRoomsByArea(area []int) (returnRooms []Room) {
+
+	returnRooms = []Room{} // 回傳給客戶端時維持空陣列
+
+	areaMap := make(map[int]bool) // 查詢的場域
+	for _, areaNumber := range area {
+		areaMap[areaNumber] = true
+	}
+
+	roomManagerPointer.readWriteLock.RLock() // 讀鎖
+
+	for _, roomPointer := range roomManagerPointer.roomPointerMap {
+		for _, areaNumber := range roomPointer.Area {
+
+			if areaMap[areaNumber] {
+				returnRooms = append(returnRooms, copyRoom(roomPointer))
+				break // 已加入，換下一個房間
+			}
+
+		}
+	}
+
+	roomManagerPointer.readWriteLock.RUnlock() // 解開讀鎖
+
+	sort.Slice(returnRooms, func(i, j int) bool {
+		return returnRooms[i].RoomID < returnRooms[j].RoomID
+	})
+
+	return // 回傳
+}
+
+// cleanupEmptyRooms - 關閉建立後逾時仍沒有參與者的房間
+func (roomManagerPointer *RoomManager) cleanupEmptyRooms() {
+
+	roomManagerPointer.readWriteLock.Lock()         // 寫鎖
+	defer roomManagerPointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	for _, roomPointer := range roomManagerPointer.roomPointerMap {
+		if 0 == len(roomPointer.Participants) && time.Since(roomPointer.CreatedTime) > roomEmptyTimeoutDuration {
+			roomManagerPointer.closeRoom(roomPointer, `建立後超過 `+strconv.Itoa(int(roomEmptyTimeoutDuration/time.Second))+` 秒沒有參與者`)
+		}
+	}
+
+}
+
+// CleanUpEmptyRooms - 定時清理沒有參與者的房間
+func CleanUpEmptyRooms() {
+	for {
+		<-time.After(roomCleanupIntervalDuration) // 等待下次清理
+		roomManagerPointer.cleanupEmptyRooms()    // 清理房間
+	}
+}
@@ -0,0 +1,282 @@
+package networkHub
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRoomSequenceRepository - 測試用的記憶體房號序號儲存庫
+type memoryRoomSequenceRepository struct {
+	lastRoomID int // 最後配發的房號
+}
+
+// loadLastRoomID - 載入最後配發的房號
+func (memoryRoomSequenceRepositoryPointer *memoryRoomSequenceRepository) loadLastRoomID() (int, error) {
+	return memoryRoomSequenceRepositoryPointer.lastRoomID, nil
+}
+
+// saveLastRoomID - 儲存最後配發的房號
+func (memoryRoomSequenceRepositoryPointer *memoryRoomSequenceRepository) saveLastRoomID(lastRoomID int) error {
+	memoryRoomSequenceRepositoryPointer.lastRoomID = lastRoomID
+	return nil
+}
+
+// newTestRoom - 建立測試用房間(指定所屬場域、參與者與受邀請者)
+/**
+ * @param *testing.T t 測試
+ * @param []int area 房間所屬場域
+ * @param []string participants 參與者裝置關鍵字
+ * @param []string invitations 受邀請者裝置關鍵字
+ * @return *RoomManager 房間管理器指標
+ * @return int 房號
+ */
+func newTestRoom(t *testing.T, area []int, participants []string, invitations []string) (*RoomManager, int) {
+
+	testRoomManagerPointer := NewRoomManager(&memoryRoomSequenceRepository{})
+
+	room, createError := testRoomManagerPointer.createRoom(nil)
+
+	if nil != createError {
+		t.Fatalf(`建立房間失敗: %v`, createError)
+	}
+
+	testRoomManagerPointer.roomPointerMap[room.RoomID].Area = area
+	testRoomManagerPointer.roomPointerMap[room.RoomID].Invitations = append([]string{}, invitations...)
+
+	for _, deviceKey := range participants {
+		testRoomManagerPointer.joinRoom(room.RoomID, deviceKey)
+	}
+
+	return testRoomManagerPointer, room.RoomID
+}
+
+// TestRoomManagerCreateRoom - 房號接續儲存庫中最後配發的房號，重新啟動後不重複
+func TestRoomManagerCreateRoom(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		lastRoomID  int // 儲存庫中最後配發的房號(重新啟動前)
+		roomCount   int // 建立房間數
+		wantRoomIDs []int
+	}{
+		{`第一次啟動`, 0, 3, []int{1, 2, 3}},
+		{`重新啟動後接續房號`, 41, 2, []int{42, 43}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			sequenceRepositoryPointer := &memoryRoomSequenceRepository{lastRoomID: testCase.lastRoomID}
+			testRoomManagerPointer := NewRoomManager(sequenceRepositoryPointer)
+
+			for index := 0; index < testCase.roomCount; index++ {
+
+				room, createError := testRoomManagerPointer.createRoom(nil)
+
+				if nil != createError {
+					t.Fatalf(`createRoom() 錯誤 = %v`, createError)
+				}
+
+				if room.RoomID != testCase.wantRoomIDs[index] || RoomStateWaiting != room.State {
+					t.Errorf(`第 %d 間房間 房號 = %d、狀態 = %d，預期房號 %d、狀態 %d`, index+1, room.RoomID, room.State, testCase.wantRoomIDs[index], RoomStateWaiting)
+				}
+
+			}
+
+			if wantLastRoomID := testCase.wantRoomIDs[len(testCase.wantRoomIDs)-1]; sequenceRepositoryPointer.lastRoomID != wantLastRoomID {
+				t.Errorf(`儲存庫中最後配發的房號 = %d，預期 %d`, sequenceRepositoryPointer.lastRoomID, wantLastRoomID)
+			}
+
+		})
+	}
+
+}
+
+// TestRoomManagerTryJoinRoom - 加入房間時檢查人數上限、場域與邀請
+func TestRoomManagerTryJoinRoom(t *testing.T) {
+
+	defer func(originalRoomCapacity int) { roomCapacity = originalRoomCapacity }(roomCapacity)
+	roomCapacity = 3
+
+	testCases := []struct {
+		name                 string
+		participants         []string // 已在房間內的參與者
+		invitations          []string // 受邀請者
+		deviceKey            string   // 加入者
+		area                 []int    // 加入者所屬場域
+		wantError            error
+		wantParticipantCount int
+		wantState            int
+	}{
+		{`同場域加入空房間`, nil, nil, `d1|b`, []int{1}, nil, 1, RoomStateWaiting},
+		{`第二位加入開始通話`, []string{`d1|b`}, nil, `d2|b`, []int{1}, nil, 2, RoomStateActive},
+		{`加入後剛好達上限`, []string{`d1|b`, `d2|b`}, nil, `d3|b`, []int{1}, nil, 3, RoomStateActive},
+		{`已達上限不可加入`, []string{`d1|b`, `d2|b`, `d3|b`}, nil, `d4|b`, []int{1}, errRoomFull, 3, RoomStateActive},
+		{`已達上限時受邀請者也不可加入`, []string{`d1|b`, `d2|b`, `d3|b`}, []string{`d4|b`}, `d4|b`, []int{2}, errRoomFull, 3, RoomStateActive},
+		{`已在房間內重複加入不佔座位`, []string{`d1|b`, `d2|b`, `d3|b`}, nil, `d1|b`, []int{1}, nil, 3, RoomStateActive},
+		{`不同場域且未受邀請`, []string{`d1|b`}, nil, `d2|b`, []int{2}, errRoomNotInArea, 1, RoomStateWaiting},
+		{`不同場域但受邀請`, []string{`d1|b`}, []string{`d2|b`}, `d2|b`, []int{2}, nil, 2, RoomStateActive},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			testRoomManagerPointer, roomID := newTestRoom(t, []int{1}, testCase.participants, testCase.invitations)
+
+			if joinError := testRoomManagerPointer.tryJoinRoom(roomID, testCase.deviceKey, testCase.area); !errors.Is(joinError, testCase.wantError) {
+				t.Fatalf(`tryJoinRoom() 錯誤 = %v，預期 %v`, joinError, testCase.wantError)
+			}
+
+			room, ok := testRoomManagerPointer.roomPointerMap[roomID]
+
+			if !ok {
+				t.Fatalf(`房間 %d 不應關閉`, roomID)
+			}
+
+			if len(room.Participants) != testCase.wantParticipantCount {
+				t.Errorf(`參與者 %v，預期 %d 位`, room.Participants, testCase.wantParticipantCount)
+			}
+
+			if room.State != testCase.wantState {
+				t.Errorf(`房間狀態 = %d，預期 %d`, room.State, testCase.wantState)
+			}
+
+			if nil == testCase.wantError && containsString(room.Invitations, testCase.deviceKey) {
+				t.Errorf(`加入後不應仍在受邀請者 %v 中`, room.Invitations)
+			}
+
+		})
+	}
+
+}
+
+// TestRoomManagerTryJoinRoomConcurrently - 同時加入同一房間時不超過人數上限
+func TestRoomManagerTryJoinRoomConcurrently(t *testing.T) {
+
+	defer func(originalRoomCapacity int) { roomCapacity = originalRoomCapacity }(roomCapacity)
+
+	testCases := []struct {
+		name        string
+		capacity    int
+		joinerCount int
+	}{
+		{`加入者多於上限`, 4, 32},
+		{`加入者等於上限`, 4, 4},
+		{`上限為一`, 1, 16},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			roomCapacity = testCase.capacity
+
+			testRoomManagerPointer, roomID := newTestRoom(t, []int{1}, nil, nil)
+
+			var waitGroup sync.WaitGroup
+			var successCountLock sync.Mutex
+			successCount := 0
+
+			for index := 0; index < testCase.joinerCount; index++ {
+
+				waitGroup.Add(1)
+
+				go func(deviceKey string) {
+
+					defer waitGroup.Done()
+
+					if nil == testRoomManagerPointer.tryJoinRoom(roomID, deviceKey, []int{1}) {
+						successCountLock.Lock()
+						successCount++
+						successCountLock.Unlock()
+					}
+
+				}(`d` + strconv.Itoa(index) + `|b`)
+
+			}
+
+			waitGroup.Wait()
+
+			wantCount := testCase.capacity
+
+			if testCase.joinerCount < wantCount {
+				wantCount = testCase.joinerCount
+			}
+
+			room := testRoomManagerPointer.roomPointerMap[roomID]
+
+			if successCount != wantCount || len(room.Participants) != wantCount {
+				t.Errorf(`成功加入 %d 位、參與者 %d 位，預期皆為 %d 位`, successCount, len(room.Participants), wantCount)
+			}
+
+		})
+	}
+
+}
+
+// TestRoomManagerCloseEmptyRooms - 最後一位參與者離開時關閉房間，建立後逾時仍沒有參與者的房間於清理時關閉
+func TestRoomManagerCloseEmptyRooms(t *testing.T) {
+
+	defer func(originalDuration time.Duration) { roomEmptyTimeoutDuration = originalDuration }(roomEmptyTimeoutDuration)
+	roomEmptyTimeoutDuration = time.Minute
+
+	testCases := []struct {
+		name         string
+		participants []string      // 加入的參與者
+		leavers      []string      // 之後離開的參與者
+		createdAgo   time.Duration // 房間建立多久
+		isCleanedUp  bool          // 是否執行清理
+		wantOpen     bool
+		wantState    int
+	}{
+		{`最後一位參與者離開`, []string{`d1|b`}, []string{`d1|b`}, 0, false, false, RoomStateClosed},
+		{`兩位都離開`, []string{`d1|b`, `d2|b`}, []string{`d2|b`, `d1|b`}, 0, false, false, RoomStateClosed},
+		{`還有參與者`, []string{`d1|b`, `d2|b`}, []string{`d1|b`}, 0, false, true, RoomStateWaiting},
+		{`非參與者離開`, []string{`d1|b`}, []string{`d9|b`}, 0, false, true, RoomStateWaiting},
+		{`建立後逾時沒有參與者`, nil, nil, 2 * time.Minute, true, false, RoomStateClosed},
+		{`建立後未逾時沒有參與者`, nil, nil, 0, true, true, RoomStateWaiting},
+		{`建立後逾時但有參與者`, []string{`d1|b`}, nil, 2 * time.Minute, true, true, RoomStateWaiting},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			testRoomManagerPointer, roomID := newTestRoom(t, []int{1}, testCase.participants, nil)
+
+			roomPointer := testRoomManagerPointer.roomPointerMap[roomID]
+			roomPointer.CreatedTime = time.Now().Add(-testCase.createdAgo)
+
+			for _, deviceKey := range testCase.leavers {
+				testRoomManagerPointer.leaveRoom(roomID, deviceKey)
+			}
+
+			if testCase.isCleanedUp {
+				testRoomManagerPointer.cleanupEmptyRooms()
+			}
+
+			if isOpen := testRoomManagerPointer.isRoomOpen(roomID); isOpen != testCase.wantOpen {
+				t.Fatalf(`isRoomOpen() = %v，預期 %v`, isOpen, testCase.wantOpen)
+			}
+
+			if roomPointer.State != testCase.wantState {
+				t.Errorf(`房間狀態 = %d，預期 %d`, roomPointer.State, testCase.wantState)
+			}
+
+			if !testCase.wantOpen {
+
+				if joinError := testRoomManagerPointer.tryJoinRoom(roomID, `d1|b`, []int{1}); !errors.Is(joinError, errRoomNotOpen) {
+					t.Errorf(`關閉後加入 tryJoinRoom() 錯誤 = %v，預期 %v`, joinError, errRoomNotOpen)
+				}
+
+			}
+
+			if rooms := testRoomManagerPointer.getRoomsByArea([]int{1}); testCase.wantOpen != (1 == len(rooms)) {
+				t.Errorf(`場域內的房間 %v，預期開啟 %v`, rooms, testCase.wantOpen)
+			}
+
+		})
+	}
+
+}
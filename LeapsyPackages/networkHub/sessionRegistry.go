@@ -0,0 +1,503 @@
+package networkHub
+
+import (
+	"sync"
+)
+
+// SessionRegistry - 連線登入資訊登記表(取代原本無鎖的 clientInfoMap)
+type SessionRegistry struct {
+	readWriteLock *sync.RWMutex // 讀寫鎖
+
+	roomUpdateLock *sync.Mutex // 通知房間管理器的鎖(解開讀寫鎖前取得，讓通知順序與索引更新順序一致)
+
+	infoPointerMap map[*client]*Info // 連線對應登入資訊
+
+	deviceKeyIndex map[string]*client          // 裝置關鍵字對應連線
+	userIDIndex    map[string]map[*client]bool // 使用者登入帳號對應連線
+	areaIndex      map[int]map[*client]bool    // 場域代號對應連線(眼鏡端依裝置場域，平板端依帳號場域)
+	roomIDIndex    map[int]map[*client]bool    // 房號對應連線
+
+	indexKeysMap map[*client]sessionIndexKeys // 連線目前登記在索引中的關鍵字(移除索引時使用)
+}
+
+// sessionIndexKeys - 連線登記在索引中的關鍵字
+type sessionIndexKeys struct {
+	deviceKey string // 裝置關鍵字
+	userID    string // 使用者登入帳號
+	area      []int  // 場域代號
+	roomID    int    // 房號
+}
+
+// roomParticipantChange - 連線更新索引前後的關鍵字(解開讀寫鎖後才據此通知房間管理器)
+type roomParticipantChange struct {
+	oldIndexKeys sessionIndexKeys // 原本的索引關鍵字
+	newIndexKeys sessionIndexKeys // 新的索引關鍵字
+}
+
+// NewSessionRegistry - 建立連線登入資訊登記表
+/**
+ * @return *SessionRegistry 連線登入資訊登記表指標
+ */
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{
+		readWriteLock:  new(sync.RWMutex),
+		roomUpdateLock: new(sync.Mutex),
+		infoPointerMap: make(map[*client]*Info),
+		deviceKeyIndex: make(map[string]*client),
+		userIDIndex:    make(map[string]map[*client]bool),
+		areaIndex:      make(map[int]map[*client]bool),
+		roomIDIndex:    make(map[int]map[*client]bool),
+		indexKeysMap:   make(map[*client]sessionIndexKeys),
+	}
+}
+
+// 連線登入資訊登記表
+var sessionRegistryPointer = NewSessionRegistry()
+
+// getSessionIndexKeys - 依登入資訊取得索引關鍵字
+/**
+ * @param *Info infoPointer 登入資訊
+ * @return sessionIndexKeys returnIndexKeys 索引關鍵字
+ */
+func getSessionIndexKeys(infoPointer *Info) (returnIndexKeys sessionIndexKeys) {
+
+	if nil == infoPointer {
+		return // 回傳
+	}
+
+	devicePointer := infoPointer.DevicePointer
+	accountPointer := infoPointer.AccountPointer
+
+	if nil != accountPointer {
+		returnIndexKeys.userID = accountPointer.UserID
+	}
+
+	if nil != devicePointer {
+
+		returnIndexKeys.deviceKey = getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)
+		returnIndexKeys.roomID = devicePointer.RoomID
+
+		if 1 == devicePointer.DeviceType { // 眼鏡端取裝置場域
+			returnIndexKeys.area = append([]int{}, devicePointer.Area...)
+		} else if 2 == devicePointer.DeviceType && nil != accountPointer { // 平板端取專家帳號場域
+			returnIndexKeys.area = append([]int{}, accountPointer.Area...)
+		}
+
+	}
+
+	return // 回傳
+}
+
+// addClientToIntIndex - 將連線加入某一索引(需已上寫鎖)
+/**
+ * @param map[int]map[*client]bool index 索引
+ * @param int key 關鍵字
+ * @param *client clientPointer 連線指標
+ */
+func addClientToIntIndex(index map[int]map[*client]bool, key int, clientPointer *client) {
+
+	if nil == index[key] {
+		index[key] = make(map[*client]bool)
+	}
+
+	index[key][clientPointer] = true
+}
+
+// removeClientFromIntIndex - 將連線從某一索引移除(需已上寫鎖)
+/**
+ * @param map[int]map[*client]bool index 索引
+ * @param int key 關鍵字
+ * @param *client clientPointer 連線指標
+ */
+func removeClientFromIntIndex(index map[int]map[*client]bool, key int, clientPointer *client) {
+
+	delete(index[key], clientPointer)
+
+	if 0 == len(index[key]) {
+		delete(index, key)
+	}
+
+}
+
+// addIndexes - 依登入資訊將連線加入所有索引(需已上寫鎖)
+/**
+ * @param *client clientPointer 連線指標
+ * @param *Info infoPointer 登入資訊
+ */
+func (sessionRegistryPointer *SessionRegistry) addIndexes(clientPointer *client, infoPointer *Info) {
+
+	indexKeys := getSessionIndexKeys(infoPointer)
+
+	if `` != indexKeys.deviceKey {
+		sessionRegistryPointer.deviceKeyIndex[indexKeys.deviceKey] = clientPointer
+	}
+
+	if `` != indexKeys.userID {
+
+		if nil == sessionRegistryPointer.userIDIndex[indexKeys.userID] {
+			sessionRegistryPointer.userIDIndex[indexKeys.userID] = make(map[*client]bool)
+		}
+
+		sessionRegistryPointer.userIDIndex[indexKeys.userID][clientPointer] = true
+	}
+
+	for _, areaNumber := range indexKeys.area {
+		addClientToIntIndex(sessionRegistryPointer.areaIndex, areaNumber, clientPointer)
+	}
+
+	if 0 != indexKeys.roomID {
+		addClientToIntIndex(sessionRegistryPointer.roomIDIndex, indexKeys.roomID, clientPointer)
+	}
+
+	sessionRegistryPointer.indexKeysMap[clientPointer] = indexKeys
+}
+
+// removeIndexes - 將連線從所有索引移除(需已上寫鎖)
+/**
+ * @param *client clientPointer 連線指標
+ */
+func (sessionRegistryPointer *SessionRegistry) removeIndexes(clientPointer *client) {
+
+	indexKeys, ok := sessionRegistryPointer.indexKeysMap[clientPointer]
+
+	if !ok {
+		return // 回傳
+	}
+
+	if sessionRegistryPointer.deviceKeyIndex[indexKeys.deviceKey] == clientPointer { // 重複登入時裝置關鍵字可能已指向新連線
+		delete(sessionRegistryPointer.deviceKeyIndex, indexKeys.deviceKey)
+	}
+
+	delete(sessionRegistryPointer.userIDIndex[indexKeys.userID], clientPointer)
+
+	if 0 == len(sessionRegistryPointer.userIDIndex[indexKeys.userID]) {
+		delete(sessionRegistryPointer.userIDIndex, indexKeys.userID)
+	}
+
+	for _, areaNumber := range indexKeys.area {
+		removeClientFromIntIndex(sessionRegistryPointer.areaIndex, areaNumber, clientPointer)
+	}
+
+	removeClientFromIntIndex(sessionRegistryPointer.roomIDIndex, indexKeys.roomID, clientPointer)
+
+	delete(sessionRegistryPointer.indexKeysMap, clientPointer)
+}
+
+// replaceIndexes - 依登入資訊重新登記連線的所有索引(需已上寫鎖)
+/**
+ * @param *client clientPointer 連線指標
+ * @param *Info infoPointer 登入資訊
+ * @return roomParticipantChange returnChange 更新索引前後的關鍵字(解開寫鎖後通知房間管理器)
+ */
+func (sessionRegistryPointer *SessionRegistry) replaceIndexes(clientPointer *client, infoPointer *Info) (returnChange roomParticipantChange) {
+
+	returnChange.oldIndexKeys = sessionRegistryPointer.indexKeysMap[clientPointer]
+
+	sessionRegistryPointer.removeIndexes(clientPointer)
+	sessionRegistryPointer.addIndexes(clientPointer, infoPointer)
+
+	returnChange.newIndexKeys = sessionRegistryPointer.indexKeysMap[clientPointer]
+
+	return // 回傳
+}
+
+// unlockAndUpdateRoomParticipants - 解開寫鎖後，依索引更新的順序通知房間管理器(需已上寫鎖，不在登記表的鎖內呼叫房間管理器)
+/**
+ * @param ...roomParticipantChange changes 更新索引前後的關鍵字
+ */
+func (sessionRegistryPointer *SessionRegistry) unlockAndUpdateRoomParticipants(changes ...roomParticipantChange) {
+
+	sessionRegistryPointer.roomUpdateLock.Lock()         // 先取得通知鎖，後更新索引者需等待前者通知完成
+	defer sessionRegistryPointer.roomUpdateLock.Unlock() // 記得解開通知鎖
+
+	sessionRegistryPointer.readWriteLock.Unlock() // 解開寫鎖
+
+	for _, change := range changes {
+		updateRoomParticipants(change.oldIndexKeys, change.newIndexKeys)
+	}
+
+}
+
+// updateRoomParticipants - 依連線前後的索引關鍵字，更新房間參與者
+/**
+ * @param sessionIndexKeys oldIndexKeys 原本的索引關鍵字
+ * @param sessionIndexKeys newIndexKeys 新的索引關鍵字
+ */
+func updateRoomParticipants(oldIndexKeys sessionIndexKeys, newIndexKeys sessionIndexKeys) {
+
+	if oldIndexKeys.roomID == newIndexKeys.roomID && oldIndexKeys.deviceKey == newIndexKeys.deviceKey { // 房間沒有改變
+		return // 回傳
+	}
+
+	if 0 != oldIndexKeys.roomID && `` != oldIndexKeys.deviceKey {
+		roomManagerPointer.leaveRoom(oldIndexKeys.roomID, oldIndexKeys.deviceKey) // 離開原本房間
+	}
+
+	if 0 != newIndexKeys.roomID && `` != newIndexKeys.deviceKey {
+		roomManagerPointer.joinRoom(newIndexKeys.roomID, newIndexKeys.deviceKey) // 進入新房間
+	}
+
+}
+
+// getInfoPointerAndOK - 取得連線的登入資訊與是否已登入
+/**
+ * @param *client clientPointer 連線指標
+ * @return *Info returnInfoPointer 登入資訊
+ * @return bool returnOK 是否已登入
+ */
+func (sessionRegistryPointer *SessionRegistry) getInfoPointerAndOK(clientPointer *client) (returnInfoPointer *Info, returnOK bool) {
+	sessionRegistryPointer.readWriteLock.RLock()                                       // 讀鎖
+	returnInfoPointer, returnOK = sessionRegistryPointer.infoPointerMap[clientPointer] // 取得登入資訊
+	sessionRegistryPointer.readWriteLock.RUnlock()                                     // 解開讀鎖
+	return                                                                             // 回傳
+}
+
+// getInfoPointer - 取得連線的登入資訊(未登入回傳nil)
+/**
+ * @param *client clientPointer 連線指標
+ * @return *Info returnInfoPointer 登入資訊
+ */
+func (sessionRegistryPointer *SessionRegistry) getInfoPointer(clientPointer *client) (returnInfoPointer *Info) {
+	returnInfoPointer, _ = sessionRegistryPointer.getInfoPointerAndOK(clientPointer) // 取得登入資訊
+	return                                                                           // 回傳
+}
+
+// setInfoPointer - 設定連線的登入資訊並更新索引
+/**
+ * @param *client clientPointer 連線指標
+ * @param *Info infoPointer 登入資訊
+ */
+func (sessionRegistryPointer *SessionRegistry) setInfoPointer(clientPointer *client, infoPointer *Info) {
+
+	if nil == clientPointer { // 若連線指標為空
+		return // 回傳
+	}
+
+	sessionRegistryPointer.readWriteLock.Lock() // 寫鎖
+
+	sessionRegistryPointer.infoPointerMap[clientPointer] = infoPointer
+	change := sessionRegistryPointer.replaceIndexes(clientPointer, infoPointer)
+
+	sessionRegistryPointer.unlockAndUpdateRoomParticipants(change) // 解開寫鎖後通知房間管理器
+}
+
+// deleteClient - 移除連線的登入資訊與索引
+/**
+ * @param *client clientPointer 連線指標
+ */
+func (sessionRegistryPointer *SessionRegistry) deleteClient(clientPointer *client) {
+
+	sessionRegistryPointer.readWriteLock.Lock() // 寫鎖
+
+	change := roomParticipantChange{oldIndexKeys: sessionRegistryPointer.indexKeysMap[clientPointer]} // 連線中斷視同離開房間
+
+	sessionRegistryPointer.removeIndexes(clientPointer)
+	delete(sessionRegistryPointer.infoPointerMap, clientPointer)
+
+	sessionRegistryPointer.unlockAndUpdateRoomParticipants(change) // 解開寫鎖後通知房間管理器
+}
+
+// moveClient - 將登入資訊與索引從舊連線移到新連線(恢復連線用，裝置不離開房間)
+/**
+ * @param *client oldClientPointer 舊連線指標
+ * @param *client newClientPointer 新連線指標
+ * @return *Info returnInfoPointer 登入資訊
+ * @return bool returnOK 舊連線是否仍有登入資訊
+ */
+func (sessionRegistryPointer *SessionRegistry) moveClient(oldClientPointer *client, newClientPointer *client) (returnInfoPointer *Info, returnOK bool) {
+
+	if nil == oldClientPointer || nil == newClientPointer { // 若連線指標為空
+		return // 回傳
+	}
+
+	sessionRegistryPointer.readWriteLock.Lock()         // 寫鎖
+	defer sessionRegistryPointer.readWriteLock.Unlock() // 記得解開寫鎖
+
+	if returnInfoPointer, returnOK = sessionRegistryPointer.infoPointerMap[oldClientPointer]; !returnOK {
+		return // 回傳
+	}
+
+	sessionRegistryPointer.removeIndexes(oldClientPointer)
+	delete(sessionRegistryPointer.infoPointerMap, oldClientPointer)
+
+	sessionRegistryPointer.infoPointerMap[newClientPointer] = returnInfoPointer
+	sessionRegistryPointer.addIndexes(newClientPointer, returnInfoPointer) // 房號與裝置關鍵字不變，不需通知房間管理器
+
+	return // 回傳
+}
+
+// reindexClient - 連線的房號或場域改變後，重新整理該連線的索引
+/**
+ * @param *client clientPointer 連線指標
+ */
+func (sessionRegistryPointer *SessionRegistry) reindexClient(clientPointer *client) {
+
+	sessionRegistryPointer.readWriteLock.Lock() // 寫鎖
+
+	changes := []roomParticipantChange{}
+
+	if infoPointer, ok := sessionRegistryPointer.infoPointerMap[clientPointer]; ok {
+		changes = append(changes, sessionRegistryPointer.replaceIndexes(clientPointer, infoPointer))
+	}
+
+	sessionRegistryPointer.unlockAndUpdateRoomParticipants(changes...) // 解開寫鎖後通知房間管理器
+}
+
+// reindexDevicePointer - 裝置的房號或場域改變後，重新整理使用此裝置之連線的索引
+/**
+ * @param *Device devicePointer 裝置指標
+ */
+func (sessionRegistryPointer *SessionRegistry) reindexDevicePointer(devicePointer *Device) {
+
+	if nil == devicePointer { // 若裝置指標為空
+		return // 回傳
+	}
+
+	sessionRegistryPointer.readWriteLock.Lock() // 寫鎖
+
+	changes := []roomParticipantChange{}
+
+	if clientPointer, ok := sessionRegistryPointer.deviceKeyIndex[getDeviceKey(devicePointer.DeviceID, devicePointer.DeviceBrand)]; ok { // 裝置在線上
+		if infoPointer := sessionRegistryPointer.infoPointerMap[clientPointer]; nil != infoPointer && devicePointer == infoPointer.DevicePointer {
+			changes = append(changes, sessionRegistryPointer.replaceIndexes(clientPointer, infoPointer))
+		}
+	}
+
+	sessionRegistryPointer.unlockAndUpdateRoomParticipants(changes...) // 解開寫鎖後通知房間管理器
+}
+
+// reindexAll - 帳號或裝置清單重新載入後，重新整理所有連線的索引
+func (sessionRegistryPointer *SessionRegistry) reindexAll() {
+
+	sessionRegistryPointer.readWriteLock.Lock() // 寫鎖
+
+	changes := []roomParticipantChange{}
+
+	for clientPointer, infoPointer := range sessionRegistryPointer.infoPointerMap {
+		changes = append(changes, sessionRegistryPointer.replaceIndexes(clientPointer, infoPointer))
+	}
+
+	sessionRegistryPointer.unlockAndUpdateRoomParticipants(changes...) // 解開寫鎖後通知房間管理器
+}
+
+// replaceAccountPointers - 帳號重新載入後，將連線登入資訊換成新的帳號指標(不修改原本的登入資訊)，並重新整理所有連線的索引
+/**
+ * @param map[string]*Account accountPointerMap 使用者登入帳號對應新的帳號指標
+ */
+func (sessionRegistryPointer *SessionRegistry) replaceAccountPointers(accountPointerMap map[string]*Account) {
+
+	sessionRegistryPointer.readWriteLock.Lock() // 寫鎖
+
+	changes := []roomParticipantChange{}
+
+	for clientPointer, infoPointer := range sessionRegistryPointer.infoPointerMap {
+
+		if nil != infoPointer && nil != infoPointer.AccountPointer {
+			if accountPointer, ok := accountPointerMap[infoPointer.AccountPointer.UserID]; ok && accountPointer != infoPointer.AccountPointer {
+				infoPointer = &Info{AccountPointer: accountPointer, DevicePointer: infoPointer.DevicePointer}
+				sessionRegistryPointer.infoPointerMap[clientPointer] = infoPointer
+			}
+		}
+
+		changes = append(changes, sessionRegistryPointer.replaceIndexes(clientPointer, infoPointer))
+	}
+
+	sessionRegistryPointer.unlockAndUpdateRoomParticipants(changes...) // 解開寫鎖後通知房間管理器
+}
+
+// getClientInfoMapCopy - 取得連線對應登入資訊的副本(可在不上鎖的情況下走訪)
+/**
+ * @return map[*client]*Info returnClientInfoMap 連線對應登入資訊的副本
+ */
+func (sessionRegistryPointer *SessionRegistry) getClientInfoMapCopy() (returnClientInfoMap map[*client]*Info) {
+
+	sessionRegistryPointer.readWriteLock.RLock()         // 讀鎖
+	defer sessionRegistryPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	returnClientInfoMap = make(map[*client]*Info, len(sessionRegistryPointer.infoPointerMap))
+
+	for clientPointer, infoPointer := range sessionRegistryPointer.infoPointerMap {
+		returnClientInfoMap[clientPointer] = infoPointer
+	}
+
+	return // 回傳
+}
+
+// getClientPointerByDeviceKey - 取得使用某裝置的連線
+/**
+ * @param string deviceKey 裝置關鍵字
+ * @return *client returnClientPointer 連線指標
+ * @return *Info returnInfoPointer 登入資訊
+ * @return bool returnOK 是否找到
+ */
+func (sessionRegistryPointer *SessionRegistry) getClientPointerByDeviceKey(deviceKey string) (returnClientPointer *client, returnInfoPointer *Info, returnOK bool) {
+
+	sessionRegistryPointer.readWriteLock.RLock()         // 讀鎖
+	defer sessionRegistryPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	if returnClientPointer, returnOK = sessionRegistryPointer.deviceKeyIndex[deviceKey]; returnOK {
+		returnInfoPointer = sessionRegistryPointer.infoPointerMap[returnClientPointer]
+	}
+
+	return // 回傳
+}
+
+// getClientPointersByUserID - 取得某帳號登入的所有連線
+/**
+ * @param string userID 使用者登入帳號
+ * @return []*client returnClientPointers 連線指標
+ */
+func (sessionRegistryPointer *SessionRegistry) getClientPointersByUserID(userID string) (returnClientPointers []*client) {
+
+	sessionRegistryPointer.readWriteLock.RLock()         // 讀鎖
+	defer sessionRegistryPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	for clientPointer := range sessionRegistryPointer.userIDIndex[userID] {
+		returnClientPointers = append(returnClientPointers, clientPointer)
+	}
+
+	return // 回傳
+}
+
+// getClientPointersByArea - 取得屬於某些場域的所有連線(不重複)
+/**
+ * @param []int area 場域代號
+ * @return []*client returnClientPointers 連線指標
+ */
+func (sessionRegistryPointer *SessionRegistry) getClientPointersByArea(area []int) (returnClientPointers []*client) {
+
+	sessionRegistryPointer.readWriteLock.RLock()         // 讀鎖
+	defer sessionRegistryPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	foundMap := make(map[*client]bool) // 已找到的連線
+
+	for _, areaNumber := range area {
+		for clientPointer := range sessionRegistryPointer.areaIndex[areaNumber] {
+
+			if !foundMap[clientPointer] {
+				foundMap[clientPointer] = true
+				returnClientPointers = append(returnClientPointers, clientPointer)
+			}
+
+		}
+	}
+
+	return // 回傳
+}
+
+// getClientPointersByRoomID - 取得某房間內的所有連線
+/**
+ * @param int roomID 房號
+ * @return []*client returnClientPointers 連線指標
+ */
+func (sessionRegistryPointer *SessionRegistry) getClientPointersByRoomID(roomID int) (returnClientPointers []*client) {
+
+	sessionRegistryPointer.readWriteLock.RLock()         // 讀鎖
+	defer sessionRegistryPointer.readWriteLock.RUnlock() // 記得解開讀鎖
+
+	for clientPointer := range sessionRegistryPointer.roomIDIndex[roomID] {
+		returnClientPointers = append(returnClientPointers, clientPointer)
+	}
+
+	return // 回傳
+}
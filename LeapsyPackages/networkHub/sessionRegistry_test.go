@@ -0,0 +1,108 @@
+package networkHub
+
+import (
+	"testing"
+)
+
+// TestProcessLoginWithDuplicateSameConnection - 相同連線、相同裝置換帳號重複登入，登記表改為新帳號並更新帳號索引
+func TestProcessLoginWithDuplicateSameConnection(t *testing.T) {
+
+	defer func(originalSessionRegistryPointer *SessionRegistry, originalHooks []deviceStatusHookFunc) {
+		sessionRegistryPointer = originalSessionRegistryPointer
+		deviceStatusHooks = originalHooks
+	}(sessionRegistryPointer, deviceStatusHooks)
+
+	deviceStatusHooks = []deviceStatusHookFunc{} // 不廣播、不記錄稽核
+
+	testCases := []struct {
+		name       string
+		newUserID  string
+		newRole    string
+		wantOldHit int // 舊帳號索引到的連線數
+	}{
+		{`換帳號登入`, `expert@leapsy.com`, RoleExpert, 0},
+		{`同帳號登入`, `frontline@leapsy.com`, RoleFrontline, 1},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			sessionRegistryPointer = NewSessionRegistry()
+
+			clientPointer := &client{}
+			devicePointer := &Device{DeviceID: `d1`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceStatus: DeviceStatusIdle, OnlineStatus: OnlineStatusOnline}
+			oldAccountPointer := &Account{UserID: `frontline@leapsy.com`, Role: RoleFrontline, Area: []int{}}
+
+			sessionRegistryPointer.setInfoPointer(clientPointer, &Info{AccountPointer: oldAccountPointer, DevicePointer: devicePointer})
+
+			newAccountPointer := &Account{UserID: testCase.newUserID, Role: testCase.newRole, Area: []int{}}
+			command := Command{DeviceID: `d1`, DeviceBrand: `b`}
+
+			if messages, loginError := processLoginWithDuplicate(`登入`, clientPointer, command, devicePointer, newAccountPointer); nil != loginError {
+				t.Fatalf(`processLoginWithDuplicate() 錯誤 = %v: %s`, loginError, messages)
+			}
+
+			infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer)
+
+			if nil == infoPointer || infoPointer.AccountPointer != newAccountPointer {
+				t.Fatalf(`登記表的帳號 = %+v，預期新帳號 %s`, infoPointer, testCase.newUserID)
+			}
+
+			if clientPointers := sessionRegistryPointer.getClientPointersByUserID(testCase.newUserID); 1 != len(clientPointers) || clientPointers[0] != clientPointer {
+				t.Errorf(`新帳號索引到 %d 個連線，預期只有此連線`, len(clientPointers))
+			}
+
+			if clientPointers := sessionRegistryPointer.getClientPointersByUserID(oldAccountPointer.UserID); testCase.wantOldHit != len(clientPointers) {
+				t.Errorf(`舊帳號索引到 %d 個連線，預期 %d 個`, len(clientPointers), testCase.wantOldHit)
+			}
+
+			if foundClientPointer, _, ok := sessionRegistryPointer.getClientPointerByDeviceKey(getDeviceKey(`d1`, `b`)); !ok || foundClientPointer != clientPointer {
+				t.Errorf(`裝置索引未指向此連線`)
+			}
+
+		})
+	}
+
+}
+
+// TestProcessLoginWithDuplicateLeavesRoom - 通話中的裝置在相同連線重複登入，變為閒置並離開原本房間
+func TestProcessLoginWithDuplicateLeavesRoom(t *testing.T) {
+
+	defer func(originalSessionRegistryPointer *SessionRegistry, originalRoomManagerPointer *RoomManager, originalHooks []deviceStatusHookFunc) {
+		sessionRegistryPointer = originalSessionRegistryPointer
+		roomManagerPointer = originalRoomManagerPointer
+		deviceStatusHooks = originalHooks
+	}(sessionRegistryPointer, roomManagerPointer, deviceStatusHooks)
+
+	deviceStatusHooks = []deviceStatusHookFunc{} // 不廣播、不記錄稽核
+
+	deviceKey := getDeviceKey(`d1`, `b`)
+
+	var roomID int
+	roomManagerPointer, roomID = newTestRoom(t, []int{1}, []string{deviceKey, getDeviceKey(`d2`, `b`)}, nil)
+
+	sessionRegistryPointer = NewSessionRegistry()
+
+	clientPointer := &client{}
+	devicePointer := &Device{DeviceID: `d1`, DeviceBrand: `b`, DeviceType: 1, Area: []int{1}, DeviceStatus: DeviceStatusInCall, OnlineStatus: OnlineStatusOnline, RoomID: roomID}
+	accountPointer := &Account{UserID: `frontline@leapsy.com`, Role: RoleFrontline, Area: []int{}}
+
+	sessionRegistryPointer.setInfoPointer(clientPointer, &Info{AccountPointer: accountPointer, DevicePointer: devicePointer})
+
+	if messages, loginError := processLoginWithDuplicate(`登入`, clientPointer, Command{DeviceID: `d1`, DeviceBrand: `b`}, devicePointer, accountPointer); nil != loginError {
+		t.Fatalf(`processLoginWithDuplicate() 錯誤 = %v: %s`, loginError, messages)
+	}
+
+	if DeviceStatusIdle != devicePointer.DeviceStatus || 0 != devicePointer.RoomID {
+		t.Errorf(`重複登入後狀態 %s、房號 %d，預期閒置且無房號`, devicePointer.DeviceStatus, devicePointer.RoomID)
+	}
+
+	if clientPointers := sessionRegistryPointer.getClientPointersByRoomID(roomID); 0 != len(clientPointers) {
+		t.Errorf(`房號索引仍有 %d 個連線`, len(clientPointers))
+	}
+
+	if room, ok := roomManagerPointer.getOpenRoom(roomID); ok && containsString(room.Participants, deviceKey) {
+		t.Errorf(`房間參與者仍有重複登入的裝置: %v`, room.Participants)
+	}
+
+}
@@ -0,0 +1,146 @@
+package networkHub
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/gobwas/ws"
+)
+
+// SignalRelay - 廣播-轉送WebRTC信令
+type SignalRelay struct {
+	Command         int             `json:"command"`
+	CommandType     int             `json:"commandType"`
+	RoomID          int             `json:"roomID"`          // 房號
+	FromDeviceID    string          `json:"fromDeviceID"`    // 發送者裝置ID
+	FromDeviceBrand string          `json:"fromDeviceBrand"` // 發送者裝置品牌
+	Signal          json.RawMessage `json:"signal"`          // SDP或ICE candidate內容
+}
+
+// WebRTC信令轉送指令(接收者可指定單一裝置，或不指定而轉送給同房間所有參與者)
+func init() {
+
+	// 轉送SDP offer
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfRelaySDPOffer,
+		name:                 `轉送SDP offer`,
+		requiredFields:       []string{`signal`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleRelaySignalCommand,
+	})
+
+	// 轉送SDP answer
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfRelaySDPAnswer,
+		name:                 `轉送SDP answer`,
+		requiredFields:       []string{`signal`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleRelaySignalCommand,
+	})
+
+	// 轉送ICE candidate
+	registerCommandHandlerOrPanic(&commandHandlerStruct{
+		commandNumber:        CommandNumberOfRelayICECandidate,
+		name:                 `轉送ICE candidate`,
+		requiredFields:       []string{`signal`},
+		isLoginRequired:      true,
+		allowedDeviceTypes:   nil,
+		isHeartbeatRefreshed: true,
+		handleFunc:           handleRelaySignalCommand,
+	})
+
+}
+
+// handleRelaySignalCommand - 處理<轉送WebRTC信令>指令(SDP offer、SDP answer、ICE candidate共用)
+/**
+ * @param *client clientPointer 連線指標
+ * @param Command command 客戶端的指令(deviceID、deviceBrand為接收者裝置，皆空白則轉送給同房間所有參與者)
+ * @param string whatKindCommandString 指令名稱
+ */
+func handleRelaySignalCommand(clientPointer *client, command Command, whatKindCommandString string) {
+
+	details := `-收到指令`
+
+	infoPointer := sessionRegistryPointer.getInfoPointer(clientPointer) // 取info
+
+	if nil == infoPointer {
+		details += `-找不到要求端連線info`
+		processResponseInfoNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	devicePointer := infoPointer.DevicePointer // 取device
+
+	if nil == devicePointer {
+		details += `-找不到裝置`
+		processResponseDeviceNil(clientPointer, whatKindCommandString, command, details)
+		return // 跳出
+	}
+
+	roomID := devicePointer.RoomID
+
+	// 檢核:發送者需在房間中
+	if 0 == roomID {
+		details += `-執行失敗:不在任何房間中，無法轉送信令`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeNotInRoom)
+		return // 跳出
+	}
+
+	jsonBytes, err := json.Marshal(SignalRelay{
+		Command:         command.Command,
+		CommandType:     CommandTypeNumberOfBroadcast,
+		RoomID:          roomID,
+		FromDeviceID:    devicePointer.DeviceID,
+		FromDeviceBrand: devicePointer.DeviceBrand,
+		Signal:          command.Signal,
+	})
+
+	if nil != err {
+		details += `-執行失敗:信令轉json失敗`
+		processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeInternalError)
+		return // 跳出
+	}
+
+	if `` == command.DeviceID && `` == command.DeviceBrand {
+
+		// 轉送給同房間所有參與者(排除自己)
+		broadcastByRoomID(roomID, websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}, clientPointer)
+
+		details += `-轉送給房號=` + strconv.Itoa(roomID) + `所有參與者`
+
+	} else {
+
+		// 檢核:接收者需在線上且與發送者同房間
+		targetClientPointer, targetInfoPointer, ok := sessionRegistryPointer.getClientPointerByDeviceKey(getDeviceKey(command.DeviceID, command.DeviceBrand))
+
+		if !ok || nil == targetInfoPointer || nil == targetInfoPointer.DevicePointer || roomID != targetInfoPointer.DevicePointer.RoomID {
+			details += `-執行失敗:接收者裝置ID=` + command.DeviceID + `,裝置品牌=` + command.DeviceBrand + `不在同一房間`
+			processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeTargetNotAvailable)
+			return // 跳出
+		}
+
+		if targetClientPointer == clientPointer {
+			details += `-執行失敗:不可轉送給自己`
+			processResponseFail(clientPointer, whatKindCommandString, command, details, ResultCodeSignalToSelf)
+			return // 跳出
+		}
+
+		// 轉送給接收者
+		targetClientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: jsonBytes}
+
+		details += `-轉送給裝置ID=` + command.DeviceID + `,裝置品牌=` + command.DeviceBrand
+	}
+
+	// Response:成功
+	responseBytes := getResponseJsonBytes(clientPointer.getLocale(), command.Command, ResultCodeSuccess, command.TransactionID)
+	clientPointer.outputChannel <- websocketData{wsOpCode: ws.OpText, dataBytes: responseBytes}
+
+	// logger
+	details += `-指令執行成功`
+	loggerFields := getLoggerFields(clientPointer) //取當下的記錄欄位
+	processLoggerInfof(whatKindCommandString, details, command, loggerFields)
+}
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"./LeapsyPackages/configurations"
+	"./LeapsyPackages/jwts"
 	"./LeapsyPackages/logings"
 	"./LeapsyPackages/network"
 	"./LeapsyPackages/networkHub"
 	"github.com/gin-gonic/gin"
 	"github.com/gobwas/ws"
+	"github.com/skip2/go-qrcode"
 )
 
 var (
@@ -21,6 +29,11 @@ func main() {
 
 	/** 此分支版本:繼續進行例外處理的分支 **/
 
+	initializeLogger() // 依設定檔初始化記錄器
+
+	jwts.Initialize()       // 依設定檔載入令牌設定與密鑰(記錄器初始化後才載入，載入紀錄才會寫入記錄檔)
+	networkHub.Initialize() // 依設定檔初始化網路中心
+
 	go startWebsocketServer() // 啟動Websocket伺服器
 
 	//go networkHub.SetSecretByteArray(networkHub.GetNewSecretByteArray())
@@ -36,6 +49,9 @@ func startWebsocketServer() {
 	go networkHub.UpdateAllDevicesList()
 	go networkHub.UpdateAllAccountList()
 	go networkHub.UpdateAllAreaMap()
+	go networkHub.CleanUpEmptyRooms()          // 定時清理沒有參與者的房間
+	go networkHub.CheckHelpRequestSLA()        // 定時檢查求助時限
+	go networkHub.CleanUpLoginFailureRecords() // 定時清除過期的登入失敗紀錄
 
 	address := fmt.Sprintf(`%s:%d`,
 		configurations.GetConfigValueOrPanic(`local`, `host`),
@@ -52,27 +68,128 @@ func startWebsocketServer() {
 		getWebsocketHandler,
 	)
 
-	var enginePointerRunError error // 伺服器啟動錯誤
+	enginePointer.GET(
+		`/healthz`,
+		getHealthzHandler,
+	)
+
+	enginePointer.GET(
+		`/readyz`,
+		getReadyzHandler,
+	)
+
+	enginePointer.GET(
+		`/metrics`,
+		getMetricsHandler,
+	)
+
+	enginePointer.GET(
+		`/result-codes`,
+		getResultCatalogueHandler,
+	)
+
+	enginePointer.GET(
+		`/admin/log/level`,
+		checkAdminTokenHandler,
+		getLogLevelHandler,
+	)
+
+	enginePointer.PUT(
+		`/admin/log/level`,
+		checkAdminTokenHandler,
+		setLogLevelHandler,
+	)
+
+	enginePointer.GET(
+		`/admin/audit`,
+		checkAdminTokenHandler,
+		getAuditRecordsHandler,
+	)
 
-	go func() {
-		enginePointerRunError = enginePointer.Run(address) // 啟動伺服器或回傳伺服器啟動錯誤
-	}()
+	enginePointer.POST(
+		`/admin/login/unlock`,
+		checkAdminTokenHandler,
+		unlockLoginHandler,
+	)
+
+	enginePointer.GET(
+		`/admin/qrcode/login`,
+		checkAdminTokenHandler,
+		getLoginQRCodeHandler,
+	)
+
+	enginePointer.GET(
+		`/admin/qrcode/area`,
+		checkAdminTokenHandler,
+		getAreaQRCodeHandler,
+	)
 
-	<-time.After(time.Second * 3) // 等待伺服器啟動結果
+	listener, netListenError := net.Listen(`tcp`, address) // 先監聽位址，監聽成功才算啟動
 
 	// 取得記錄器格式和參數
 	formatString, args := logings.GetLogFuncFormatAndArguments(
 		[]string{`%s %s 啟動`},
 		network.GetAliasAddressPair(address),
-		enginePointerRunError,
+		netListenError,
 	)
 
-	if nil != enginePointerRunError { // 若伺服器啟動錯誤
+	if nil != netListenError { // 若伺服器啟動錯誤
 		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
 	} else { // 若伺服器啟動成功
 		logger.Infof(formatString, args...) // 記錄資訊
 	}
 
+	enginePointerRunError := enginePointer.RunListener(listener) // 處理連線直到伺服器停止
+
+	// 取得記錄器格式和參數
+	formatString, args = logings.GetLogFuncFormatAndArguments(
+		[]string{`%s %s 停止`},
+		network.GetAliasAddressPair(address),
+		enginePointerRunError,
+	)
+
+	logger.Errorf(formatString, args...) // 記錄錯誤
+
+}
+
+// initializeLogger - 依設定檔的 [log] 區塊初始化記錄器，設定錯誤則逐層結束程式
+func initializeLogger() {
+
+	maxSizeMegabytes, strconvAtoiError := strconv.Atoi(configurations.GetConfigValueOrPanic(`log`, `max-size`)) // 單一記錄檔大小上限(MB)
+
+	if nil != strconvAtoiError || maxSizeMegabytes < 0 {
+		strconvAtoiError = errors.New(`[ log ] max-size 應為非負整數`)
+	}
+
+	settings := logings.Settings{
+		Level:            configurations.GetConfigValueOrPanic(`log`, `level`),
+		Directory:        configurations.GetConfigValueOrPanic(`log`, `directory`),
+		RotationInterval: time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`log`, `rotation-interval`)) * time.Hour,
+		MaxAge:           time.Duration(configurations.GetConfigPositiveIntValueOrPanic(`log`, `max-age`)) * time.Hour * 24,
+		MaxSize:          int64(maxSizeMegabytes) * 1024 * 1024,
+		Format:           configurations.GetConfigValueOrPanic(`log`, `format`),
+		IsStdoutMirrored: 1 == configurations.GetConfigPositiveIntValueOrPanic(`log`, `stdout`),
+	} // 記錄設定
+
+	initializeError := strconvAtoiError // 初始化錯誤
+
+	if nil == initializeError {
+		initializeError = logings.Initialize(settings) // 初始化記錄器
+	}
+
+	// 取得記錄器格式和參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`初始化記錄器 層級 %s 目錄 %s 格式 %s `},
+		[]interface{}{settings.Level, settings.Directory, settings.Format},
+		initializeError,
+	)
+
+	if nil != initializeError { // 若初始化記錄器錯誤
+		logger.Panicf(formatString, args...) // 記錄錯誤並逐層結束程式
+	} else { // 若初始化記錄器成功
+		logger.Infof(formatString, args...) // 記錄資訊
+	}
+
 }
 
 // getWebsocketHandler - 處理websocket
@@ -109,6 +226,368 @@ func getWebsocketHandler(ginContextPointer *gin.Context) {
 
 }
 
+// checkAdminTokenHandler - 檢查管理者權杖(未設定權杖或權杖錯誤則拒絕)
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func checkAdminTokenHandler(ginContextPointer *gin.Context) {
+
+	adminToken := configurations.GetConfigValueOrDefault(`admin`, `token`, ``) // 管理者權杖
+
+	if `` == adminToken ||
+		1 != subtle.ConstantTimeCompare([]byte(adminToken), []byte(ginContextPointer.GetHeader(`X-Admin-Token`))) {
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`%s 呼叫管理者API %s 權杖錯誤或未啟用 `},
+			[]interface{}{ginContextPointer.ClientIP(), ginContextPointer.Request.URL.Path},
+			nil,
+		)
+
+		logger.Warnf(formatString, args...) // 記錄警告
+
+		ginContextPointer.AbortWithStatusJSON(http.StatusForbidden, gin.H{`message`: `權杖錯誤或管理者API未啟用`})
+		return // 回傳
+	}
+
+	ginContextPointer.Next()
+}
+
+// getHealthzHandler - 存活檢查(伺服器能處理請求即回傳成功)
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func getHealthzHandler(ginContextPointer *gin.Context) {
+	ginContextPointer.JSON(http.StatusOK, gin.H{`status`: `ok`})
+}
+
+// getReadyzHandler - 就緒檢查(各項檢查全部通過才回傳成功，否則回傳503)
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func getReadyzHandler(ginContextPointer *gin.Context) {
+
+	healthChecks, isReady := networkHub.GetReadinessChecks() // 各項檢查結果
+
+	statusCode, status := http.StatusOK, `ok`
+
+	if !isReady { // 若有檢查未通過
+		statusCode, status = http.StatusServiceUnavailable, `unavailable`
+	}
+
+	ginContextPointer.JSON(statusCode, gin.H{
+		`status`: status,
+		`checks`: healthChecks,
+	})
+}
+
+// getMetricsHandler - 取得Prometheus文字格式的指標
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func getMetricsHandler(ginContextPointer *gin.Context) {
+	ginContextPointer.Data(http.StatusOK, `text/plain; version=0.0.4; charset=utf-8`, []byte(networkHub.GetMetricsText()))
+}
+
+// getResultCatalogueHandler - 取得結果代碼目錄(代碼、符號名稱與訊息範本，locale:語系，預設zh-TW)
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func getResultCatalogueHandler(ginContextPointer *gin.Context) {
+	ginContextPointer.JSON(http.StatusOK, networkHub.GetResultCatalogue(ginContextPointer.Query(`locale`)))
+}
+
+// getLogLevelHandler - 取得目前的記錄層級
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func getLogLevelHandler(ginContextPointer *gin.Context) {
+	ginContextPointer.JSON(http.StatusOK, gin.H{`level`: logings.GetLevel()})
+}
+
+// setLogLevelHandler - 管理者執行期間調整記錄層級(level:記錄層級)
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func setLogLevelHandler(ginContextPointer *gin.Context) {
+
+	level := ginContextPointer.Query(`level`) // 記錄層級
+	oldLevel := logings.GetLevel()            // 原本的記錄層級
+
+	setLevelError := logings.SetLevel(level) // 調整記錄層級
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`%s 將記錄層級從 %s 調整為 %s `},
+		[]interface{}{ginContextPointer.ClientIP(), oldLevel, level},
+		setLevelError,
+	)
+
+	if nil != setLevelError { // 若記錄層級錯誤
+		logger.Warnf(formatString, args...) // 記錄警告
+		ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: `level 需為 panic、fatal、error、warn、info、debug 或 trace`})
+		return // 回傳
+	}
+
+	logger.Warnf(formatString, args...) // 記錄警告(調整層級後仍需留下紀錄)
+
+	ginContextPointer.JSON(http.StatusOK, gin.H{`level`: logings.GetLevel()})
+}
+
+// getAuditRecordsHandler - 查詢稽核紀錄(from、to:RFC3339時間範圍，userID:帳號，deviceID、deviceBrand:裝置，areaID:場域代號，event:稽核事件，limit:最多筆數)
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func getAuditRecordsHandler(ginContextPointer *gin.Context) {
+
+	auditQuery := networkHub.AuditQuery{
+		UserID:      ginContextPointer.Query(`userID`),
+		DeviceID:    ginContextPointer.Query(`deviceID`),
+		DeviceBrand: ginContextPointer.Query(`deviceBrand`),
+		Event:       ginContextPointer.Query(`event`),
+	}
+
+	var parseError error
+
+	if fromString := ginContextPointer.Query(`from`); `` != fromString {
+		if auditQuery.FromTime, parseError = time.Parse(time.RFC3339, fromString); nil != parseError {
+			ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: `from 需為 RFC3339 時間，如 2006-01-02T15:04:05+08:00`})
+			return // 回傳
+		}
+	}
+
+	if toString := ginContextPointer.Query(`to`); `` != toString {
+		if auditQuery.ToTime, parseError = time.Parse(time.RFC3339, toString); nil != parseError {
+			ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: `to 需為 RFC3339 時間，如 2006-01-02T15:04:05+08:00`})
+			return // 回傳
+		}
+	}
+
+	if areaString := ginContextPointer.Query(`areaID`); `` != areaString {
+		if auditQuery.AreaNumber, parseError = strconv.Atoi(areaString); nil != parseError || auditQuery.AreaNumber <= 0 {
+			ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: `areaID 需為正整數`})
+			return // 回傳
+		}
+	}
+
+	if limitString := ginContextPointer.Query(`limit`); `` != limitString {
+		if auditQuery.Limit, parseError = strconv.Atoi(limitString); nil != parseError || auditQuery.Limit <= 0 {
+			ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: `limit 需為正整數`})
+			return // 回傳
+		}
+	}
+
+	auditRecords, queryError := networkHub.QueryAuditRecords(auditQuery) // 查詢稽核紀錄
+
+	if nil != queryError { // 若查詢錯誤
+
+		// 取得記錄器格式字串與參數
+		formatString, args := logings.GetLogFuncFormatAndArguments(
+			[]string{`%s 查詢稽核紀錄 `},
+			[]interface{}{ginContextPointer.ClientIP()},
+			queryError,
+		)
+
+		logger.Errorf(formatString, args...) // 記錄錯誤
+
+		ginContextPointer.JSON(http.StatusInternalServerError, gin.H{`message`: `查詢稽核紀錄失敗`})
+		return // 回傳
+	}
+
+	if nil == auditRecords {
+		auditRecords = []networkHub.AuditRecord{} // 沒有紀錄時回傳空陣列
+	}
+
+	ginContextPointer.JSON(http.StatusOK, gin.H{
+		`count`:   len(auditRecords),
+		`records`: auditRecords,
+	})
+}
+
+// unlockLoginHandler - 管理者解除帳號或來源位址的登入鎖定
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func unlockLoginHandler(ginContextPointer *gin.Context) {
+
+	userID := ginContextPointer.Query(`userID`)   // 帳號
+	remoteHost := ginContextPointer.Query(`host`) // 來源位址
+
+	if `` == userID && `` == remoteHost {
+		ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: `需指定 userID 或 host`})
+		return // 回傳
+	}
+
+	ginContextPointer.JSON(http.StatusOK, gin.H{
+		`userID`:     userID,
+		`host`:       remoteHost,
+		`isUnlocked`: networkHub.UnlockLogin(userID, remoteHost),
+	})
+}
+
+// getLoginQRCodeHandler - 產生QRcode登入令牌(userID:帳號)
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func getLoginQRCodeHandler(ginContextPointer *gin.Context) {
+
+	userID := ginContextPointer.Query(`userID`) // 帳號
+
+	if !networkHub.IsAccountExisted(userID) {
+		ginContextPointer.JSON(http.StatusNotFound, gin.H{`message`: `找不到帳號`})
+		return // 回傳
+	}
+
+	respondQRCodeToken(ginContextPointer, jwts.TokenInfo{Data: userID, Purpose: jwts.PurposeLogin})
+}
+
+// getAreaQRCodeHandler - 產生切換場域令牌(areaID:場域代號)
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ */
+func getAreaQRCodeHandler(ginContextPointer *gin.Context) {
+
+	areaNumber, strconvAtoiError := strconv.Atoi(ginContextPointer.Query(`areaID`)) // 場域代號
+
+	if nil != strconvAtoiError || !networkHub.IsAreaActive(areaNumber) {
+		ginContextPointer.JSON(http.StatusNotFound, gin.H{`message`: `找不到場域或場域未啟用`})
+		return // 回傳
+	}
+
+	respondQRCodeToken(ginContextPointer, jwts.TokenInfo{Data: strconv.Itoa(areaNumber), Purpose: jwts.PurposeArea})
+}
+
+// respondQRCodeToken - 簽署令牌並依 format 回傳 JSON(預設)、PNG 或 SVG，可用 ttl 指定有效秒數、size 指定圖片邊長
+/**
+ * @param  *gin.Context ginContextPointer  gin Context 指標
+ * @param  jwts.TokenInfo tokenInfo  令牌資訊
+ */
+func respondQRCodeToken(ginContextPointer *gin.Context, tokenInfo jwts.TokenInfo) {
+
+	format := ginContextPointer.DefaultQuery(`format`, `json`) // 回傳格式
+
+	if `json` != format && `png` != format && `svg` != format {
+		ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: `format 需為 json、png 或 svg`})
+		return // 回傳
+	}
+
+	size := configurations.GetConfigPositiveIntValueOrPanic(`qrcode`, `size`) // 圖片邊長
+
+	if sizeString := ginContextPointer.Query(`size`); `` != sizeString {
+
+		var strconvAtoiError error
+
+		if size, strconvAtoiError = strconv.Atoi(sizeString); nil != strconvAtoiError || size < 64 || size > 2048 {
+			ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: `size 需為 64 到 2048 的整數`})
+			return // 回傳
+		}
+
+	}
+
+	lifetime := jwts.GetTokenLifetime(tokenInfo.Purpose) // 有效時間
+
+	if ttlString := ginContextPointer.Query(`ttl`); `` != ttlString {
+
+		maxTTLSeconds := configurations.GetConfigPositiveIntValueOrPanic(`qrcode`, `max-ttl`)
+
+		ttlSeconds, strconvAtoiError := strconv.Atoi(ttlString)
+
+		if nil != strconvAtoiError || ttlSeconds <= 0 || ttlSeconds > maxTTLSeconds {
+			ginContextPointer.JSON(http.StatusBadRequest, gin.H{`message`: fmt.Sprintf(`ttl 需為 1 到 %d 的整數(秒)`, maxTTLSeconds)})
+			return // 回傳
+		}
+
+		lifetime = time.Duration(ttlSeconds) * time.Second
+	}
+
+	expiresAt := time.Now().Add(lifetime) // 到期時間
+
+	tokenStringPointer := jwts.CreateTokenWithLifetime(&tokenInfo, lifetime)
+
+	var qrcodeError error
+
+	if nil == tokenStringPointer {
+		qrcodeError = errors.New(`簽署令牌失敗`)
+	}
+
+	// 取得記錄器格式字串與參數
+	formatString, args := logings.GetLogFuncFormatAndArguments(
+		[]string{`%s 產生 %s 令牌 資料=%s 到期=%s 格式=%s `},
+		[]interface{}{ginContextPointer.ClientIP(), tokenInfo.Purpose, tokenInfo.Data, expiresAt.Format(time.RFC3339), format},
+		qrcodeError,
+	)
+
+	if nil != qrcodeError {
+		logger.Errorf(formatString, args...) // 記錄錯誤
+		ginContextPointer.JSON(http.StatusInternalServerError, gin.H{`message`: qrcodeError.Error()})
+		return // 回傳
+	}
+
+	go logger.Infof(formatString, args...) // 記錄資訊
+
+	switch format {
+
+	case `png`:
+
+		pngBytes, qrcodeEncodeError := qrcode.Encode(*tokenStringPointer, qrcode.Medium, size)
+
+		if nil != qrcodeEncodeError {
+			ginContextPointer.JSON(http.StatusInternalServerError, gin.H{`message`: qrcodeEncodeError.Error()})
+			return // 回傳
+		}
+
+		ginContextPointer.Data(http.StatusOK, `image/png`, pngBytes)
+
+	case `svg`:
+
+		qrCodePointer, qrcodeNewError := qrcode.New(*tokenStringPointer, qrcode.Medium)
+
+		if nil != qrcodeNewError {
+			ginContextPointer.JSON(http.StatusInternalServerError, gin.H{`message`: qrcodeNewError.Error()})
+			return // 回傳
+		}
+
+		ginContextPointer.Data(http.StatusOK, `image/svg+xml`, getQRCodeSVGBytes(qrCodePointer.Bitmap(), size))
+
+	default:
+
+		ginContextPointer.JSON(http.StatusOK, gin.H{
+			`token`:     *tokenStringPointer,
+			`purpose`:   tokenInfo.Purpose,
+			`data`:      tokenInfo.Data,
+			`expiresAt`: expiresAt.Format(time.RFC3339),
+		})
+
+	}
+
+}
+
+// getQRCodeSVGBytes - 將QRcode點陣轉為SVG
+/**
+ * @param  [][]bool bitmap  QRcode點陣(含留白)
+ * @param  int size  圖片邊長
+ * @return []byte SVG內容
+ */
+func getQRCodeSVGBytes(bitmap [][]bool, size int) []byte {
+
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, len(bitmap), len(bitmap))
+	builder.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/>`)
+
+	for y, row := range bitmap {
+		for x, isBlack := range row {
+			if isBlack {
+				fmt.Fprintf(&builder, `<rect x="%d" y="%d" width="1" height="1"/>`, x, y)
+			}
+		}
+	}
+
+	builder.WriteString(`</svg>`)
+
+	return []byte(builder.String())
+}
+
 // deleteInvalidAndOutputValidFiles - 刪除失效檔案並輸出有效檔案
 /**
  * @param  *net.Conn connectionPointer  連線指標